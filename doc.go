@@ -15,6 +15,9 @@ The sub-packages contain all the relevant code:
 useful type-level properties that are used in the GoGi GUI.  It also
 is a powerful 'kit for dealing with Go's reflect system.
 
+* xmlki: imports arbitrary XML and HTML documents into trees of generic
+element nodes, and exports them back to XML.
+
 * ints, floats, dirs, bitflag, atomctr, indent all provide basic
 Go infrastructure that one could argue should have been in the
 standard library, but isn't..
@@ -3,6 +3,7 @@ module github.com/goki/ki
 require (
 	github.com/goki/prof v0.0.0-20180502205428-54bc71b5d09b
 	github.com/jinzhu/copier v0.0.0-20190924061706-b57f9002281a
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-go 1.14
+go 1.23
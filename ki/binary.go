@@ -0,0 +1,873 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/goki/ki/kit"
+)
+
+// The binary format is a compact alternative to JSON for frequent saving,
+// e.g., for autosave or IPC.  After the BinaryPrefix, the root type name is
+// followed by the fields of the root node, in the order of the struct type
+// layout, and recursively for all the Kids.  The layout (field names) of each
+// struct type is saved the first time the type is encountered, and a
+// mismatch with the current layout is an error.  The same fields are saved as
+// in JSON (exported, and not tagged json:"-").  Type names, node names and
+// other short strings are saved once in a string table and then referred to
+// by index.  Enums are saved by their registered names, and interface values
+// (e.g., in Props and PropSlice) by their kit.Types type names.
+
+// BinaryPrefix is the first thing output in a ki tree binary output file,
+// identifying the file as a ki tree binary file, with the format version.
+var BinaryPrefix = []byte("KiB\x01")
+
+// BinaryMaxIntern is the maximum length of strings that are saved in the
+// string table of the binary format -- longer strings are saved directly.
+var BinaryMaxIntern = 64
+
+// WriteBinary writes the tree to an io.Writer in the compact binary format
+// -- see ReadNewBinary to load a new tree, and ReadBinary to load onto an
+// existing one.
+func (n *Node) WriteBinary(writer io.Writer) error {
+	err := n.ThisCheck()
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(writer)
+	be := newBinEncoder(bw)
+	be.write(BinaryPrefix)
+	be.str(kit.Types.TypeName(n.Type()))
+	err = be.value(reflect.ValueOf(n.This()).Elem())
+	if err == nil {
+		err = be.err
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// ReadBinary reads the tree starting at this node from the binary format
+// written by WriteBinary.  The root type in the stream must be the same as
+// this node -- see ReadNewBinary to construct a new tree.  Uses
+// ConfigureChildren to minimize changes from current tree relative to
+// loading one, and calls UnmarshalPost to recover pointers from paths.
+func (n *Node) ReadBinary(reader io.Reader) error {
+	err := n.ThisCheck()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	bd := newBinDecoder(reader)
+	tn, err := bd.header()
+	if err == nil && tn != kit.Types.TypeName(n.Type()) {
+		err = fmt.Errorf("ki.ReadBinary: root type %v is not same as this node type %v", tn, kit.Types.TypeName(n.Type()))
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if us := UndoStackFor(n.This()); us != nil {
+		defer us.Reset()
+	}
+	updt := n.UpdateStart()
+	err = bd.value(reflect.ValueOf(n.This()).Elem())
+	if err == nil {
+		err = n.unmarshalPost()
+	}
+	n.SetFlag(int(ChildAdded)) // this might not be set..
+	n.UpdateEnd(updt)
+	return err
+}
+
+// ReadNewBinary reads a new Ki tree from the binary format written by
+// WriteBinary, using the root type name at the start to create an object of
+// the proper type.
+func ReadNewBinary(reader io.Reader) (Ki, error) {
+	bd := newBinDecoder(reader)
+	tn, err := bd.header()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	typ := kit.Types.Type(tn)
+	if typ == nil {
+		return nil, fmt.Errorf("ki.ReadNewBinary: kit.Types type name not found: %v", tn)
+	}
+	root := NewOfType(typ)
+	root.Init(root)
+
+	updt := root.UpdateStart()
+	err = bd.value(reflect.ValueOf(root).Elem())
+	if err == nil {
+		err = root.AsNode().unmarshalPost()
+	}
+	root.SetFlag(int(ChildAdded)) // this might not be set..
+	root.UpdateEnd(updt)
+	return root, err
+}
+
+// tags for interface values in the binary format
+const (
+	binNil byte = iota
+	binBasic
+	binTyped
+	binTypedPtr
+	binList
+	binMap
+)
+
+// binBasicTypes are the unnamed basic types that are saved in interface
+// values by their reflect.Kind
+var binBasicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:       reflect.TypeOf(false),
+	reflect.Int:        reflect.TypeOf(int(0)),
+	reflect.Int8:       reflect.TypeOf(int8(0)),
+	reflect.Int16:      reflect.TypeOf(int16(0)),
+	reflect.Int32:      reflect.TypeOf(int32(0)),
+	reflect.Int64:      reflect.TypeOf(int64(0)),
+	reflect.Uint:       reflect.TypeOf(uint(0)),
+	reflect.Uint8:      reflect.TypeOf(uint8(0)),
+	reflect.Uint16:     reflect.TypeOf(uint16(0)),
+	reflect.Uint32:     reflect.TypeOf(uint32(0)),
+	reflect.Uint64:     reflect.TypeOf(uint64(0)),
+	reflect.Float32:    reflect.TypeOf(float32(0)),
+	reflect.Float64:    reflect.TypeOf(float64(0)),
+	reflect.Complex64:  reflect.TypeOf(complex64(0)),
+	reflect.Complex128: reflect.TypeOf(complex128(0)),
+	reflect.String:     reflect.TypeOf(""),
+}
+
+var (
+	binSliceType  = reflect.TypeOf(Slice{})
+	binListType   = reflect.TypeOf([]interface{}{})
+	binMapType    = reflect.TypeOf(map[string]interface{}{})
+	binMarshType  = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binUnmarshTyp = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// binIsEnum returns true if type is a registered enum
+func binIsEnum(typ reflect.Type) bool {
+	k := typ.Kind()
+	return k >= reflect.Int && k <= reflect.Uint64 && kit.Enums.TypeRegistered(typ)
+}
+
+// enumFromString sets the enum pointed to by ptr from its string name,
+// returning an error if the name is not valid for the enum type
+func enumFromString(ptr reflect.Value, str string) error {
+	if fs, ok := ptr.Interface().(interface{ FromString(string) error }); ok {
+		return fs.FromString(str)
+	}
+	return kit.SetEnumValueFromString(ptr, str)
+}
+
+// binTypeByName returns the type for given registered type name
+func binTypeByName(tn string) reflect.Type {
+	if typ := kit.Types.Type(tn); typ != nil {
+		return typ
+	}
+	return kit.Enums.Enum(tn)
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  binEncoder
+
+// binEncoder writes values in the binary format
+type binEncoder struct {
+	w       *bufio.Writer
+	strs    map[string]int
+	layouts map[reflect.Type]bool
+	buf     [binary.MaxVarintLen64]byte
+	err     error
+}
+
+func newBinEncoder(w *bufio.Writer) *binEncoder {
+	return &binEncoder{w: w, strs: make(map[string]int), layouts: make(map[reflect.Type]bool)}
+}
+
+func (be *binEncoder) write(b []byte) {
+	if be.err != nil {
+		return
+	}
+	_, be.err = be.w.Write(b)
+}
+
+func (be *binEncoder) byte(b byte) {
+	if be.err != nil {
+		return
+	}
+	be.err = be.w.WriteByte(b)
+}
+
+func (be *binEncoder) uvarint(u uint64) {
+	n := binary.PutUvarint(be.buf[:], u)
+	be.write(be.buf[:n])
+}
+
+func (be *binEncoder) varint(i int64) {
+	n := binary.PutVarint(be.buf[:], i)
+	be.write(be.buf[:n])
+}
+
+// str writes a string: 0 = new string added to the table, 1 = string not
+// in the table, else index into the table + 2
+func (be *binEncoder) str(s string) {
+	if idx, ok := be.strs[s]; ok {
+		be.uvarint(uint64(idx + 2))
+		return
+	}
+	if len(s) <= BinaryMaxIntern {
+		be.strs[s] = len(be.strs)
+		be.uvarint(0)
+	} else {
+		be.uvarint(1)
+	}
+	be.uvarint(uint64(len(s)))
+	be.write([]byte(s))
+}
+
+// value writes given value according to its type
+func (be *binEncoder) value(v reflect.Value) error {
+	typ := v.Type()
+	if typ == binSliceType {
+		return be.kids(v.Interface().(Slice))
+	}
+	if binIsEnum(typ) {
+		be.str(kit.EnumIfaceToString(v.Interface()))
+		return nil
+	}
+	if typ.Kind() != reflect.Ptr && typ.Kind() != reflect.Interface && typ.Implements(binMarshType) {
+		b, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		be.uvarint(uint64(len(b)))
+		be.write(b)
+		return nil
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			be.byte(1)
+		} else {
+			be.byte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		be.varint(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		be.uvarint(v.Uint())
+	case reflect.Float32:
+		binary.LittleEndian.PutUint32(be.buf[:4], math.Float32bits(float32(v.Float())))
+		be.write(be.buf[:4])
+	case reflect.Float64:
+		binary.LittleEndian.PutUint64(be.buf[:8], math.Float64bits(v.Float()))
+		be.write(be.buf[:8])
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		be.value(reflect.ValueOf(real(c)))
+		be.value(reflect.ValueOf(imag(c)))
+	case reflect.String:
+		be.str(v.String())
+	case reflect.Struct:
+		flds := jsonFields(typ)
+		if !be.layouts[typ] {
+			be.layouts[typ] = true
+			be.uvarint(uint64(len(flds)))
+			for _, fi := range flds {
+				be.str(typ.Field(fi).Name)
+			}
+		}
+		for _, fi := range flds {
+			if err := be.value(v.Field(fi)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := be.value(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			be.uvarint(0)
+			return nil
+		}
+		be.uvarint(uint64(v.Len() + 1))
+		if typ.Elem().Kind() == reflect.Uint8 {
+			be.write(v.Bytes())
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := be.value(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			be.uvarint(0)
+			return nil
+		}
+		be.uvarint(uint64(v.Len() + 1))
+		keys := v.MapKeys()
+		if typ.Key().Kind() == reflect.String { // deterministic output
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		}
+		for _, key := range keys {
+			if err := be.value(key); err != nil {
+				return err
+			}
+			if err := be.value(v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			be.byte(0)
+			return nil
+		}
+		be.byte(1)
+		return be.value(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			be.byte(binNil)
+			return nil
+		}
+		return be.iface(v.Elem())
+	default:
+		return fmt.Errorf("ki.WriteBinary: cannot save value of type: %v", typ)
+	}
+	return be.err
+}
+
+// iface writes the value of an interface, preceded by its type information
+func (be *binEncoder) iface(v reflect.Value) error {
+	typ := v.Type()
+	switch {
+	case typ == binListType:
+		be.byte(binList)
+		return be.value(v)
+	case typ == binMapType:
+		be.byte(binMap)
+		return be.value(v)
+	case typ.Kind() == reflect.Ptr && !v.IsNil():
+		tn := kit.Types.TypeName(typ.Elem())
+		if binTypeByName(tn) == nil {
+			return fmt.Errorf("ki.WriteBinary: cannot save value of type %v -- not registered in kit.Types", typ)
+		}
+		be.byte(binTypedPtr)
+		be.str(tn)
+		return be.value(v.Elem())
+	case binBasicTypes[typ.Kind()] == typ:
+		be.byte(binBasic)
+		be.byte(byte(typ.Kind()))
+		return be.value(v)
+	}
+	tn := kit.Types.TypeName(typ)
+	if binTypeByName(tn) != typ {
+		if bt, ok := binBasicTypes[typ.Kind()]; ok { // saved as the basic type, as in JSON
+			return be.iface(v.Convert(bt))
+		}
+		return fmt.Errorf("ki.WriteBinary: cannot save value of type %v -- not registered in kit.Types", typ)
+	}
+	be.byte(binTyped)
+	be.str(tn)
+	return be.value(v)
+}
+
+// kids writes the number, types and names of the kids, and then each kid
+func (be *binEncoder) kids(sl Slice) error {
+	be.uvarint(uint64(len(sl)))
+	for _, kid := range sl {
+		be.str(kit.Types.TypeName(kid.Type()))
+		be.str(kid.UniqueName())
+	}
+	for _, kid := range sl {
+		if err := be.value(reflect.ValueOf(kid).Elem()); err != nil {
+			return err
+		}
+	}
+	return be.err
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  binDecoder
+
+// binDecoder reads values in the binary format -- lengths read from the
+// input are checked against the number of bytes remaining in it when that
+// is known (see binInputSize), and otherwise memory is only allocated as
+// the data actually arrives, so a corrupt length cannot force a large
+// allocation.
+type binDecoder struct {
+	r       *bufio.Reader
+	cr      *binCountReader
+	size    int64 // size of the input, or -1 if unknown
+	strs    []string
+	layouts map[reflect.Type]bool
+	buf     [8]byte
+}
+
+// binChunk is the most that is allocated ahead of the data arriving when
+// the size of the input is unknown
+const binChunk = 1 << 16
+
+func newBinDecoder(r io.Reader) *binDecoder {
+	cr := &binCountReader{r: r}
+	return &binDecoder{r: bufio.NewReader(cr), cr: cr, size: binInputSize(r), layouts: make(map[reflect.Type]bool)}
+}
+
+// binCountReader counts the bytes read from the input
+type binCountReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *binCountReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// binInputSize returns the number of bytes remaining in the input, if it
+// has a Len method (e.g., bytes.Reader, bytes.Buffer) or can seek (e.g., a
+// file), or -1 otherwise
+func binInputSize(r io.Reader) int64 {
+	switch rt := r.(type) {
+	case interface{ Len() int }:
+		return int64(rt.Len())
+	case io.Seeker:
+		cur, err := rt.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		end, err := rt.Seek(0, io.SeekEnd)
+		if err != nil {
+			return -1
+		}
+		if _, err := rt.Seek(cur, io.SeekStart); err != nil {
+			return -1
+		}
+		return end - cur
+	}
+	return -1
+}
+
+// remaining returns the number of bytes remaining in the input, or -1 if
+// unknown
+func (bd *binDecoder) remaining() int64 {
+	if bd.size < 0 {
+		return -1
+	}
+	return bd.size - bd.cr.n + int64(bd.r.Buffered())
+}
+
+// checkLen returns an error if given length, of items that each take at
+// least size bytes in the input, is more than the input can hold
+func (bd *binDecoder) checkLen(n uint64, size int64) error {
+	if n > uint64(math.MaxInt32) {
+		return fmt.Errorf("ki.ReadBinary: invalid length: %d", n)
+	}
+	if rem := bd.remaining(); rem >= 0 && int64(n)*size > rem {
+		return fmt.Errorf("ki.ReadBinary: invalid length: %d, only %d bytes remaining", n, rem)
+	}
+	return nil
+}
+
+// allocLen returns the number of items of given length to allocate ahead
+// of reading them -- at most the bytes remaining in the input, or binChunk
+// if unknown, as items of zero size in the input cannot be ruled out
+func (bd *binDecoder) allocLen(n int) int {
+	lim := bd.remaining()
+	if lim < 0 {
+		lim = binChunk
+	}
+	if int64(n) > lim {
+		return int(lim)
+	}
+	return n
+}
+
+// header reads the BinaryPrefix and returns the root type name
+func (bd *binDecoder) header() (string, error) {
+	pb := make([]byte, len(BinaryPrefix))
+	if _, err := io.ReadFull(bd.r, pb); err != nil || !bytes.Equal(pb[:3], BinaryPrefix[:3]) {
+		return "", fmt.Errorf("ki.ReadBinary: binary prefix not found at start of stream")
+	}
+	if pb[3] != BinaryPrefix[3] {
+		return "", fmt.Errorf("ki.ReadBinary: binary format version %d not supported", pb[3])
+	}
+	return bd.str()
+}
+
+func (bd *binDecoder) eof(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (bd *binDecoder) uvarint() (uint64, error) {
+	u, err := binary.ReadUvarint(bd.r)
+	return u, bd.eof(err)
+}
+
+func (bd *binDecoder) varint() (int64, error) {
+	i, err := binary.ReadVarint(bd.r)
+	return i, bd.eof(err)
+}
+
+func (bd *binDecoder) byte() (byte, error) {
+	b, err := bd.r.ReadByte()
+	return b, bd.eof(err)
+}
+
+func (bd *binDecoder) bytes(n uint64) ([]byte, error) {
+	if err := bd.checkLen(n, 1); err != nil {
+		return nil, err
+	}
+	if bd.size < 0 && n > binChunk {
+		var bb bytes.Buffer
+		_, err := io.CopyN(&bb, bd.r, int64(n))
+		return bb.Bytes(), bd.eof(err)
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(bd.r, b)
+	return b, bd.eof(err)
+}
+
+// str reads a string written by binEncoder.str
+func (bd *binDecoder) str() (string, error) {
+	code, err := bd.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if code >= 2 {
+		idx := code - 2
+		if idx >= uint64(len(bd.strs)) {
+			return "", fmt.Errorf("ki.ReadBinary: invalid string table index: %d", idx)
+		}
+		return bd.strs[idx], nil
+	}
+	n, err := bd.uvarint()
+	if err != nil {
+		return "", err
+	}
+	b, err := bd.bytes(n)
+	if err != nil {
+		return "", err
+	}
+	s := string(b)
+	if code == 0 {
+		bd.strs = append(bd.strs, s)
+	}
+	return s, nil
+}
+
+// length reads a slice or map length, returning -1 for nil
+func (bd *binDecoder) length() (int, error) {
+	n, err := bd.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(math.MaxInt32) {
+		return 0, fmt.Errorf("ki.ReadBinary: invalid length: %d", n)
+	}
+	return int(n) - 1, nil
+}
+
+// value reads into given settable value according to its type
+func (bd *binDecoder) value(v reflect.Value) error {
+	typ := v.Type()
+	if typ == binSliceType {
+		return bd.kids(v.Addr().Interface().(*Slice))
+	}
+	if binIsEnum(typ) {
+		s, err := bd.str()
+		if err != nil {
+			return err
+		}
+		return enumFromString(v.Addr(), s)
+	}
+	if typ.Kind() != reflect.Ptr && typ.Kind() != reflect.Interface && typ.Implements(binMarshType) && reflect.PtrTo(typ).Implements(binUnmarshTyp) {
+		n, err := bd.uvarint()
+		if err != nil {
+			return err
+		}
+		b, err := bd.bytes(n)
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		b, err := bd.byte()
+		if err != nil {
+			return err
+		}
+		v.SetBool(b != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := bd.varint()
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := bd.uvarint()
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32:
+		if _, err := io.ReadFull(bd.r, bd.buf[:4]); err != nil {
+			return bd.eof(err)
+		}
+		v.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(bd.buf[:4]))))
+	case reflect.Float64:
+		if _, err := io.ReadFull(bd.r, bd.buf[:8]); err != nil {
+			return bd.eof(err)
+		}
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(bd.buf[:8])))
+	case reflect.Complex64, reflect.Complex128:
+		var re, im float64
+		if err := bd.value(reflect.ValueOf(&re).Elem()); err != nil {
+			return err
+		}
+		if err := bd.value(reflect.ValueOf(&im).Elem()); err != nil {
+			return err
+		}
+		v.SetComplex(complex(re, im))
+	case reflect.String:
+		s, err := bd.str()
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Struct:
+		flds := jsonFields(typ)
+		if !bd.layouts[typ] {
+			if err := bd.layout(typ, flds); err != nil {
+				return err
+			}
+		}
+		for _, fi := range flds {
+			if err := bd.value(v.Field(fi)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := bd.value(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		n, err := bd.length()
+		if err != nil || n < 0 {
+			v.Set(reflect.Zero(typ))
+			return err
+		}
+		if typ.Elem().Kind() == reflect.Uint8 {
+			b, err := bd.bytes(uint64(n))
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		sv := reflect.MakeSlice(typ, 0, bd.allocLen(n))
+		ez := reflect.Zero(typ.Elem())
+		for i := 0; i < n; i++ {
+			sv = reflect.Append(sv, ez)
+			if err := bd.value(sv.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(sv)
+	case reflect.Map:
+		n, err := bd.length()
+		if err != nil || n < 0 {
+			v.Set(reflect.Zero(typ))
+			return err
+		}
+		mv := reflect.MakeMapWithSize(typ, bd.allocLen(n))
+		for i := 0; i < n; i++ {
+			key := reflect.New(typ.Key()).Elem()
+			if err := bd.value(key); err != nil {
+				return err
+			}
+			val := reflect.New(typ.Elem()).Elem()
+			if err := bd.value(val); err != nil {
+				return err
+			}
+			mv.SetMapIndex(key, val)
+		}
+		v.Set(mv)
+	case reflect.Ptr:
+		b, err := bd.byte()
+		if err != nil || b == 0 {
+			v.Set(reflect.Zero(typ))
+			return err
+		}
+		pv := reflect.New(typ.Elem())
+		if err := bd.value(pv.Elem()); err != nil {
+			return err
+		}
+		v.Set(pv)
+	case reflect.Interface:
+		iv, err := bd.iface()
+		if err != nil {
+			return err
+		}
+		if !iv.IsValid() {
+			v.Set(reflect.Zero(typ))
+			return nil
+		}
+		if !iv.Type().AssignableTo(typ) {
+			return fmt.Errorf("ki.ReadBinary: value of type %v cannot be assigned to %v", iv.Type(), typ)
+		}
+		v.Set(iv)
+	default:
+		return fmt.Errorf("ki.ReadBinary: cannot load value of type: %v", typ)
+	}
+	return nil
+}
+
+// layout reads the saved layout of struct type, which must match the fields
+func (bd *binDecoder) layout(typ reflect.Type, flds []int) error {
+	n, err := bd.uvarint()
+	if err != nil {
+		return err
+	}
+	if err := bd.checkLen(n, 1); err != nil {
+		return err
+	}
+	nms := make([]string, 0, min(n, uint64(len(flds)+1)))
+	for i := uint64(0); i < n && i <= uint64(len(flds)); i++ {
+		nm, err := bd.str()
+		if err != nil {
+			return err
+		}
+		nms = append(nms, nm)
+	}
+	cur := make([]string, len(flds))
+	for i, fi := range flds {
+		cur[i] = typ.Field(fi).Name
+	}
+	if strings.Join(nms, ",") != strings.Join(cur, ",") {
+		return fmt.Errorf("ki.ReadBinary: saved layout of type %v: %v does not match current fields: %v", kit.Types.TypeName(typ), nms, cur)
+	}
+	bd.layouts[typ] = true
+	return nil
+}
+
+// iface reads an interface value written by binEncoder.iface -- returns
+// an invalid Value for nil
+func (bd *binDecoder) iface() (reflect.Value, error) {
+	tag, err := bd.byte()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	var typ reflect.Type
+	switch tag {
+	case binNil:
+		return reflect.Value{}, nil
+	case binList:
+		typ = binListType
+	case binMap:
+		typ = binMapType
+	case binBasic:
+		k, err := bd.byte()
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		typ = binBasicTypes[reflect.Kind(k)]
+		if typ == nil {
+			return reflect.Value{}, fmt.Errorf("ki.ReadBinary: invalid basic kind: %d", k)
+		}
+	case binTyped, binTypedPtr:
+		tn, err := bd.str()
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		typ = binTypeByName(tn)
+		if typ == nil {
+			return reflect.Value{}, fmt.Errorf("ki.ReadBinary: kit.Types type name not found: %v", tn)
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("ki.ReadBinary: invalid value tag: %d", tag)
+	}
+	pv := reflect.New(typ)
+	var kival Ki
+	if tag == binTypedPtr && IsKi(typ) {
+		kival = pv.Interface().(Ki)
+		kival.Init(kival)
+	}
+	if err := bd.value(pv.Elem()); err != nil {
+		return reflect.Value{}, err
+	}
+	if kival != nil {
+		kival.UnmarshalPost()
+	}
+	if tag == binTypedPtr {
+		return pv, nil
+	}
+	return pv.Elem(), nil
+}
+
+// kids reads the number, types and names of the kids, configures the slice
+// accordingly, and then reads each kid
+func (bd *binDecoder) kids(sl *Slice) error {
+	n, err := bd.uvarint()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		*sl = nil
+		return nil
+	}
+	if err := bd.checkLen(n, 2); err != nil { // type and name of each
+		return err
+	}
+	var tnl kit.TypeAndNameList
+	for i := uint64(0); i < n; i++ {
+		tn, err := bd.str()
+		if err != nil {
+			return err
+		}
+		typ := kit.Types.Type(tn)
+		if typ == nil {
+			return fmt.Errorf("ki.ReadBinary: kit.Types type name not found: %v", tn)
+		}
+		nm, err := bd.str()
+		if err != nil {
+			return err
+		}
+		tnl.Add(typ, nm)
+	}
+	sl.Config(nil, tnl, true) // true = uniq names
+	for _, kid := range *sl {
+		if err := bd.value(reflect.ValueOf(kid).Elem()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
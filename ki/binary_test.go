@@ -0,0 +1,133 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func binTestTree() Ki {
+	root := diffTestTree()
+	root.Embed(KiT_NodeField).(*NodeField).Field1.AddNewChild(KiT_NodeEmbed, "fieldkid")
+	c2 := root.ChildByName("child2", 0)
+	c2.SetProp("floatprop", 2.5)
+	c2.SetProp("enumprop", EditMove)
+	c2.SetProp("blank", BlankProp{})
+	c2.SetProp("list", []interface{}{1.5, "two", nil})
+	c2.SetProp("sub", Props{"color": "red", "width": float32(2), "sub2": Props{"n": 3}})
+	c2.SetProp("slice", PropSlice{{"first", 1}, {"second", Props{"x": "y"}}})
+	root.Child(0).(*NodeEmbed).Mbr1 = strings.Repeat("long string ", 10)
+	root.Child(3).AddNewChild(KiT_NodeField2, "kid2").(*NodeField2).Field2.Mbr2 = 7
+	return root
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	root := binTestTree()
+	var buf bytes.Buffer
+	err := root.WriteBinary(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jbuf bytes.Buffer
+	root.WriteJSON(&jbuf, false)
+	if buf.Len() >= jbuf.Len()/2 {
+		t.Errorf("binary size %d not much smaller than JSON: %d", buf.Len(), jbuf.Len())
+	}
+	b := buf.Bytes()
+
+	nwnd, err := ReadNewBinary(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if es, err := Diff(root, nwnd); err != nil || len(es) != 0 {
+		t.Errorf("round trip diffs: %v\n%v", err, es)
+	}
+	c2 := root.ChildByName("child2", 0)
+	nc2 := nwnd.ChildByName("child2", 0)
+	if !reflect.DeepEqual(c2.Properties(), nc2.Properties()) {
+		t.Errorf("props not equal:\n%v\nvs.\n%v", c2.Properties(), nc2.Properties())
+	}
+	if nc2.Prop("enumprop") != EditMove {
+		t.Errorf("enum prop not restored: %v", nc2.Prop("enumprop"))
+	}
+	if nc2.Parent() != nwnd || nc2.Child(0).Parent() != nc2 {
+		t.Errorf("parents not set by UnmarshalPost")
+	}
+	if nwnd.Child(3).Child(0).(*NodeField2).Field2.Mbr2 != 7 {
+		t.Errorf("nested Ki field not restored")
+	}
+	if nwnd.Embed(KiT_NodeField).(*NodeField).Field1.NumChildren() != 1 {
+		t.Errorf("Ki field kids not restored")
+	}
+
+	// ReadBinary onto an existing tree keeps the existing nodes
+	tree := diffTestTree()
+	oc2 := tree.ChildByName("child2", 0)
+	err = tree.ReadBinary(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.ChildByName("child2", 0) != oc2 {
+		t.Errorf("ReadBinary should configure existing children")
+	}
+	if es, err := Diff(root, tree); err != nil || len(es) != 0 {
+		t.Errorf("ReadBinary diffs: %v\n%v", err, es)
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	root := binTestTree()
+	var buf bytes.Buffer
+	root.WriteBinary(&buf)
+	b := buf.Bytes()
+
+	if _, err := ReadNewBinary(bytes.NewReader([]byte("not binary"))); err == nil {
+		t.Errorf("expected error for missing prefix")
+	}
+	for _, n := range []int{len(BinaryPrefix) + 2, len(b) / 2, len(b) - 1} {
+		if _, err := ReadNewBinary(bytes.NewReader(b[:n])); err == nil {
+			t.Errorf("expected error for truncated stream at %d", n)
+		}
+	}
+	other := NodeEmbed{}
+	other.InitName(&other, "other")
+	if err := other.ReadBinary(bytes.NewReader(b)); err == nil {
+		t.Errorf("expected error for wrong root type")
+	}
+	root.SetProp("unregistered", struct{ A int }{1})
+	if err := root.WriteBinary(&buf); err == nil {
+		t.Errorf("expected error for unregistered prop type")
+	}
+}
+
+func TestBinaryCorruptLength(t *testing.T) {
+	// prefix, new string of length 1<<30 for the root type name
+	b := append([]byte{}, BinaryPrefix...)
+	b = append(b, 0)
+	b = binary.AppendUvarint(b, 1<<30+1)
+
+	readers := map[string]func() io.Reader{
+		"sized":   func() io.Reader { return bytes.NewReader(b) },
+		"unsized": func() io.Reader { return io.MultiReader(bytes.NewReader(b)) },
+	}
+	for nm, rd := range readers {
+		var ms0, ms1 runtime.MemStats
+		runtime.ReadMemStats(&ms0)
+		_, err := ReadNewBinary(rd())
+		runtime.ReadMemStats(&ms1)
+		if err == nil {
+			t.Errorf("%v: expected error for corrupt length", nm)
+		}
+		if alloc := ms1.TotalAlloc - ms0.TotalAlloc; alloc > 1<<20 {
+			t.Errorf("%v: corrupt length allocated %d bytes", nm, alloc)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/goki/ki/kit"
+)
+
+// ChangeTypes are the different types of changes recorded in a Change.
+type ChangeTypes int32
+
+const (
+	// ChangeChildAdded means child Kid was added at Index -- if it was moved
+	// from another parent, that parent records a ChangeChildDeleted.
+	ChangeChildAdded ChangeTypes = iota
+
+	// ChangeChildDeleted means child Kid was deleted from Index.
+	ChangeChildDeleted
+
+	// ChangeChildMoved means child Kid was moved from index From to index To.
+	ChangeChildMoved
+
+	// ChangeChildrenDeleted means all the children were deleted.
+	ChangeChildrenDeleted
+
+	// ChangeProp means property Key was set from Old (nil if it was not
+	// set) to New.
+	ChangeProp
+
+	// ChangeDelProp means property Key, with value Old, was deleted.
+	ChangeDelProp
+
+	// ChangeField means field Name was set.
+	ChangeField
+
+	ChangeTypesN
+)
+
+//go:generate stringer -type=ChangeTypes
+
+var KiT_ChangeTypes = kit.Enums.AddEnum(ChangeTypesN, kit.NotBitFlag, nil)
+
+func (ev ChangeTypes) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *ChangeTypes) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+// Change is a record of one change to a node within an update batch (from
+// UpdateStart to UpdateEnd), sent to receivers of the resulting
+// NodeSignalUpdated signal in the Changes of its UpdatedData, on
+// connections made WithChanges -- the Flags only say what types of changes
+// happened.
+type Change struct {
+	Type  ChangeTypes `desc:"type of change"`
+	Node  Ki          `desc:"node that changed -- the parent for changes to children"`
+	Kid   Ki          `desc:"for ChangeChildAdded, ChangeChildDeleted, ChangeChildMoved, the child"`
+	Index int         `desc:"for ChangeChildAdded, ChangeChildDeleted, the index of the child"`
+	From  int         `desc:"for ChangeChildMoved, the index the child moved from"`
+	To    int         `desc:"for ChangeChildMoved, the index the child moved to"`
+	Key   string      `desc:"for ChangeProp, ChangeDelProp, the property key"`
+	Old   interface{} `desc:"for ChangeProp, ChangeDelProp, the previous value of the property"`
+	New   interface{} `desc:"for ChangeProp, the new value of the property"`
+	Name  string      `desc:"for ChangeField, the name of the field"`
+}
+
+// String returns a short, human-readable description of the change.
+func (ch *Change) String() string {
+	switch ch.Type {
+	case ChangeChildAdded, ChangeChildDeleted:
+		return fmt.Sprintf("%v %v %v at %v", ch.Type, ch.Node.Name(), ch.Kid.Name(), ch.Index)
+	case ChangeChildMoved:
+		return fmt.Sprintf("%v %v %v from %v to %v", ch.Type, ch.Node.Name(), ch.Kid.Name(), ch.From, ch.To)
+	case ChangeProp:
+		return fmt.Sprintf("%v %v %v: %v -> %v", ch.Type, ch.Node.Name(), ch.Key, ch.Old, ch.New)
+	case ChangeDelProp:
+		return fmt.Sprintf("%v %v %v: %v", ch.Type, ch.Node.Name(), ch.Key, ch.Old)
+	case ChangeField:
+		return fmt.Sprintf("%v %v %v", ch.Type, ch.Node.Name(), ch.Name)
+	}
+	return fmt.Sprintf("%v %v", ch.Type, ch.Node.Name())
+}
+
+// UpdatedData is the data sent with the NodeSignalUpdated signal on
+// connections made WithChanges: the Flags of the node, which accumulate
+// the types of changes made since the last update signal, and the Changes
+// recorded within the tree under the node in the update batch, in the
+// order they happened.  Both are a snapshot taken when the signal is sent,
+// so they remain valid for receivers that run later, e.g., on a Mailbox,
+// on a timed connection (see ConnectDebounced) or with EmitGo.  Other
+// connections just get the Flags, as an int64.
+type UpdatedData struct {
+	Flags   int64    `desc:"node Flags when the signal was sent"`
+	Changes []Change `desc:"changes within the tree in the update batch -- nil for UpdateSig"`
+}
+
+// String returns the Flags, as they were printed when they were the data
+func (ud UpdatedData) String() string {
+	return strconv.FormatInt(ud.Flags, 10)
+}
+
+// UpdatedFlags returns the node Flags from the data of a NodeSignalUpdated
+// signal, which is either an UpdatedData, on connections made WithChanges,
+// or just the flags as an int64 -- returns 0 otherwise.
+func UpdatedFlags(data interface{}) int64 {
+	switch d := data.(type) {
+	case UpdatedData:
+		return d.Flags
+	case int64:
+		return d
+	}
+	return 0
+}
+
+// mergeUpdated returns the data of two consecutive NodeSignalUpdated
+// signals merged into one, OR-ing the flags and appending the changes, or
+// the new data if they cannot be merged.
+func mergeUpdated(od, nd interface{}) interface{} {
+	switch nv := nd.(type) {
+	case UpdatedData:
+		if ov, ok := od.(UpdatedData); ok {
+			return UpdatedData{Flags: ov.Flags | nv.Flags, Changes: append(slices.Clip(ov.Changes), nv.Changes...)}
+		}
+	case int64:
+		if ov, ok := od.(int64); ok {
+			return ov | nv
+		}
+	}
+	return nd
+}
+
+// Changes returns a copy of the list of changes recorded within the tree
+// under this node during its last update batch (from UpdateStart to
+// UpdateEnd), in the order they happened.  The list is reset at the next
+// UpdateStart, and by UpdateSig -- receivers of the NodeSignalUpdated
+// signal should use the snapshot sent in its UpdatedData instead (see
+// WithChanges).
+func (n *Node) Changes() []Change {
+	defer n.rlockTree().RUnlock()
+	return slices.Clone(n.changes)
+}
+
+// updatedData returns the data for the NodeSignalUpdated signal of this
+// node, with a snapshot of its changes
+func (n *Node) updatedData() UpdatedData {
+	return UpdatedData{Flags: n.Flags(), Changes: slices.Clone(n.changes)}
+}
+
+// addChange records given change to this node in the change list of the
+// update batch that it is in, if any -- that is the highest updating node
+// above it.  Assumed to be under the tree lock.
+func (n *Node) addChange(ch Change) {
+	if !n.IsUpdating() {
+		return
+	}
+	ch.Node = n.This()
+	top := n
+	for par := n.Par; par != nil && par.This() != nil; par = par.AsNode().Par {
+		if !par.IsUpdating() || par.OnlySelfUpdate() {
+			break
+		}
+		top = par.AsNode()
+	}
+	top.changes = append(top.changes, ch)
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	parent := NodeEmbed{}
+	parent.InitName(&parent, "par1")
+	child1 := parent.AddNewChild(nil, "child1")
+	parent.AddNewChild(nil, "child2")
+
+	var res []string
+	var flags, old interface{}
+	parent.NodeSignal().Connect(&parent, func(r, s Ki, sig int64, d interface{}) {
+		flags = d
+		res = res[:0]
+		for _, ch := range d.(UpdatedData).Changes {
+			res = append(res, ch.String())
+		}
+	}).WithChanges()
+	// other connections still get just the flags
+	parent.NodeSignal().ConnectPri(child1, 0, func(r, s Ki, sig int64, d interface{}) {
+		old = d
+	})
+
+	parent.AddNewChild(nil, "child3")
+	if !reflect.DeepEqual(res, []string{"ChangeChildAdded par1 child3 at 2"}) {
+		t.Errorf("AddNewChild changes: %v", res)
+	}
+	if ud, ok := flags.(UpdatedData); !ok || ud.Flags != parent.Flags() || UpdatedFlags(flags) != ud.Flags {
+		t.Errorf("signal data should be UpdatedData with flags: %v", flags)
+	}
+	if f, ok := old.(int64); !ok || f != parent.Flags() || UpdatedFlags(old) != f {
+		t.Errorf("signal data without WithChanges should be int64 flags: %v", old)
+	}
+
+	child1.SetProp("p", 1)
+	updt := parent.UpdateStart()
+	parent.MoveChild(2, 0)
+	child1.SetProp("p", 2)
+	child1.DeleteProp("p")
+	child1.SetField("Mbr1", "new")
+	sub := child1.AddNewChild(nil, "sub")
+	parent.SwapChildren(1, 2)
+	child1.DeleteChild(sub, true)
+	parent.DeleteChildAtIndex(0, true)
+	parent.UpdateEnd(updt)
+	want := []string{
+		"ChangeChildMoved par1 child3 from 2 to 0",
+		"ChangeProp child1 p: 1 -> 2",
+		"ChangeDelProp child1 p: 2",
+		"ChangeField child1 Mbr1",
+		"ChangeChildAdded child1 sub at 0",
+		"ChangeChildMoved par1 child1 from 1 to 2",
+		"ChangeChildMoved par1 child2 from 2 to 1",
+		"ChangeChildDeleted child1 sub at 0",
+		"ChangeChildDeleted par1 child3 at 0",
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("batch changes:\n%v\nvs.\n%v", res, want)
+	}
+	if len(child1.Changes()) != 0 {
+		t.Errorf("changes should be recorded on batch node only: %v", child1.Changes())
+	}
+	chs := parent.Changes()
+	if chs[1].Node != child1 || chs[1].Old != 1 || chs[1].New != 2 || chs[4].Kid != sub {
+		t.Errorf("change details wrong: %+v", chs[1])
+	}
+
+	parent.UpdateSig()
+	if len(res) != 0 {
+		t.Errorf("UpdateSig should reset changes: %v", res)
+	}
+
+	// undo and redo report the changes they make
+	us := NewUndoStack(&parent, 0)
+	defer us.Close()
+	parent.DeleteChildren(true)
+	if !reflect.DeepEqual(res, []string{"ChangeChildrenDeleted par1"}) {
+		t.Errorf("DeleteChildren changes: %v", res)
+	}
+	us.Undo()
+	if !reflect.DeepEqual(res, []string{"ChangeChildAdded par1 child2 at 0", "ChangeChildAdded par1 child1 at 1"}) {
+		t.Errorf("undo changes: %v", res)
+	}
+}
+
+func TestChangesDeferred(t *testing.T) {
+	parent := NodeEmbed{}
+	parent.InitName(&parent, "par1")
+
+	// receivers running after the next batch has started still get their
+	// own batch of changes
+	mb := NewMailbox()
+	BindMailbox(&parent, mb)
+	defer BindMailbox(&parent, nil)
+	var res [][]string
+	parent.NodeSignal().Connect(&parent, func(r, s Ki, sig int64, d interface{}) {
+		var chs []string
+		for _, ch := range d.(UpdatedData).Changes {
+			chs = append(chs, ch.String())
+		}
+		res = append(res, chs)
+	}).WithChanges()
+	parent.AddNewChild(nil, "child1")
+	parent.SetField("Mbr1", "new")
+	updt := parent.UpdateStart()
+	parent.AddNewChild(nil, "child2")
+	mb.Drain()
+	parent.UpdateEnd(updt)
+	mb.Drain()
+	want := [][]string{
+		{"ChangeChildAdded par1 child1 at 0"},
+		{"ChangeField par1 Mbr1"},
+		{"ChangeChildAdded par1 child2 at 1"},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("deferred changes:\n%v\nvs.\n%v", res, want)
+	}
+}
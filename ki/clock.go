@@ -0,0 +1,140 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for the timed signal connections (see
+// ConnectDebounced, ConnectThrottled, ConnectCoalesced) -- SystemClock is
+// the real clock, and ManualClock is for deterministic tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// AfterFunc calls given function, on its own goroutine, after given
+	// duration, unless the returned timer is stopped first.
+	AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+// ClockTimer is a timer returned by Clock.AfterFunc.
+type ClockTimer interface {
+	// Stop prevents the timer from firing, returning false if it has
+	// already fired or been stopped.
+	Stop() bool
+}
+
+// SignalClock is the Clock used by the timed signal connections made
+// after it is set -- set it to a ManualClock for tests.
+var SignalClock Clock = SystemClock{}
+
+// SystemClock is the Clock using the real time package.
+type SystemClock struct{}
+
+func (sc SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (sc SystemClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
+
+// ManualClock is a Clock whose time only moves when Advance is called,
+// which calls the functions of all the timers that are then due, on the
+// calling goroutine -- this allows timing to be tested deterministically
+// without sleeping.
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+	seq    int
+}
+
+// manualTimer is a timer of a ManualClock
+type manualTimer struct {
+	mc  *ManualClock
+	at  time.Time
+	seq int // order of creation, for timers due at the same time
+	fun func()
+}
+
+// NewManualClock returns a new ManualClock starting at given time.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (mc *ManualClock) Now() time.Time {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.now
+}
+
+// AfterFunc adds a timer calling given function when the clock is
+// advanced by at least given duration from now.
+func (mc *ManualClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.seq++
+	mt := &manualTimer{mc: mc, at: mc.now.Add(d), seq: mc.seq, fun: f}
+	mc.timers = append(mc.timers, mt)
+	return mt
+}
+
+// Pending returns the number of timers that have not yet fired or been
+// stopped.
+func (mc *ManualClock) Pending() int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return len(mc.timers)
+}
+
+// Advance moves the clock forward by given duration, calling the
+// functions of the timers as they become due, in order of time, with the
+// clock set to the time each is due -- including any timers added by
+// those functions that become due within the duration.
+func (mc *ManualClock) Advance(d time.Duration) {
+	mc.mu.Lock()
+	end := mc.now.Add(d)
+	for {
+		sort.SliceStable(mc.timers, func(i, j int) bool {
+			ti, tj := mc.timers[i], mc.timers[j]
+			if ti.at.Equal(tj.at) {
+				return ti.seq < tj.seq
+			}
+			return ti.at.Before(tj.at)
+		})
+		if len(mc.timers) == 0 || mc.timers[0].at.After(end) {
+			break
+		}
+		mt := mc.timers[0]
+		mc.timers = mc.timers[1:]
+		if mt.at.After(mc.now) {
+			mc.now = mt.at
+		}
+		mc.mu.Unlock()
+		mt.fun()
+		mc.mu.Lock()
+	}
+	mc.now = end
+	mc.mu.Unlock()
+}
+
+// Stop removes the timer from its clock
+func (mt *manualTimer) Stop() bool {
+	mc := mt.mc
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for i, t := range mc.timers {
+		if t == mt {
+			mc.timers = append(mc.timers[:i], mc.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
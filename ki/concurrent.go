@@ -0,0 +1,353 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// TreeLock is a read / write lock owned by the root of a tree, which
+// makes the tree safe for concurrent use from multiple goroutines -- see
+// NewTreeLock.  By default trees are not locked, and must only be used
+// from one goroutine at a time.
+//
+// Once a tree is in concurrent mode, all nodes within it use the lock
+// automatically: the mutating Node methods (Add*, Insert*, Delete*,
+// SetChild, MoveChild, SwapChildren, SetNChildren, ConfigChildren,
+// SetName, UniquifyNames, SetProp, SetProps, DeleteProp, SetField etc)
+// take the write lock, and the accessing and traversal methods (Parent,
+// Root, Child*, NumChildren, Path*, FindPathUnique, FindPaths, Prop,
+// PropInherit, FuncUp*, FuncDown* etc) take the read lock -- the FuncDown
+// methods hold it for the entire traversal, so the function is always
+// called on a consistent tree.  An UpdateStart / UpdateEnd batch holds
+// the write lock from the outermost UpdateStart to the matching UpdateEnd,
+// so the whole batch is atomic with respect to other goroutines, and is
+// one undo transaction.  The goroutine running the batch is wired to its
+// operating system thread (runtime.LockOSThread) until UpdateEnd, and the
+// lock recognizes the Node methods it calls within the batch by the
+// thread id, so they can take the lock again -- UpdateEnd must be called
+// on the same goroutine as UpdateStart, and the batch must not wait on
+// other goroutines that use the tree.  On platforms without thread ids
+// (other than linux, darwin and windows), batches do not hold the lock,
+// and each method is only atomic on its own.
+//
+// The read lock can be nested, so reading the tree from within a FuncDown
+// function is fine, but outside of a batch the write lock cannot:
+// modifying the tree while holding the read lock (e.g., within a FuncDown
+// function), or calling any Node method of the tree while holding the
+// write lock taken by Lock, deadlocks -- do the modifications within a
+// batch, or collect the nodes to modify first and modify them after the
+// traversal.  Signals sent by the mutating methods (e.g.,
+// NodeSignalUpdated, NodeSignalDeleting) are sent after the write lock is
+// released, at the end of the batch for those within one, so receivers
+// can access and modify the tree.
+//
+// The pointers returned by Children() and Properties() are not protected
+// -- only access them while holding the lock (e.g., within a FuncDown
+// function, or explicitly via RLock / RUnlock).  Nodes that are deleted
+// leave the tree and its lock, and must not be used by other goroutines
+// after that.  Moving nodes between two trees takes both locks, and must
+// not be done concurrently in both directions.
+type TreeLock struct {
+	Root Ki `desc:"root of the tree being locked"`
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	readers int      // number of read locks held
+	writer  bool     // write lock is held
+	depth   int      // nesting of the write lock within an update batch
+	owner   uint64   // thread id of the goroutine running the update batch holding the write lock, 0 if none
+	after   []func() // functions to call after the write lock is released
+}
+
+// NewTreeLock puts the tree under given root into concurrent mode,
+// returning its new TreeLock, which is used by all nodes in the tree,
+// including those added later.  Any existing lock for the tree is
+// closed first.  Must be called before the tree is used concurrently.
+func NewTreeLock(root Ki) *TreeLock {
+	if old := TreeLockFor(root); old != nil {
+		old.Close()
+	}
+	tl := &TreeLock{Root: root}
+	tl.cond = sync.NewCond(&tl.mu)
+	tl.Lock()
+	setTreeLockDown(root, tl)
+	tl.Unlock()
+	return tl
+}
+
+// TreeLockFor returns the TreeLock for the tree containing given node,
+// or nil if the tree is not in concurrent mode.
+func TreeLockFor(k Ki) *TreeLock {
+	if k == nil || k.This() == nil {
+		return nil
+	}
+	return k.AsNode().treeLock()
+}
+
+// Close takes the tree out of concurrent mode -- it must no longer be
+// used concurrently after this.
+func (tl *TreeLock) Close() {
+	tl.Lock()
+	setTreeLockDown(tl.Root, nil)
+	tl.Unlock()
+}
+
+// Lock takes the write lock on the tree, waiting until no other read or
+// write lock is held -- only needed for direct access to Children() or
+// Properties() when modifying them, as the Node methods lock
+// automatically, and must not be called while holding the lock.  No
+// Node methods of the tree can be called until the matching Unlock --
+// except within an update batch, which holds the lock.  Does nothing if
+// tl is nil, so it is safe to call on the result of TreeLockFor.
+func (tl *TreeLock) Lock() {
+	if tl == nil {
+		return
+	}
+	tl.mu.Lock()
+	if tl.inBatch() {
+		tl.depth++
+		tl.mu.Unlock()
+		return
+	}
+	for tl.writer || tl.readers > 0 {
+		tl.cond.Wait()
+	}
+	tl.writer = true
+	tl.depth = 1
+	tl.mu.Unlock()
+}
+
+// Unlock releases the write lock taken by Lock, and then sends any
+// signals that were deferred while it was held.
+func (tl *TreeLock) Unlock() {
+	if tl == nil {
+		return
+	}
+	tl.mu.Lock()
+	tl.depth--
+	if tl.depth > 0 {
+		tl.mu.Unlock()
+		return
+	}
+	tl.writer = false
+	tl.owner = 0
+	after := tl.after
+	tl.after = nil
+	tl.cond.Broadcast()
+	tl.mu.Unlock()
+	for _, fun := range after {
+		fun()
+	}
+}
+
+// RLock takes the read lock on the tree -- only needed to keep the tree
+// stable across a sequence of operations, or for direct access to
+// Children() or Properties().  Read locks can be nested, and readers are
+// never blocked by waiting writers.  Does nothing if tl is nil.
+func (tl *TreeLock) RLock() {
+	if tl == nil {
+		return
+	}
+	tl.mu.Lock()
+	if tl.inBatch() {
+		tl.depth++
+		tl.mu.Unlock()
+		return
+	}
+	for tl.writer {
+		tl.cond.Wait()
+	}
+	tl.readers++
+	tl.mu.Unlock()
+}
+
+// RUnlock releases the read lock taken by RLock.
+func (tl *TreeLock) RUnlock() {
+	if tl == nil {
+		return
+	}
+	tl.mu.Lock()
+	if tl.writer { // nested within the update batch holding the write lock
+		tl.depth--
+		tl.mu.Unlock()
+		return
+	}
+	tl.readers--
+	if tl.readers == 0 {
+		tl.cond.Broadcast()
+	}
+	tl.mu.Unlock()
+}
+
+// inBatch returns true if the write lock is held by the update batch
+// running on the calling goroutine, which is wired to its thread -- under
+// the mu lock.  The thread id is only needed while a batch is running.
+func (tl *TreeLock) inBatch() bool {
+	return tl.owner != 0 && tl.owner == threadID()
+}
+
+// holdBatch keeps the write lock, which is held by the calling goroutine,
+// until the matching releaseBatch, for an update batch started by
+// UpdateStart -- the goroutine is wired to its thread until then, so that
+// the lock can recognize it.  Returns false if thread ids are not
+// available, so the lock is not kept.
+func (tl *TreeLock) holdBatch() bool {
+	runtime.LockOSThread()
+	tid := threadID()
+	if tid == 0 {
+		runtime.UnlockOSThread()
+		return false
+	}
+	tl.mu.Lock()
+	tl.owner = tid
+	tl.depth++
+	tl.mu.Unlock()
+	return true
+}
+
+// releaseBatch releases the write lock kept by holdBatch at the end of an
+// update batch.
+func (tl *TreeLock) releaseBatch() {
+	tl.Unlock()
+	runtime.UnlockOSThread()
+}
+
+// afterUnlock calls given function once the write lock, which is assumed
+// to be held, is released -- or right away if tl is nil -- used to send
+// signals from within the Locked methods, so receivers can use the tree.
+func (tl *TreeLock) afterUnlock(fun func()) {
+	if tl == nil {
+		fun()
+		return
+	}
+	tl.mu.Lock()
+	tl.after = append(tl.after, fun)
+	tl.mu.Unlock()
+}
+
+// setTreeLockDown sets the TreeLock for k and all nodes below it,
+// including Ki fields -- also caches the Ki field info on each node, so
+// that it is only read during concurrent traversal.
+func setTreeLockDown(k Ki, tl *TreeLock) {
+	walkDownLocked(k, func(k Ki) bool {
+		k.AsNode().setTreeLock(tl)
+		return Continue
+	})
+}
+
+// treeLock returns the TreeLock of the tree this node is in, nil if not
+// in concurrent mode.
+func (n *Node) treeLock() *TreeLock {
+	return (*TreeLock)(atomic.LoadPointer(&n.tlock))
+}
+
+func (n *Node) setTreeLock(tl *TreeLock) {
+	atomic.StorePointer(&n.tlock, unsafe.Pointer(tl))
+}
+
+// lockTree takes the write lock of the tree this node is in, returning
+// the lock for the matching Unlock -- nil if not in concurrent mode.
+// Usage: defer n.lockTree().Unlock()
+func (n *Node) lockTree() *TreeLock {
+	for {
+		tl := n.treeLock()
+		tl.Lock()
+		if n.treeLock() == tl { // not moved to another tree while waiting
+			return tl
+		}
+		tl.Unlock()
+	}
+}
+
+// rlockTree takes the read lock of the tree this node is in, returning
+// the lock for the matching RUnlock -- nil if not in concurrent mode.
+// Usage: defer n.rlockTree().RUnlock()
+func (n *Node) rlockTree() *TreeLock {
+	for {
+		tl := n.treeLock()
+		tl.RLock()
+		if n.treeLock() == tl {
+			return tl
+		}
+		tl.RUnlock()
+	}
+}
+
+// lockOther takes the write lock of the tree given node is in, if that is
+// not the tree of tl, whose write lock is held -- used when moving nodes
+// between trees.  Returns the lock for the matching Unlock, nil if none
+// was taken.
+func lockOther(k Ki, tl *TreeLock) *TreeLock {
+	if k == nil {
+		return nil
+	}
+	n := k.AsNode()
+	for {
+		otl := n.treeLock()
+		if otl == tl {
+			return nil
+		}
+		otl.Lock()
+		if n.treeLock() == otl {
+			return otl
+		}
+		otl.Unlock()
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  Locked variants
+
+// The functions and methods named *Locked access the tree directly,
+// without taking the tree lock, for use by code that already holds the
+// write lock (or the read lock, for those that only read) -- they must
+// only call other Locked methods, as the lock is only reentrant within an
+// update batch.  They are equivalent to the corresponding exported methods
+// when the tree is not in concurrent mode.
+
+// rootLocked returns the root of the tree containing given node.
+func rootLocked(k Ki) Ki {
+	for {
+		par := k.AsNode().Par
+		if par == nil || par.This() == nil {
+			return k.This()
+		}
+		k = par
+	}
+}
+
+// lineageLocked returns given node followed by all of its parents, up to
+// the root.
+func lineageLocked(k Ki) []Ki {
+	ks := []Ki{k.This()}
+	for par := k.AsNode().Par; par != nil && par.This() != nil; par = par.AsNode().Par {
+		ks = append(ks, par.This())
+	}
+	return ks
+}
+
+// walkDownLocked calls fun on given node and all the nodes below it, in
+// depth-first order, with Ki fields before children, as in
+// FuncDownMeFirst -- if fun returns false the nodes below that node are
+// skipped.
+func walkDownLocked(k Ki, fun func(k Ki) bool) {
+	if k == nil || k.This() == nil || !fun(k.This()) {
+		return
+	}
+	n := k.AsNode()
+	if n.HasKiFields() {
+		n.FuncFields(0, nil, func(fk Ki, level int, d interface{}) bool {
+			walkDownLocked(fk, fun)
+			return true
+		})
+	}
+	for _, kid := range n.Kids {
+		walkDownLocked(kid, fun)
+	}
+}
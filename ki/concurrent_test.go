@@ -0,0 +1,239 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTreeLockStress(t *testing.T) {
+	defer func(trace bool) { SignalTrace = trace }(SignalTrace)
+	SignalTrace = false // tracing is not thread-safe
+	root := NodeField{}
+	root.InitName(&root, "root")
+	for i := 0; i < 5; i++ {
+		root.AddNewChild(KiT_NodeEmbed, fmt.Sprintf("c%d", i))
+	}
+	tl := NewTreeLock(root.This())
+	if TreeLockFor(root.Child(0)) != tl || TreeLockFor(&root.Field1) != tl {
+		t.Fatalf("TreeLock not set on all nodes")
+	}
+
+	const nwrite, nread, iters = 4, 4, 200
+	var wg sync.WaitGroup
+	for w := 0; w < nwrite; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < iters; i++ {
+				nm := fmt.Sprintf("c%d", rnd.Intn(10))
+				switch rnd.Intn(7) {
+				case 0:
+					root.AddNewChild(KiT_NodeEmbed, nm)
+				case 1:
+					root.DeleteChildByName(nm, true)
+				case 2:
+					root.SetProp("p", i)
+				case 3:
+					root.MoveChild(0, 1)
+				case 4:
+					root.Field1.SetProp("f", w)
+				case 5:
+					// k may be deleted by another writer before DeleteChild
+					if k := root.ChildByName(nm, 0); k != nil {
+						root.DeleteChild(k, rnd.Intn(2) == 0)
+					}
+				default:
+					// batch of reads and writes that must see a consistent tree
+					updt := root.UpdateStart()
+					if k := root.ChildByName(nm, 0); k != nil {
+						gk := k.AddNewChild(KiT_NodeEmbed, "gc")
+						gk.SetProp("p", w)
+						if k.NumChildren() > 3 {
+							k.DeleteChildAtIndex(0, true)
+						}
+						if gk.Parent() != k || k.Parent() != root.This() {
+							t.Errorf("inconsistent tree within update batch")
+						}
+					}
+					root.UpdateEnd(updt)
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < nread; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := 0; i < iters; i++ {
+				root.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+					k.PropInherit("p", true, false)
+					if k != root.This() && k.Parent() == nil {
+						t.Errorf("node without parent in tree: %v", k.Name())
+					}
+					return true
+				})
+				root.FuncDownBreadthFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+					return level < 1
+				})
+				if k := root.ChildByName(fmt.Sprintf("c%d", i%10), 0); k != nil && k.Name() == "" {
+					t.Errorf("child without name")
+				}
+				root.FindPathUnique("/root/c1/gc")
+				root.FindPaths("/root/**")
+				root.NumChildren()
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	root.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		if TreeLockFor(k) != tl {
+			t.Errorf("node %v not using TreeLock", k.PathUnique())
+		}
+		for _, kid := range *k.Children() {
+			if kid.Parent() != k {
+				t.Errorf("wrong parent for %v", kid.PathUnique())
+			}
+		}
+		return true
+	})
+	if root.IsUpdating() {
+		t.Errorf("root still updating")
+	}
+}
+
+func TestTreeLockBatchStress(t *testing.T) {
+	defer func(trace bool) { SignalTrace = trace }(SignalTrace)
+	SignalTrace = false // tracing is not thread-safe
+	root := NodeEmbed{}
+	root.InitName(&root, "root")
+	root.SetProp("n", 0)
+	NewTreeLock(root.This())
+
+	// each batch reads and then writes the counter, and adds and removes
+	// a child, which no other goroutine may see in between
+	const ngo, iters = 8, 200
+	var wg sync.WaitGroup
+	for g := 0; g < ngo; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iters; i++ {
+				updt := root.UpdateStart()
+				n := root.Prop("n").(int)
+				k := root.AddNewChild(KiT_NodeEmbed, "tmp")
+				runtime.Gosched()
+				root.SetProp("n", n+1)
+				root.DeleteChild(k, true)
+				root.UpdateEnd(updt)
+			}
+		}()
+	}
+	for r := 0; r < 2; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iters; i++ {
+				if root.NumChildren() != 0 {
+					t.Errorf("update batch not atomic: child visible")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := root.Prop("n"); n != ngo*iters {
+		t.Errorf("update batch not atomic: got %v increments, expected %v", n, ngo*iters)
+	}
+}
+
+func TestTreeLock(t *testing.T) {
+	root := NodeEmbed{}
+	root.InitName(&root, "root")
+	c1 := root.AddNewChild(KiT_NodeEmbed, "c1")
+	tl := NewTreeLock(root.This())
+
+	// read lock can be nested
+	tl.RLock()
+	tl.RLock()
+	if root.Child(0) != c1 {
+		t.Errorf("read within read lock")
+	}
+	tl.RUnlock()
+	tl.RUnlock()
+	c2 := root.AddNewChild(KiT_NodeEmbed, "c2")
+	if TreeLockFor(c2) != tl {
+		t.Errorf("added child not using TreeLock")
+	}
+
+	// signals are sent after the write lock is released, so receivers can
+	// modify the tree
+	conn := root.NodeSignal().Connect(root.This(), func(recv, send Ki, sig int64, data interface{}) {
+		if sig == int64(NodeSignalUpdated) && recv.Prop("r") == nil {
+			recv.SetProp("r", recv.NumChildren())
+		}
+	})
+	c3 := root.AddNewChild(KiT_NodeEmbed, "c3")
+	conn.Disconnect()
+	if root.Prop("r") != 3 {
+		t.Errorf("receiver did not modify tree: %v", root.Prop("r"))
+	}
+	c3.Delete(true)
+
+	// update batch holds the write lock until UpdateEnd, and can read and
+	// modify the tree within it, including within FuncDown
+	updt := root.UpdateStart()
+	root.SetProp("x", 1)
+	done := make(chan struct{})
+	go func() {
+		root.SetProp("x", 2)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-done:
+		t.Errorf("write not blocked by update batch")
+	default:
+	}
+	tl.RLock()
+	c4 := root.AddNewChild(KiT_NodeEmbed, "c4")
+	tl.RUnlock()
+	root.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		k.SetProp("y", level)
+		return true
+	})
+	if root.Prop("x") != 1 || c4.Prop("y") != 1 {
+		t.Errorf("prop changed within update batch")
+	}
+	c4.Delete(true)
+	root.UpdateEnd(updt)
+	<-done
+	if root.Prop("x") != 2 {
+		t.Errorf("prop not set after update batch")
+	}
+
+	// deleted nodes leave the lock, moved nodes join the new one
+	c2.Delete(false)
+	if TreeLockFor(c2) != nil {
+		t.Errorf("deleted node still using TreeLock")
+	}
+	other := NodeEmbed{}
+	other.InitName(&other, "other")
+	otl := NewTreeLock(other.This())
+	other.AddChild(c1)
+	if TreeLockFor(c1) != otl || root.NumChildren() != 0 {
+		t.Errorf("moved node not using new TreeLock")
+	}
+	otl.Close()
+	if TreeLockFor(c1) != nil || TreeLockFor(other.This()) != nil {
+		t.Errorf("closed TreeLock still in use")
+	}
+}
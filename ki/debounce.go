@@ -0,0 +1,180 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnectDebounced adds a new connection of a receiver and function to the
+// signal, at priority 0 like ConnectPri, which only calls the function
+// once the signal has not been sent for the given duration, with the last
+// signal sent -- e.g., to save a document only once the user pauses
+// editing.  Consecutive NodeSignalUpdated signals on the NodeSignal of the
+// same sender are merged by OR-ing their flags, and appending their
+// changes if the connection is made WithChanges (see UpdatedData), so no
+// changes are missed.
+// The timing uses the SignalClock, and the call is made on the Mailbox of
+// the receiver if it is bound to one, and otherwise on the goroutine of
+// the clock.  Returns the handle of the new connection -- any pending call
+// is dropped when it is disconnected.
+func (s *Signal) ConnectDebounced(recv Ki, d time.Duration, fun RecvFunc) *SignalConn {
+	return s.connectTimed(recv, d, fun, sigDebounce)
+}
+
+// ConnectThrottled adds a new connection of a receiver and function to the
+// signal, like ConnectDebounced, which calls the function at most once per
+// given duration: a signal is passed on immediately if the function has
+// not been called within the duration, and otherwise the last signal is
+// passed on at the end of the duration (merging NodeSignalUpdated data as
+// in ConnectDebounced) -- e.g., to re-render at a limited rate.
+func (s *Signal) ConnectThrottled(recv Ki, d time.Duration, fun RecvFunc) *SignalConn {
+	return s.connectTimed(recv, d, fun, sigThrottle)
+}
+
+// ConnectCoalesced adds a new connection of a receiver and function to the
+// signal, like ConnectDebounced, which collects all the signals sent
+// within the given duration after the first one, and then passes them on
+// in order, with consecutive signals of the same type from the same sender
+// coalesced into one: NodeSignalUpdated signals on the NodeSignal merge
+// their data as in ConnectDebounced, and otherwise the last data is used.
+func (s *Signal) ConnectCoalesced(recv Ki, d time.Duration, fun RecvFunc) *SignalConn {
+	return s.connectTimed(recv, d, fun, sigCoalesce)
+}
+
+// sigTiming is the type of timing of a timed connection
+type sigTiming int
+
+const (
+	sigDebounce sigTiming = iota
+	sigThrottle
+	sigCoalesce
+)
+
+// sigCall is one signal pending delivery on a timed connection
+type sigCall struct {
+	send Ki
+	sig  int64
+	data interface{}
+}
+
+// sigTimer implements the delayed delivery of signals on a connection made
+// by ConnectDebounced, ConnectThrottled or ConnectCoalesced
+type sigTimer struct {
+	timing sigTiming
+	d      time.Duration
+	clock  Clock
+	fun    RecvFunc
+	signal *Signal
+	sc     *SignalConn
+
+	mu    sync.Mutex
+	pend  []sigCall
+	timer ClockTimer
+	gen   int       // generation of timer, so a stopped timer that fires anyway is ignored
+	last  time.Time // time of last call, for throttling
+}
+
+// connectTimed adds a connection calling fun with given timing
+func (s *Signal) connectTimed(recv Ki, d time.Duration, fun RecvFunc, timing sigTiming) *SignalConn {
+	st := &sigTimer{timing: timing, d: d, clock: SignalClock, fun: fun, signal: s}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sc = s.ConnectPri(recv, 0, st.recv)
+	return st.sc
+}
+
+// recv is the receiving function of the connection, which schedules the
+// call to fun.
+func (st *sigTimer) recv(recv, send Ki, sig int64, data interface{}) {
+	st.mu.Lock()
+	switch st.timing {
+	case sigDebounce:
+		st.add(sigCall{send, sig, data}, true)
+		if st.timer != nil {
+			st.timer.Stop()
+		}
+		st.start(st.d)
+	case sigThrottle:
+		now := st.clock.Now()
+		if st.timer == nil && (st.last.IsZero() || now.Sub(st.last) >= st.d) {
+			st.last = now
+			st.mu.Unlock()
+			st.fun(recv, send, sig, data)
+			return
+		}
+		st.add(sigCall{send, sig, data}, true)
+		if st.timer == nil {
+			st.start(st.d - now.Sub(st.last))
+		}
+	case sigCoalesce:
+		st.add(sigCall{send, sig, data}, false)
+		if st.timer == nil {
+			st.start(st.d)
+		}
+	}
+	st.mu.Unlock()
+}
+
+// add adds a signal to the pending list, merging it into the last one if
+// it is the same type from the same sender, or always replacing the last
+// one if only one is kept.  Assumed to be under the lock.
+func (st *sigTimer) add(call sigCall, one bool) {
+	n := len(st.pend)
+	if n == 0 {
+		st.pend = append(st.pend, call)
+		return
+	}
+	lst := &st.pend[n-1]
+	if lst.send == call.send && lst.sig == call.sig && call.sig == int64(NodeSignalUpdated) && call.send.NodeSignal() == st.signal {
+		call.data = mergeUpdated(lst.data, call.data)
+	}
+	if one || (lst.send == call.send && lst.sig == call.sig) {
+		*lst = call
+		return
+	}
+	st.pend = append(st.pend, call)
+}
+
+// start starts a new timer for given duration, assumed to be under the
+// lock
+func (st *sigTimer) start(d time.Duration) {
+	st.gen++
+	gen := st.gen
+	st.timer = st.clock.AfterFunc(d, func() { st.fire(gen) })
+}
+
+// fire is called by the timer of given generation to pass on the pending
+// signals
+func (st *sigTimer) fire(gen int) {
+	st.mu.Lock()
+	if gen != st.gen {
+		st.mu.Unlock()
+		return
+	}
+	pend, sc := st.pend, st.sc
+	st.pend = nil
+	st.timer = nil
+	st.last = st.clock.Now()
+	st.mu.Unlock()
+	if len(pend) == 0 {
+		return
+	}
+	recv := sc.Recv
+	call := func() {
+		for _, c := range pend {
+			if !sc.IsConnected() || recv.IsDestroyed() {
+				return
+			}
+			st.fun(recv, c.send, c.sig, c.data)
+		}
+	}
+	if mb := MailboxFor(recv); mb != nil {
+		mb.Post(call)
+		return
+	}
+	call()
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSignalTimed(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	defer func(sc Clock) { SignalClock = sc }(SignalClock)
+	SignalClock = clock
+
+	parent := TestNode{}
+	parent.InitName(&parent, "par1")
+	deb := parent.AddNewChild(nil, "deb")
+	thr := parent.AddNewChild(nil, "thr")
+	coa := parent.AddNewChild(nil, "coa")
+
+	var res []string
+	recv := func(receiver, sender Ki, sig int64, data interface{}) {
+		res = append(res, fmt.Sprintf("%v %v %v", receiver.Name(), sig, data))
+	}
+	check := func(step string, trg ...string) {
+		t.Helper()
+		if len(res) == 0 && len(trg) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(res, trg) {
+			t.Errorf("%v: results: %v != target: %v", step, res, trg)
+		}
+		res = res[:0]
+	}
+
+	dc := parent.sig1.ConnectDebounced(deb, 10*time.Millisecond, recv)
+	parent.sig1.ConnectThrottled(thr, 10*time.Millisecond, recv)
+	parent.sig1.ConnectCoalesced(coa, 10*time.Millisecond, recv)
+
+	parent.sig1.Emit(&parent, 1, "a")
+	check("first", "thr 1 a")
+	clock.Advance(4 * time.Millisecond)
+	parent.sig1.Emit(&parent, 1, "b")
+	parent.sig1.Emit(&parent, 2, "c")
+	check("within")
+	clock.Advance(6 * time.Millisecond) // 10: throttle and coalesce due
+	check("at 10", "coa 1 b", "coa 2 c", "thr 2 c")
+	clock.Advance(3 * time.Millisecond)
+	check("at 13")
+	clock.Advance(time.Millisecond) // 14: debounce due
+	check("at 14", "deb 2 c")
+	clock.Advance(20 * time.Millisecond)
+	check("idle")
+	if clock.Pending() != 0 {
+		t.Errorf("timers pending: %v", clock.Pending())
+	}
+
+	// debounce resets the timer at each signal
+	for i := 0; i < 5; i++ {
+		parent.sig1.Emit(&parent, 3, i)
+		clock.Advance(5 * time.Millisecond)
+	}
+	check("storm", "thr 3 0", "coa 3 1", "thr 3 1", "thr 3 3", "coa 3 3")
+	clock.Advance(5 * time.Millisecond)
+	check("storm end", "deb 3 4", "thr 3 4", "coa 3 4")
+
+	// disconnecting drops pending calls
+	parent.sig1.Emit(&parent, 4, nil)
+	dc.Disconnect()
+	clock.Advance(time.Second)
+	check("disconnected", "thr 4 <nil>", "coa 4 <nil>")
+}
+
+func TestSignalCoalescedUpdates(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	defer func(sc Clock) { SignalClock = sc }(SignalClock)
+	SignalClock = clock
+
+	parent := NodeEmbed{}
+	parent.InitName(&parent, "par1")
+	child := parent.AddNewChild(nil, "child1")
+
+	var sigs []int64
+	var flags []int64
+	parent.NodeSignal().ConnectCoalesced(child, time.Millisecond, func(receiver, sender Ki, sig int64, data interface{}) {
+		sigs = append(sigs, sig)
+		flags = append(flags, data.(int64))
+	})
+	parent.NodeSignal().Emit(&parent, int64(NodeSignalUpdated), int64(1))
+	parent.NodeSignal().Emit(&parent, int64(NodeSignalUpdated), int64(4))
+	parent.NodeSignal().Emit(&parent, int64(NodeSignalDeleting), int64(0))
+	parent.NodeSignal().Emit(&parent, int64(NodeSignalUpdated), int64(8))
+	parent.NodeSignal().Emit(&parent, int64(NodeSignalUpdated), int64(2))
+	clock.Advance(time.Millisecond)
+	trg := []int64{int64(NodeSignalUpdated), int64(NodeSignalDeleting), int64(NodeSignalUpdated)}
+	if !reflect.DeepEqual(sigs, trg) || !reflect.DeepEqual(flags, []int64{5, 0, 10}) {
+		t.Errorf("coalesced updates: %v %v", sigs, flags)
+	}
+
+	// delivered on the Mailbox of the receiver
+	mb := NewMailbox()
+	BindMailbox(child, mb)
+	defer BindMailbox(child, nil)
+	sigs, flags = nil, nil
+	parent.NodeSignal().Emit(&parent, int64(NodeSignalUpdated), int64(1))
+	mb.Drain()
+	clock.Advance(time.Millisecond)
+	if len(sigs) != 0 || mb.Drain() != 1 || !reflect.DeepEqual(flags, []int64{1}) {
+		t.Errorf("coalesced updates on mailbox: %v", flags)
+	}
+}
+
+func TestDebounceChanges(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	defer func(sc Clock) { SignalClock = sc }(SignalClock)
+	SignalClock = clock
+
+	parent := NodeEmbed{}
+	parent.InitName(&parent, "par1")
+	child := parent.AddNewChild(nil, "child1")
+
+	var res []UpdatedData
+	parent.NodeSignal().ConnectDebounced(child, time.Millisecond, func(receiver, sender Ki, sig int64, data interface{}) {
+		res = append(res, data.(UpdatedData))
+	}).WithChanges()
+	parent.SetField("Mbr1", "new")
+	parent.AddNewChild(nil, "child2")
+	clock.Advance(time.Millisecond)
+	if len(res) != 1 || len(res[0].Changes) != 2 || res[0].Changes[0].Type != ChangeField || res[0].Changes[1].Type != ChangeChildAdded {
+		t.Fatalf("debounced changes not merged: %v", res)
+	}
+	if res[0].Flags&(1<<uint(ChildAdded)) == 0 {
+		t.Errorf("debounced flags not merged: %v", res[0].Flags)
+	}
+}
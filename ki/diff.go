@@ -0,0 +1,419 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/goki/ki/kit"
+)
+
+// EditOps are the different types of operations in an EditScript, as
+// generated by Diff and applied by ApplyPatch.
+type EditOps int32
+
+const (
+	// EditAdd adds a new child, of registered type Type, at Index, whose
+	// full state (including its own children) is the JSON encoded Value.
+	EditAdd EditOps = iota
+
+	// EditDelete deletes (and destroys) the child named Name.
+	EditDelete
+
+	// EditMove moves the child named Name to Index.
+	EditMove
+
+	// EditRename renames the node at Path to NewName, with unique name
+	// NewUnique.
+	EditRename
+
+	// EditField sets the field Name to the JSON encoded Value.
+	EditField
+
+	// EditProp sets the property Name to the value in the JSON encoded
+	// Props map in Value, which preserves the type of the value.
+	EditProp
+
+	// EditDelProp deletes the property Name.
+	EditDelProp
+
+	EditOpsN
+)
+
+//go:generate stringer -type=EditOps
+
+var KiT_EditOps = kit.Enums.AddEnum(EditOpsN, kit.NotBitFlag, nil)
+
+func (ev EditOps) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *EditOps) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+// Edit is one operation in an EditScript.  Paths are unique paths relative
+// to the root passed to Diff / ApplyPatch (which is the empty path), with
+// children separated by / and Ki fields by . -- e.g., "kid1/sub.Field1".
+type Edit struct {
+	Op        EditOps         `desc:"type of edit operation"`
+	Path      string          `desc:"unique path of the node that the edit applies to -- for EditAdd, EditDelete, EditMove this is the parent of the child being edited"`
+	Name      string          `json:",omitempty" desc:"unique name of the child for EditAdd, EditDelete, EditMove -- field name for EditField -- property key for EditProp, EditDelProp"`
+	NewName   string          `json:",omitempty" desc:"for EditRename, the new Name"`
+	NewUnique string          `json:",omitempty" desc:"for EditRename, the new UniqueName"`
+	Type      string          `json:",omitempty" desc:"for EditAdd, the kit.Types registered name of the type of child to add"`
+	Index     int             `json:",omitempty" desc:"for EditAdd and EditMove, the index of the child after the edit"`
+	Value     json.RawMessage `json:",omitempty" desc:"for EditAdd, the JSON encoding of the new child -- for EditField the JSON encoding of the new field value -- for EditProp the JSON encoding of a Props map with the new property"`
+}
+
+// EditScript is a list of Edit operations that transform one tree into
+// another, in order -- it can be saved and loaded as JSON.
+type EditScript []Edit
+
+// String returns a short, human-readable description of the edit.
+func (ed *Edit) String() string {
+	switch ed.Op {
+	case EditAdd:
+		return fmt.Sprintf("%v %v %v [%v] at %v", ed.Op, ed.Path, ed.Name, ed.Type, ed.Index)
+	case EditMove:
+		return fmt.Sprintf("%v %v %v to %v", ed.Op, ed.Path, ed.Name, ed.Index)
+	case EditRename:
+		return fmt.Sprintf("%v %v to %v", ed.Op, ed.Path, ed.NewName)
+	case EditField, EditProp:
+		return fmt.Sprintf("%v %v %v = %v", ed.Op, ed.Path, ed.Name, string(ed.Value))
+	}
+	return fmt.Sprintf("%v %v %v", ed.Op, ed.Path, ed.Name)
+}
+
+// String returns the edits one per line.
+func (es EditScript) String() string {
+	var sb strings.Builder
+	for i := range es {
+		sb.WriteString(es[i].String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Diff returns the EditScript that transforms tree a into tree b, covering
+// children added, removed, moved and renamed, fields changed (all exported,
+// JSON-saved fields other than those of Node itself, compared by their
+// JSON encoding) and Props changed.  Ki fields are compared recursively.
+//
+// Children are matched by UniqueName and type, as in Slice.Config -- an
+// unmatched child of a is considered renamed if the unmatched child of b at
+// the same index has the same type, otherwise children of a that are not
+// matched are deleted and those of b added.  A child whose type changes is
+// deleted and re-added.  The root nodes are assumed to correspond, and
+// an error is returned if they are not of the same type, as the root
+// cannot be replaced by an edit, or if a field or child cannot be encoded.
+func Diff(a, b Ki) (EditScript, error) {
+	if a.Type() != b.Type() {
+		return nil, fmt.Errorf("ki.Diff: root types differ: %v vs. %v", a.Type(), b.Type())
+	}
+	var es EditScript
+	if a.Name() != b.Name() || a.UniqueName() != b.UniqueName() {
+		es = append(es, Edit{Op: EditRename, NewName: b.Name(), NewUnique: b.UniqueName()})
+	}
+	if err := es.diffNode(a, b, ""); err != nil {
+		return nil, fmt.Errorf("ki.Diff: %v", err)
+	}
+	return es, nil
+}
+
+// ApplyPatch applies the edit script to the tree at root, within one
+// UpdateStart / UpdateEnd block, so a single update signal is sent.
+// Edits are applied with minimal changes to the existing nodes, using
+// the standard Ki methods (and thus are recorded by an UndoStack if active).
+// Stops and returns an error at the first edit that cannot be applied.
+func ApplyPatch(root Ki, script EditScript) error {
+	updt := root.UpdateStart()
+	defer root.UpdateEnd(updt)
+	for i := range script {
+		if err := script[i].Apply(root); err != nil {
+			return fmt.Errorf("ki.ApplyPatch: edit %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Apply applies the edit to the tree at given root.
+func (ed *Edit) Apply(root Ki) error {
+	k, err := editPathNode(root, ed.Path)
+	if err != nil {
+		return err
+	}
+	switch ed.Op {
+	case EditAdd:
+		typ := kit.Types.Type(ed.Type)
+		if typ == nil {
+			return fmt.Errorf("%v: type %v not registered in kit.Types", ed, ed.Type)
+		}
+		if _, ok := k.Children().IndexByUniqueName(ed.Name, 0); ok {
+			return fmt.Errorf("%v: child already exists", ed)
+		}
+		kid := k.InsertNewChildFast(typ, ed.Index, ed.Name)
+		if err := json.Unmarshal(ed.Value, kid); err != nil {
+			return fmt.Errorf("%v: %v", ed, err)
+		}
+		kid.SetUniqueName(ed.Name)
+		kid.UnmarshalPost()
+	case EditDelete:
+		idx, ok := k.Children().IndexByUniqueName(ed.Name, 0)
+		if !ok {
+			return fmt.Errorf("%v: child not found", ed)
+		}
+		return k.DeleteChildAtIndex(idx, true)
+	case EditMove:
+		idx, ok := k.Children().IndexByUniqueName(ed.Name, 0)
+		if !ok {
+			return fmt.Errorf("%v: child not found", ed)
+		}
+		if idx != ed.Index {
+			return k.MoveChild(idx, ed.Index)
+		}
+	case EditRename:
+		k.SetName(ed.NewName)
+		k.SetUniqueName(ed.NewUnique)
+	case EditField:
+		fv := kit.FlatFieldValueByName(k, ed.Name)
+		if !fv.IsValid() {
+			return fmt.Errorf("%v: field not found", ed)
+		}
+		if us := undoRecorder(k); us != nil {
+			us.recordField(k, ed.Name, fv)
+		}
+		if err := json.Unmarshal(ed.Value, kit.PtrValue(fv).Interface()); err != nil {
+			return fmt.Errorf("%v: %v", ed, err)
+		}
+		k.SetFlag(int(FieldUpdated))
+		k.AsNode().addChange(Change{Type: ChangeField, Name: ed.Name})
+	case EditProp:
+		var pv Props
+		if err := json.Unmarshal(ed.Value, &pv); err != nil {
+			return fmt.Errorf("%v: %v", ed, err)
+		}
+		val, ok := pv[ed.Name]
+		if !ok {
+			return fmt.Errorf("%v: property not in value", ed)
+		}
+		k.SetProp(ed.Name, val)
+		k.SetFlag(int(PropUpdated))
+	case EditDelProp:
+		k.DeleteProp(ed.Name)
+		k.SetFlag(int(PropUpdated))
+	default:
+		return fmt.Errorf("invalid edit op: %v", ed.Op)
+	}
+	return nil
+}
+
+// editPathNode returns the node at given edit path relative to root.
+func editPathNode(root Ki, path string) (Ki, error) {
+	curn := root
+	if path == "" {
+		return curn, nil
+	}
+	for _, pe := range strings.Split(path, "/") {
+		fels := strings.Split(pe, ".")
+		if fels[0] != "" {
+			idx, ok := findPathChild(curn, fels[0])
+			if !ok {
+				return nil, fmt.Errorf("ki %v: element at path: %v not found", root.Name(), path)
+			}
+			curn = (*(curn.Children()))[idx]
+		}
+		for _, fe := range fels[1:] {
+			fk := curn.KiFieldByName(fe)
+			if fk == nil {
+				return nil, fmt.Errorf("ki %v: field at path: %v not found", root.Name(), path)
+			}
+			curn = fk
+		}
+	}
+	return curn, nil
+}
+
+// editChildPath returns the edit path for the child with given unique name.
+func editChildPath(path, child string) string {
+	if path == "" {
+		return child
+	}
+	return path + "/" + child
+}
+
+// diffNode adds the edits for the fields, props, children and Ki fields
+// of a vs. b, which are of the same type.
+func (es *EditScript) diffNode(a, b Ki, path string) error {
+	if err := es.diffFields(a, b, path); err != nil {
+		return err
+	}
+	es.diffProps(a, b, path)
+	if err := es.diffKids(a, b, path); err != nil {
+		return err
+	}
+	nf := a.NumKiFields()
+	for i := 0; i < nf; i++ {
+		af := a.KiField(i)
+		if err := es.diffNode(af, b.KiField(i), path+"."+af.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeFieldNames are the names of the fields of Node, which are not diffed
+var nodeFieldNames = func() map[string]bool {
+	nf := make(map[string]bool)
+	for _, f := range kit.FlatFields(KiT_Node) {
+		nf[f.Name] = true
+	}
+	return nf
+}()
+
+func (es *EditScript) diffFields(a, b Ki, path string) error {
+	for _, f := range kit.FlatFields(a.Type()) {
+		if f.PkgPath != "" || nodeFieldNames[f.Name] || f.Tag.Get("json") == "-" {
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct && kit.EmbedImplements(f.Type, KiType) {
+			continue // Ki fields are diffed as nodes
+		}
+		av := kit.FlatFieldValueByName(a, f.Name)
+		bv := kit.FlatFieldValueByName(b, f.Name)
+		ab, err := json.Marshal(av.Interface())
+		if err != nil {
+			return fmt.Errorf("field %v at path: %q: %v", f.Name, path, err)
+		}
+		bb, err := json.Marshal(bv.Interface())
+		if err != nil {
+			return fmt.Errorf("field %v at path: %q: %v", f.Name, path, err)
+		}
+		if !bytes.Equal(ab, bb) {
+			*es = append(*es, Edit{Op: EditField, Path: path, Name: f.Name, Value: bb})
+		}
+	}
+	return nil
+}
+
+// propValueJSON returns the JSON encoding of a Props map with the single
+// given property, which records the type of the value.
+func propValueJSON(key string, val interface{}) []byte {
+	b, err := json.Marshal(Props{key: val})
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func (es *EditScript) diffProps(a, b Ki, path string) {
+	ap := *a.Properties()
+	bp := *b.Properties()
+	dels := make([]string, 0)
+	for key := range ap {
+		if _, has := bp[key]; !has {
+			dels = append(dels, key)
+		}
+	}
+	sort.Strings(dels)
+	for _, key := range dels {
+		*es = append(*es, Edit{Op: EditDelProp, Path: path, Name: key})
+	}
+	keys := make([]string, 0, len(bp))
+	for key := range bp {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		bb := propValueJSON(key, bp[key])
+		if av, has := ap[key]; has && bytes.Equal(propValueJSON(key, av), bb) {
+			continue
+		}
+		*es = append(*es, Edit{Op: EditProp, Path: path, Name: key, Value: bb})
+	}
+}
+
+func (es *EditScript) diffKids(a, b Ki, path string) error {
+	akids := *a.Children()
+	bkids := *b.Children()
+	match := make(map[Ki]Ki, len(bkids)) // b kid -> a kid
+	used := make([]bool, len(akids))
+	anm := akids.UniqueNameToIndexMap()
+	for _, bk := range bkids {
+		if ai, ok := anm[bk.UniqueName()]; ok && akids[ai].Type() == bk.Type() {
+			match[bk] = akids[ai]
+			used[ai] = true
+		}
+	}
+	var renms []Edit
+	for bi, bk := range bkids {
+		if _, ok := match[bk]; ok || bi >= len(akids) || used[bi] {
+			continue
+		}
+		ak := akids[bi]
+		if ak.Type() != bk.Type() {
+			continue
+		}
+		match[bk] = ak
+		used[bi] = true
+		renms = append(renms, Edit{Op: EditRename, Path: editChildPath(path, ak.UniqueName()), NewName: bk.Name(), NewUnique: bk.UniqueName()})
+	}
+	// deletes in reverse order, then renames, so names are free
+	cur := make([]string, 0, len(bkids))
+	for ai := len(akids) - 1; ai >= 0; ai-- {
+		if !used[ai] {
+			*es = append(*es, Edit{Op: EditDelete, Path: path, Name: akids[ai].UniqueName()})
+		}
+	}
+	*es = append(*es, renms...)
+	for ai, ak := range akids {
+		if used[ai] {
+			cur = append(cur, ak.UniqueName())
+		}
+	}
+	for _, bk := range bkids {
+		if ak, ok := match[bk]; ok && ak.UniqueName() != bk.UniqueName() {
+			for ci := range cur {
+				if cur[ci] == ak.UniqueName() {
+					cur[ci] = bk.UniqueName()
+				}
+			}
+		}
+	}
+	// then adds and moves in order, as in Config
+	for i, bk := range bkids {
+		nm := bk.UniqueName()
+		if _, ok := match[bk]; ok {
+			ci := i
+			for ; ci < len(cur); ci++ {
+				if cur[ci] == nm {
+					break
+				}
+			}
+			if ci != i {
+				copy(cur[i+1:ci+1], cur[i:ci])
+				cur[i] = nm
+				*es = append(*es, Edit{Op: EditMove, Path: path, Name: nm, Index: i})
+			}
+			continue
+		}
+		val, err := json.Marshal(bk)
+		if err != nil {
+			return fmt.Errorf("child %v at path: %q: %v", nm, path, err)
+		}
+		cur = append(cur, "")
+		copy(cur[i+1:], cur[i:])
+		cur[i] = nm
+		*es = append(*es, Edit{Op: EditAdd, Path: path, Name: nm, Type: kit.Types.TypeName(bk.Type()), Index: i, Value: val})
+	}
+	for _, bk := range bkids {
+		if ak, ok := match[bk]; ok {
+			if err := es.diffNode(ak, bk, editChildPath(path, bk.UniqueName())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
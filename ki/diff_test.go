@@ -0,0 +1,118 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func diffTestTree() Ki {
+	parent := NodeField{}
+	parent.InitName(&parent, "par1")
+	typ := KiT_NodeEmbed
+	parent.AddNewChild(typ, "child1")
+	child2 := parent.AddNewChild(typ, "child2").(*NodeEmbed)
+	parent.AddNewChild(typ, "child3")
+	parent.AddNewChild(KiT_NodeField, "child4")
+	child2.AddNewChild(typ, "subchild1")
+	child2.AddNewChild(typ, "subchild2")
+	child2.SetProp("intprop", 42)
+	child2.SetProp("stringprop", "a string")
+	return parent.This()
+}
+
+func TestDiffPatch(t *testing.T) {
+	a := diffTestTree()
+	b := a.Clone()
+
+	if es, err := Diff(a, b); err != nil || len(es) != 0 {
+		t.Errorf("expected no diffs for clone, got: %v\n%v", err, es)
+	}
+
+	b.DeleteChildAtIndex(0, true) // child1
+	b.AddNewChild(KiT_NodeEmbed, "child5")
+	b.MoveChild(2, 0) // child4 first
+	b.Child(2).SetName("child3b")
+	c2 := b.ChildByName("child2", 0).(*NodeEmbed)
+	c2.Mbr1 = "changed"
+	c2.SetProp("intprop", 43)
+	c2.DeleteProp("stringprop")
+	c2.SwapChildren(0, 1)
+	c2.Child(0).SetProp("floatprop", 2.5)
+	b.Embed(KiT_NodeField).(*NodeField).Field1.Mbr2 = 17
+	b.ChildByName("child4", 0).Embed(KiT_NodeField).(*NodeField).Field1.AddNewChild(KiT_NodeEmbed, "fieldkid")
+
+	es, err := Diff(a, b)
+	if err != nil {
+		t.Error(err)
+	}
+	nops := make(map[EditOps]int)
+	for _, ed := range es {
+		nops[ed.Op]++
+	}
+	want := map[EditOps]int{EditAdd: 2, EditDelete: 1, EditMove: 2, EditRename: 1, EditField: 2, EditProp: 2, EditDelProp: 1}
+	for op, n := range want {
+		if nops[op] != n {
+			t.Errorf("expected %v %v edits, got: %v\n%v", n, op, nops[op], es)
+		}
+	}
+
+	// round-trip the script through JSON
+	jb, err := json.Marshal(es)
+	if err != nil {
+		t.Error(err)
+	}
+	var les EditScript
+	err = json.Unmarshal(jb, &les)
+	if err != nil {
+		t.Error(err)
+	}
+
+	child3 := a.ChildByName("child3", 0)
+	err = ApplyPatch(a, les)
+	if err != nil {
+		t.Error(err)
+	}
+	if a.ChildByName("child3b", 0) != child3 {
+		t.Errorf("rename should preserve the existing child")
+	}
+
+	var abuf, bbuf bytes.Buffer
+	a.WriteJSON(&abuf, true)
+	b.WriteJSON(&bbuf, true)
+	if !bytes.Equal(abuf.Bytes(), bbuf.Bytes()) {
+		t.Errorf("patched tree not equal to target:\n%v\nvs.\n%v\n", abuf.String(), bbuf.String())
+	}
+	if es, err := Diff(a, b); err != nil || len(es) != 0 {
+		t.Errorf("expected no diffs after patch, got: %v\n%v", err, es)
+	}
+}
+
+func TestPatchErrors(t *testing.T) {
+	a := diffTestTree()
+	es := EditScript{{Op: EditDelete, Path: "child2", Name: "nosuch"}}
+	if err := ApplyPatch(a, es); err == nil {
+		t.Errorf("expected error for missing child")
+	}
+	es = EditScript{{Op: EditField, Path: "nosuch", Name: "Mbr1"}}
+	if err := ApplyPatch(a, es); err == nil {
+		t.Errorf("expected error for missing path")
+	}
+	es = EditScript{{Op: EditAdd, Name: "new", Type: "ki.NoSuchType"}}
+	if err := ApplyPatch(a, es); err == nil {
+		t.Errorf("expected error for unregistered type")
+	}
+}
+
+func TestDiffRootTypes(t *testing.T) {
+	a := diffTestTree()
+	b := Node{}
+	b.InitName(&b, "par1")
+	if _, err := Diff(a, b.This()); err == nil {
+		t.Errorf("expected error for different root types")
+	}
+}
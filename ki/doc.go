@@ -21,24 +21,41 @@ functionality, including:
       . for fields.
 
 	* Apply a function across nodes up or down a tree (natural "me first",
-      breadth-first, depth-first) -- very flexible for tree walking.
+      breadth-first, depth-first) -- very flexible for tree walking -- or
+      use for-range loops with the Children, Descendants, Ancestors and
+      Siblings iterators.
 
-	* Generalized I/O -- can Save and Load the Tree as JSON, XML, etc --
+	* Generalized I/O -- can Save and Load the Tree as JSON, YAML, XML, compact binary, etc --
       including pointers which are saved using paths and automatically
-      cached-out after loading -- enums also bidirectionally convertable to
+      cached-out after loading, and signal connections made with Link to
+      named receiver functions (see RecvFuncs) -- enums also bidirectionally convertable to
       strings using enum type registry in kit package.
 
 	* Robust deep copy, clone, move of nodes, with automatic pointer updating.
 
 	* Signal sending and receiving between Nodes (simlar to Qt Signals /
       Slots) -- setup connections once and then emit signals to all receivers
-      when relevant event happens.
+      when relevant event happens -- receivers can also return values
+      collected by EmitCollect, e.g., to veto deletion -- and DOM-style
+      event dispatching with capture and bubbling through the tree via
+      EventDispatcher -- receivers can be bound to a Mailbox so that all
+      their signals are run serially on its event loop goroutine.
 
 	* Robust state updating -- wrap updates in UpdateStart / End, and signals
       are blocked until the final end, at the highest affected level in the
       tree, at which point a single update signal is sent -- automatically
       gives the minimal update.
 
+	* Undo / Redo of tree mutations via UndoStack, using the same
+      UpdateStart / End blocks to group mutations into transactions.
+
+	* Optional concurrent mode via TreeLock, with a per-tree read / write
+      lock taken automatically by each Node method and held for a whole
+      UpdateStart / End batch, and signals sent after the lock is released.
+
+	* Optional stable node IDs, preserved through save and load, with a
+      per-tree IDIndex for finding nodes by ID.
+
 	* Properties (as a string-keyed map) with property inheritance, including
       type-level properties via kit type registry.
 
@@ -0,0 +1,207 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"sync"
+
+	"github.com/goki/ki/kit"
+)
+
+// EventPhases are the phases of the propagation of an Event through the
+// tree by an EventDispatcher.
+type EventPhases int32
+
+const (
+	// EventCapture is the capture phase, going down from the Root to the
+	// parent of the target, calling capture listeners.
+	EventCapture EventPhases = iota
+
+	// EventAtTarget is the target phase, calling the capture and then the
+	// bubble listeners of the target.
+	EventAtTarget
+
+	// EventBubble is the bubble phase, going back up from the parent of the
+	// target to the Root, calling bubble listeners.
+	EventBubble
+
+	EventPhasesN
+)
+
+//go:generate stringer -type=EventPhases
+
+var KiT_EventPhases = kit.Enums.AddEnum(EventPhasesN, kit.NotBitFlag, nil)
+
+func (ev EventPhases) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *EventPhases) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+// Event is an event dispatched through the tree by an EventDispatcher, in
+// the DOM style -- it is passed as the data to each listener, which is a
+// RecvFunc called with the node it is listening on as the receiver, the
+// target of the event as the sender, and the Type as the signal.
+type Event struct {
+	Type     int64       `desc:"type of event -- listeners are registered for specific types"`
+	Data     interface{} `desc:"arbitrary data for the event, as specified by the sender"`
+	NoBubble bool        `desc:"if true, the event does not go through the bubble phase"`
+	Target   Ki          `desc:"the node the event was dispatched at -- set by the dispatcher"`
+	Current  Ki          `desc:"the node whose listeners are currently being called -- set by the dispatcher"`
+	Phase    EventPhases `desc:"the current phase of propagation -- set by the dispatcher"`
+
+	stopped   bool
+	immediate bool
+	prevented bool
+}
+
+// StopPropagation stops the event from propagating to any further nodes
+// after the remaining listeners of the current node.
+func (ev *Event) StopPropagation() {
+	ev.stopped = true
+}
+
+// StopImmediatePropagation stops the event from propagating to any further
+// listeners, including those of the current node.
+func (ev *Event) StopImmediatePropagation() {
+	ev.stopped = true
+	ev.immediate = true
+}
+
+// PreventDefault records that the default action for the event should not
+// be taken by the code that dispatched it -- it does not affect propagation.
+func (ev *Event) PreventDefault() {
+	ev.prevented = true
+}
+
+// IsStopped returns true if StopPropagation has been called.
+func (ev *Event) IsStopped() bool {
+	return ev.stopped
+}
+
+// DefaultPrevented returns true if PreventDefault has been called.
+func (ev *Event) DefaultPrevented() bool {
+	return ev.prevented
+}
+
+// eventKey identifies the listeners of a node for an event type and phase
+type eventKey struct {
+	k       Ki
+	typ     int64
+	capture bool
+}
+
+// EventDispatcher dispatches events through the tree in the DOM style: an
+// event dispatched at a target node goes through a capture phase from the
+// Root down to the parent of the target, then the target itself, and then
+// a bubble phase back up through the parents to the Root, calling the
+// listeners registered on each node for the type of event.  Listeners can
+// stop the propagation, and prevent the default action taken by the
+// dispatching code.  The listeners of each node are kept in a Signal, so
+// they are called in the order they were added, and each can be removed
+// using its SignalConn handle.  A dispatcher is safe for concurrent use.
+type EventDispatcher struct {
+	mu   sync.RWMutex
+	sigs map[eventKey]*Signal
+}
+
+// NewEventDispatcher returns a new EventDispatcher.
+func NewEventDispatcher() *EventDispatcher {
+	return &EventDispatcher{sigs: make(map[eventKey]*Signal)}
+}
+
+// Listen adds a listener function for events of given type on given node,
+// for the capture phase if capture is true, and otherwise the bubble
+// phase -- it is called for events dispatched at the node itself in
+// either case.  Returns the handle of the connection, which can be used to
+// remove the listener.
+func (d *EventDispatcher) Listen(k Ki, typ int64, capture bool, fun RecvFunc) *SignalConn {
+	key := eventKey{k: k, typ: typ, capture: capture}
+	d.mu.Lock()
+	sig := d.sigs[key]
+	if sig == nil {
+		sig = &Signal{}
+		d.sigs[key] = sig
+	}
+	d.mu.Unlock()
+	return sig.ConnectPri(k, 0, fun)
+}
+
+// RemoveListeners removes all the listeners of given node, e.g., when it
+// is destroyed.
+func (d *EventDispatcher) RemoveListeners(k Ki) {
+	d.mu.Lock()
+	for key := range d.sigs {
+		if key.k == k {
+			delete(d.sigs, key)
+		}
+	}
+	d.mu.Unlock()
+}
+
+// Dispatch dispatches an event of given type and data at the target node,
+// returning the event after all the listeners have been called -- use
+// DefaultPrevented on it to determine whether to take the default action.
+func (d *EventDispatcher) Dispatch(target Ki, typ int64, data interface{}) *Event {
+	ev := &Event{Type: typ, Data: data}
+	d.DispatchEvent(target, ev)
+	return ev
+}
+
+// DispatchEvent dispatches given event at the target node, returning false
+// if any listener called PreventDefault.  The propagation path is
+// determined before any listeners are called, so it is not affected by
+// any changes they make to the tree.
+func (d *EventDispatcher) DispatchEvent(target Ki, ev *Event) bool {
+	if target == nil || target.This() == nil {
+		return true
+	}
+	target = target.This()
+	ev.Target = target
+	var path []Ki // parents of target, from the Root down
+	for par := target.Parent(); par != nil && par.This() != nil; par = par.Parent() {
+		path = append(path, par.This())
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	ev.Phase = EventCapture
+	for _, k := range path {
+		d.call(k, ev, true)
+		if ev.stopped {
+			return !ev.prevented
+		}
+	}
+	ev.Phase = EventAtTarget
+	d.call(target, ev, true)
+	d.call(target, ev, false)
+	if ev.stopped || ev.NoBubble {
+		return !ev.prevented
+	}
+	ev.Phase = EventBubble
+	for i := len(path) - 1; i >= 0; i-- {
+		d.call(path[i], ev, false)
+		if ev.stopped {
+			break
+		}
+	}
+	return !ev.prevented
+}
+
+// call calls the listeners of given node for the event, in the capture or
+// bubble list.
+func (d *EventDispatcher) call(k Ki, ev *Event, capture bool) {
+	if ev.immediate {
+		return
+	}
+	d.mu.RLock()
+	sig := d.sigs[eventKey{k: k, typ: ev.Type, capture: capture}]
+	d.mu.RUnlock()
+	if sig == nil {
+		return
+	}
+	ev.Current = k
+	sig.ConsFunc(func(recv Ki, fun RecvFunc) bool {
+		fun(recv, ev.Target, ev.Type, ev)
+		return !ev.immediate
+	})
+}
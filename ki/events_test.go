@@ -0,0 +1,91 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestEventDispatcher(t *testing.T) {
+	root := NodeEmbed{}
+	root.InitName(&root, "root")
+	mid := root.AddNewChild(nil, "mid")
+	leaf := mid.AddNewChild(nil, "leaf")
+	other := root.AddNewChild(nil, "other")
+
+	const click, key = 1, 2
+	d := NewEventDispatcher()
+	var res []string
+	listener := func(tag string) RecvFunc {
+		return func(recv, send Ki, sig int64, data interface{}) {
+			ev := data.(*Event)
+			res = append(res, fmt.Sprintf("%v %v %v %v", recv.Name(), tag, ev.Phase, send.Name()))
+			if ev.Current != recv || ev.Target != send {
+				t.Errorf("wrong Current or Target: %v %v", ev.Current, ev.Target)
+			}
+		}
+	}
+	for _, k := range []Ki{&root, mid, leaf, other} {
+		d.Listen(k, click, true, listener("capture"))
+		d.Listen(k, click, false, listener("bubble"))
+	}
+	d.Listen(mid, key, false, listener("key"))
+
+	ev := d.Dispatch(leaf, click, nil)
+	want := []string{
+		"root capture EventCapture leaf",
+		"mid capture EventCapture leaf",
+		"leaf capture EventAtTarget leaf",
+		"leaf bubble EventAtTarget leaf",
+		"mid bubble EventBubble leaf",
+		"root bubble EventBubble leaf",
+	}
+	if !reflect.DeepEqual(res, want) || ev.DefaultPrevented() || ev.IsStopped() {
+		t.Errorf("dispatch:\n%v\nvs.\n%v", res, want)
+	}
+
+	res = res[:0]
+	d.Dispatch(leaf, key, nil)
+	if !reflect.DeepEqual(res, []string{"mid key EventBubble leaf"}) {
+		t.Errorf("event types: %v", res)
+	}
+
+	// stop in the capture phase, and prevent default while bubbling
+	res = res[:0]
+	stop := d.Listen(mid, click, true, func(recv, send Ki, sig int64, data interface{}) {
+		data.(*Event).StopPropagation()
+	})
+	d.Dispatch(leaf, click, nil)
+	if !reflect.DeepEqual(res, want[:2]) {
+		t.Errorf("StopPropagation: %v", res)
+	}
+	stop.Disconnect()
+	res = res[:0]
+	d.Listen(mid, click, false, func(recv, send Ki, sig int64, data interface{}) {
+		data.(*Event).PreventDefault()
+		data.(*Event).StopImmediatePropagation()
+	})
+	d.Listen(mid, click, false, listener("never"))
+	ok := d.DispatchEvent(leaf, &Event{Type: click})
+	if ok || !reflect.DeepEqual(res, want[:5]) {
+		t.Errorf("PreventDefault: %v %v", ok, res)
+	}
+
+	res = res[:0]
+	d.DispatchEvent(leaf, &Event{Type: click, NoBubble: true})
+	if !reflect.DeepEqual(res, want[:4]) {
+		t.Errorf("NoBubble: %v", res)
+	}
+
+	res = res[:0]
+	d.RemoveListeners(mid)
+	d.Dispatch(other, click, nil)
+	d.Dispatch(mid, key, nil)
+	if !reflect.DeepEqual(res, []string{"root capture EventCapture other", "other capture EventAtTarget other", "other bubble EventAtTarget other", "root bubble EventBubble other"}) {
+		t.Errorf("other target: %v", res)
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// NodeIDs determines whether Init allocates a stable ID (see Ki.ID) for
+// each new node -- IDs are also allocated for any nodes without one when
+// they are added to a tree that has an IDIndex.
+var NodeIDs = false
+
+// NewID returns a new random, non-zero node ID -- IDs are random so that
+// they remain unique across sessions and processes, and fit in 53 bits so
+// they are exactly represented as JSON numbers.
+func NewID() uint64 {
+	for {
+		if id := rand.Uint64() >> 11; id != 0 {
+			return id
+		}
+	}
+}
+
+// ID returns the stable identifier of this node (Node.Uid), which is
+// preserved through save and load, and is not changed by renaming -- 0
+// if none has been allocated (see NodeIDs, IDIndex).
+func (n *Node) ID() uint64 {
+	return atomic.LoadUint64(&n.Uid)
+}
+
+// SetID sets the stable identifier of this node, returning an error if it
+// is already used by another node in the IDIndex of its tree.
+func (n *Node) SetID(id uint64) error {
+	defer n.rlockTree().RUnlock()
+	ix := IDIndexFor(n.This())
+	if ix == nil {
+		atomic.StoreUint64(&n.Uid, id)
+		return nil
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if k, ok := ix.m[id]; ok && id != 0 && k != n.This() && ix.valid(k, id) {
+		return fmt.Errorf("ki %v SetID: ID %x already used by: %v", n.PathUnique(), id, k.PathUnique())
+	}
+	old := n.ID()
+	if ix.m[old] == n.This() {
+		delete(ix.m, old)
+	}
+	atomic.StoreUint64(&n.Uid, id)
+	if id != 0 {
+		ix.m[id] = n.This()
+	}
+	return nil
+}
+
+// IDIndex is an index of all the nodes in a tree by their ID, owned by
+// the root of the tree -- see NewIDIndex.  It is maintained automatically
+// as nodes are added, moved, deleted and destroyed (via SetParent), and
+// after loading (via UnmarshalPost).  Nodes without an ID are allocated
+// one when added, and a node with the same ID as another node already in
+// the tree is allocated a new one, so IDs are always unique within the
+// tree.
+type IDIndex struct {
+	Root Ki `desc:"root of the tree being indexed"`
+
+	mu sync.RWMutex
+	m  map[uint64]Ki
+}
+
+// idIndexes is the registry of IDIndex's keyed by tree root
+var idIndexes = struct {
+	sync.RWMutex
+	m map[Ki]*IDIndex
+}{m: make(map[Ki]*IDIndex)}
+
+// idIndexActive is the number of registered IDIndex's -- avoids any lookup
+// cost when indexes are not in use.
+var idIndexActive int32
+
+// NewIDIndex creates a new IDIndex of all the nodes in the tree under
+// given root node, allocating IDs for any nodes that do not have one.
+// Any existing index for the root is closed first.
+func NewIDIndex(root Ki) *IDIndex {
+	if old := IDIndexFor(root); old != nil && old.Root == root {
+		old.Close()
+	}
+	ix := &IDIndex{Root: root}
+	ix.Rebuild()
+	idIndexes.Lock()
+	idIndexes.m[root] = ix
+	atomic.StoreInt32(&idIndexActive, int32(len(idIndexes.m)))
+	idIndexes.Unlock()
+	return ix
+}
+
+// IDIndexFor returns the IDIndex for the tree containing given node, or
+// nil if none.
+func IDIndexFor(k Ki) *IDIndex {
+	if atomic.LoadInt32(&idIndexActive) == 0 || k == nil || k.This() == nil {
+		return nil
+	}
+	root := k.Root()
+	idIndexes.RLock()
+	ix := idIndexes.m[root]
+	idIndexes.RUnlock()
+	return ix
+}
+
+// ByID returns the node with given ID in the tree containing node k,
+// using its IDIndex if it has one, and otherwise searching the tree --
+// returns nil if not found.
+func ByID(k Ki, id uint64) Ki {
+	if k == nil || k.This() == nil || id == 0 {
+		return nil
+	}
+	if ix := IDIndexFor(k); ix != nil {
+		return ix.ByID(id)
+	}
+	var fk Ki
+	k.Root().FuncDownMeFirst(0, nil, func(k2 Ki, level int, d interface{}) bool {
+		if fk != nil {
+			return Break
+		}
+		if k2.ID() == id {
+			fk = k2
+			return Break
+		}
+		return Continue
+	})
+	return fk
+}
+
+// Close unregisters the index so its tree is no longer indexed.
+func (ix *IDIndex) Close() {
+	idIndexes.Lock()
+	if idIndexes.m[ix.Root] == ix {
+		delete(idIndexes.m, ix.Root)
+	}
+	atomic.StoreInt32(&idIndexActive, int32(len(idIndexes.m)))
+	idIndexes.Unlock()
+	ix.mu.Lock()
+	ix.m = nil
+	ix.mu.Unlock()
+}
+
+// ByID returns the node with given ID in the tree, or nil if not found.
+func (ix *IDIndex) ByID(id uint64) Ki {
+	defer ix.Root.AsNode().rlockTree().RUnlock()
+	ix.mu.RLock()
+	k, ok := ix.m[id]
+	ix.mu.RUnlock()
+	if !ok || !ix.valid(k, id) {
+		return nil
+	}
+	return k
+}
+
+// Len returns the number of nodes in the index.
+func (ix *IDIndex) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.m)
+}
+
+// Rebuild rebuilds the index from the current tree, which is only needed
+// if nodes have been added or removed without using SetParent.
+func (ix *IDIndex) Rebuild() {
+	ix.mu.Lock()
+	ix.m = make(map[uint64]Ki)
+	ix.mu.Unlock()
+	defer ix.Root.AsNode().rlockTree().RUnlock()
+	ix.addDown(ix.Root)
+}
+
+// valid returns true if given indexed node is still in the tree with the
+// given ID -- nodes can be left in the index by loading over an existing
+// tree, which replaces nodes without using SetParent.  Assumed to be
+// under the tree lock.
+func (ix *IDIndex) valid(k Ki, id uint64) bool {
+	if k.This() == nil || k.ID() != id || k.IsDeleted() {
+		return false
+	}
+	for ; k != ix.Root; k = k.AsNode().Par {
+		par := k.AsNode().Par
+		if par == nil {
+			return false
+		}
+		if !k.IsField() {
+			if _, ok := par.AsNode().Kids.IndexOf(k, 0); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// addDown adds given node and all the nodes below it to the index,
+// allocating new IDs as needed to keep them unique -- assumed to be under
+// the tree lock.
+func (ix *IDIndex) addDown(k Ki) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.m == nil {
+		return
+	}
+	walkDownLocked(k, func(k Ki) bool {
+		nb := k.AsNode()
+		id := nb.ID()
+		if id != 0 {
+			if ek, ok := ix.m[id]; ok && ek != k && ix.valid(ek, id) {
+				id = 0
+			}
+		}
+		if id == 0 {
+			id = NewID()
+			for _, used := ix.m[id]; used; _, used = ix.m[id] {
+				id = NewID()
+			}
+			atomic.StoreUint64(&nb.Uid, id)
+		}
+		ix.m[id] = k
+		return Continue
+	})
+}
+
+// removeDown removes given node and all the nodes below it from the index
+// -- assumed to be under the tree lock.
+func (ix *IDIndex) removeDown(k Ki) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	walkDownLocked(k, func(k Ki) bool {
+		if id := k.ID(); ix.m[id] == k {
+			delete(ix.m, id)
+		}
+		return Continue
+	})
+}
+
+// idIndexDestroyed closes the index of given root node when it is
+// destroyed -- called by Destroy.
+func idIndexDestroyed(root Ki) {
+	idIndexes.RLock()
+	ix := idIndexes.m[root]
+	idIndexes.RUnlock()
+	if ix != nil {
+		ix.Close()
+	}
+}
+
+// idIndexMove updates the indexes for node k moving from the tree under
+// oldRoot to the tree under newRoot -- called by SetParent, under the tree
+// lock.
+func idIndexMove(k, oldRoot, newRoot Ki) {
+	idIndexes.RLock()
+	oix, nix := idIndexes.m[oldRoot], idIndexes.m[newRoot]
+	idIndexes.RUnlock()
+	if oix == nix {
+		return
+	}
+	if oix != nil {
+		oix.removeDown(k)
+	}
+	if nix != nil {
+		nix.addDown(k)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNodeIDs(t *testing.T) {
+	NodeIDs = true
+	root := binTestTree()
+	NodeIDs = false
+	ids := make(map[uint64]string)
+	root.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		if k.ID() == 0 {
+			t.Errorf("no ID allocated for %v", k.PathUnique())
+		}
+		if _, has := ids[k.ID()]; has {
+			t.Errorf("duplicate ID for %v", k.PathUnique())
+		}
+		ids[k.ID()] = k.PathUnique()
+		return Continue
+	})
+	check := func(fmt string, nr Ki) {
+		t.Helper()
+		n := 0
+		nr.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+			if ids[k.ID()] != k.PathUnique() {
+				t.Errorf("%v: ID not preserved for %v", fmt, k.PathUnique())
+			}
+			n++
+			return Continue
+		})
+		if n != len(ids) {
+			t.Errorf("%v: %v nodes loaded vs. %v", fmt, n, len(ids))
+		}
+	}
+
+	var buf bytes.Buffer
+	root.WriteJSON(&buf, true)
+	nr, err := ReadNewJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("JSON", nr)
+
+	buf.Reset()
+	root.WriteXML(&buf, true)
+	xr := NodeField{}
+	xr.InitName(&xr, "")
+	if err := xr.ReadXML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	check("XML", xr.This())
+
+	buf.Reset()
+	root.WriteYAML(&buf)
+	yr, err := ReadNewYAML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("YAML", yr)
+
+	// IDs are not copied, but renaming keeps them
+	cl := root.Clone()
+	if cl.ID() != 0 || cl.Child(0).ID() != 0 {
+		t.Errorf("Clone should not copy IDs")
+	}
+	c1 := root.Child(0)
+	id := c1.ID()
+	c1.SetName("renamed")
+	if c1.ID() != id {
+		t.Errorf("SetName changed ID")
+	}
+}
+
+func TestIDIndex(t *testing.T) {
+	root := binTestTree()
+	ix := NewIDIndex(root)
+	defer ix.Close()
+	n := 0
+	root.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		if k.ID() == 0 || ix.ByID(k.ID()) != k {
+			t.Errorf("%v not indexed", k.PathUnique())
+		}
+		n++
+		return Continue
+	})
+	if ix.Len() != n {
+		t.Errorf("index has %v nodes vs. %v", ix.Len(), n)
+	}
+
+	// added nodes get IDs, and duplicates are reallocated
+	nk := root.AddNewChild(KiT_NodeField, "new")
+	if nk.ID() == 0 || ByID(root, nk.ID()) != nk || ix.ByID(nk.(*NodeField).Field1.ID()) == nil {
+		t.Errorf("added node not indexed")
+	}
+	dup := NodeEmbed{}
+	dup.InitName(&dup, "dup")
+	dup.SetID(nk.ID())
+	root.AddChild(&dup)
+	if dup.ID() == nk.ID() || ix.ByID(dup.ID()) != &dup || ix.ByID(nk.ID()) != nk {
+		t.Errorf("duplicate ID should be reallocated")
+	}
+	if err := dup.SetID(nk.ID()); err == nil {
+		t.Errorf("SetID should fail for ID in use")
+	}
+	if err := dup.SetID(42); err != nil || ByID(root, 42) != &dup {
+		t.Errorf("SetID not indexed: %v", err)
+	}
+
+	// deleted nodes are removed, moved nodes kept
+	sub := root.ChildByName("child2", 0).Child(0)
+	subID := sub.ID()
+	c3 := root.ChildByName("child3", 0)
+	c3.AddChild(sub)
+	root.ChildByName("child2", 0).DeleteChild(sub, false)
+	if ix.ByID(subID) != sub {
+		t.Errorf("moved node should be found")
+	}
+	c3.DeleteChild(sub, false)
+	if ix.ByID(subID) != nil {
+		t.Errorf("deleted node should not be found")
+	}
+	c3.AddChild(sub)
+	if ix.ByID(subID) != sub {
+		t.Errorf("re-added node should keep its ID")
+	}
+	fid := nk.ID()
+	nk.Delete(true)
+	if ix.ByID(fid) != nil || ix.Len() != n+1 {
+		t.Errorf("destroyed node should be removed: %v vs. %v", ix.Len(), n+1)
+	}
+
+	// loading over the tree keeps the IDs, and rebuilds the index
+	var buf bytes.Buffer
+	root.WriteJSON(&buf, true)
+	c1 := root.Child(0)
+	if err := root.ReadJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if nc1 := ix.ByID(c1.ID()); nc1 == nil || nc1.PathUnique() != c1.PathUnique() {
+		t.Errorf("loaded node not indexed")
+	}
+	if ix.Len() != n+1 {
+		t.Errorf("index has %v nodes after load vs. %v", ix.Len(), n+1)
+	}
+
+	ix.Close()
+	if IDIndexFor(root) != nil || ByID(root, 42) != root.ChildByName("dup", 0) {
+		t.Errorf("ByID should search the tree after Close")
+	}
+}
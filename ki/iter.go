@@ -0,0 +1,122 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"iter"
+	"reflect"
+)
+
+// This file has iterators for use with for-range loops, as an alternative
+// to the Func methods, e.g.:
+//
+//	for k := range ki.Descendants(root) {
+//		if k.Name() == "done" {
+//			break
+//		}
+//	}
+//
+// The iterators access the tree one step at a time, so the loop body can
+// modify the tree -- changes to the parts of the tree not yet reached are
+// reflected, but other changes may cause nodes to be skipped or visited
+// twice.
+
+// Children returns an iterator over the children of given node.
+func Children(k Ki) iter.Seq[Ki] {
+	return func(yield func(Ki) bool) {
+		for i := 0; i < k.NumChildren(); i++ {
+			if kid, err := k.ChildTry(i); err == nil && kid != nil && kid.This() != nil {
+				if !yield(kid.This()) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Descendants returns an iterator over all the nodes below given node, not
+// including the node itself, in depth-first order, including Ki fields,
+// which are visited before children, as in FuncDownMeFirst.
+func Descendants(k Ki) iter.Seq[Ki] {
+	return func(yield func(Ki) bool) {
+		yieldDescendants(k, yield)
+	}
+}
+
+// yieldDescendants implements Descendants, returning false if stopped
+func yieldDescendants(k Ki, yield func(Ki) bool) bool {
+	nf := k.NumKiFields()
+	for i := 0; i < nf; i++ {
+		fk := k.KiField(i)
+		if fk == nil {
+			continue
+		}
+		if !yield(fk) || !yieldDescendants(fk, yield) {
+			return false
+		}
+	}
+	for kid := range Children(k) {
+		if !yield(kid) || !yieldDescendants(kid, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// Ancestors returns an iterator over the parents of given node, starting
+// with its own parent and going up to the root.
+func Ancestors(k Ki) iter.Seq[Ki] {
+	return func(yield func(Ki) bool) {
+		for par := k.Parent(); par != nil && par.This() != nil; par = par.Parent() {
+			if !yield(par.This()) {
+				return
+			}
+		}
+	}
+}
+
+// Siblings returns an iterator over the other children of the parent of
+// given node, in order, not including the node itself.  Ki fields have no
+// siblings.
+func Siblings(k Ki) iter.Seq[Ki] {
+	return func(yield func(Ki) bool) {
+		par := k.Parent()
+		if par == nil || k.IsField() {
+			return
+		}
+		this := k.This()
+		for kid := range Children(par) {
+			if kid != this && !yield(kid) {
+				return
+			}
+		}
+	}
+}
+
+// ChildrenOfType returns an iterator over the children of given node that
+// have given type -- if embeds is true, then it includes any type that
+// embeds the given type at any level of anonymous embedding.
+func ChildrenOfType(k Ki, t reflect.Type, embeds bool) iter.Seq[Ki] {
+	return filterType(Children(k), t, embeds)
+}
+
+// DescendantsOfType returns an iterator over the nodes below given node
+// that have given type, in the same order as Descendants -- if embeds is
+// true, then it includes any type that embeds the given type at any level
+// of anonymous embedding.
+func DescendantsOfType(k Ki, t reflect.Type, embeds bool) iter.Seq[Ki] {
+	return filterType(Descendants(k), t, embeds)
+}
+
+// filterType returns the nodes in seq of given type
+func filterType(seq iter.Seq[Ki], t reflect.Type, embeds bool) iter.Seq[Ki] {
+	return func(yield func(Ki) bool) {
+		for k := range seq {
+			if (k.Type() == t || embeds && k.TypeEmbeds(t)) && !yield(k) {
+				return
+			}
+		}
+	}
+}
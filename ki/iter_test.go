@@ -0,0 +1,72 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"iter"
+	"reflect"
+	"testing"
+)
+
+func seqPaths(seq iter.Seq[Ki]) []string {
+	var ps []string
+	for k := range seq {
+		ps = append(ps, k.PathUnique())
+	}
+	return ps
+}
+
+func TestIterators(t *testing.T) {
+	root := binTestTree()
+	var want []string
+	root.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		if k != root {
+			want = append(want, k.PathUnique())
+		}
+		return Continue
+	})
+	if got := seqPaths(Descendants(root)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Descendants:\n%v\nvs.\n%v", got, want)
+	}
+
+	want = nil
+	for _, k := range *root.Children() {
+		want = append(want, k.PathUnique())
+	}
+	if got := seqPaths(Children(root)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Children:\n%v\nvs.\n%v", got, want)
+	}
+	c1 := root.Child(1)
+	if got := seqPaths(Siblings(c1)); len(got) != len(want)-1 || got[0] != want[0] || got[1] != want[2] {
+		t.Errorf("Siblings: %v", got)
+	}
+	if got := seqPaths(Siblings(root)); len(got) != 0 {
+		t.Errorf("root should not have Siblings: %v", got)
+	}
+
+	kid2 := root.ChildByName("child4", 0).Child(0).KiFieldByName("Field2")
+	if got := seqPaths(Ancestors(kid2)); !reflect.DeepEqual(got, []string{"/par1/child4/kid2", "/par1/child4", "/par1"}) {
+		t.Errorf("Ancestors: %v", got)
+	}
+
+	if got := seqPaths(DescendantsOfType(root, KiT_NodeField2, false)); !reflect.DeepEqual(got, []string{"/par1/child4/kid2"}) {
+		t.Errorf("DescendantsOfType: %v", got)
+	}
+	if got := seqPaths(ChildrenOfType(root, KiT_NodeEmbed, true)); len(got) != root.NumChildren() {
+		t.Errorf("ChildrenOfType embeds: %v", got)
+	}
+
+	// early break, including from within the recursion
+	var got []string
+	for k := range Descendants(root) {
+		got = append(got, k.PathUnique())
+		if k.Name() == "subchild1" {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, []string{"/par1.Field1", "/par1.Field1/fieldkid", "/par1/child1", "/par1/child2", "/par1/child2/subchild1"}) {
+		t.Errorf("break from Descendants: %v", got)
+	}
+}
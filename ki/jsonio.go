@@ -0,0 +1,495 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/goki/ki/kit"
+)
+
+// JSONIndent is the indent string used for indented JSON output, as in
+// WriteJSON with indent = true
+var JSONIndent = "  "
+
+//////////////////////////////////////////////////////////////////////////
+//  jsonEncoder
+
+// jsonEncoder streams a Ki tree as JSON, node by node, in exactly the format
+// produced by json.Marshal of the tree: each node's own fields are marshaled
+// from a shadow copy with the Kids field left empty (see jsonShadow), and the
+// Kids are then streamed in its place.  Thus only one node's fields are in memory at any time, instead of
+// the entire document.
+type jsonEncoder struct {
+	w      io.Writer
+	indent bool
+	err    error
+}
+
+// write writes to the underlying writer, retaining the first error
+func (je *jsonEncoder) write(b []byte) {
+	if je.err != nil {
+		return
+	}
+	_, je.err = je.w.Write(b)
+}
+
+// writeStr writes string to the underlying writer
+func (je *jsonEncoder) writeStr(s string) {
+	je.write([]byte(s))
+}
+
+// marshal marshals given value, indented with given line prefix if indenting
+func (je *jsonEncoder) marshal(v interface{}, prefix string) ([]byte, error) {
+	if je.indent {
+		return json.MarshalIndent(v, prefix, JSONIndent)
+	}
+	return json.Marshal(v)
+}
+
+// encodeNode writes given node, with given prefix for indented lines after
+// the first one.
+func (je *jsonEncoder) encodeNode(k Ki, prefix string) error {
+	nb := k.AsNode()
+	kids := nb.Kids
+	if _, ok := k.(json.Marshaler); ok || len(kids) == 0 {
+		b, err := je.marshal(k, prefix)
+		if err != nil {
+			return err
+		}
+		je.write(b)
+		return je.err
+	}
+	b, err := je.marshal(jsonShadow(k), prefix)
+	if err != nil {
+		return err
+	}
+	st, ed, ok := jsonMemberValue(b, "Kids")
+	if !ok { // Kids not saved for this type
+		je.write(b)
+		return je.err
+	}
+	je.write(b[:st])
+	je.writeStr(":")
+	if je.indent {
+		je.writeStr(" ")
+	}
+	if err := je.encodeSlice(kids, prefix+JSONIndent); err != nil {
+		return err
+	}
+	je.write(b[ed:])
+	return je.err
+}
+
+// jsonShadow returns a new value of the type of given node, with the fields
+// that are saved in JSON copied from it, except for its Kids, so the node
+// can be marshaled without its children and without modifying it.
+func jsonShadow(k Ki) interface{} {
+	src := reflect.ValueOf(k).Elem()
+	dst := reflect.New(src.Type())
+	jsonCopyFields(dst.Elem(), src, false)
+	return dst.Interface()
+}
+
+// jsonFields returns the indexes of the fields of struct type that are
+// saved in JSON: exported and not tagged json:"-" -- also used for the
+// fields saved by WriteBinary.
+func jsonFields(typ reflect.Type) []int {
+	var flds []int
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("json") == "-" {
+			continue
+		}
+		flds = append(flds, i)
+	}
+	return flds
+}
+
+// jsonCopyFields copies the fields saved in JSON (see jsonFields) from src
+// to dst struct, recursing into embedded structs and Ki fields -- the Kids of
+// the Node are only copied if kids is true, i.e., for Ki fields, which are
+// marshaled with their children.
+func jsonCopyFields(dst, src reflect.Value, kids bool) {
+	typ := src.Type()
+	for _, i := range jsonFields(typ) {
+		f := typ.Field(i)
+		switch {
+		case f.Type.Kind() == reflect.Struct && f.Anonymous:
+			jsonCopyFields(dst.Field(i), src.Field(i), kids)
+		case f.Type.Kind() == reflect.Struct && IsKi(f.Type):
+			jsonCopyFields(dst.Field(i), src.Field(i), true)
+		case typ == KiT_Node && f.Name == "Kids" && !kids:
+		default:
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+// encodeSlice writes given slice of children as the header record with the
+// number, types and names of the elements, followed by each element.
+// prefix is the prefix of the line on which the slice starts.
+func (je *jsonEncoder) encodeSlice(sl Slice, prefix string) error {
+	if len(sl) == 0 {
+		je.writeStr("null")
+		return je.err
+	}
+	eprefix := prefix + JSONIndent // element prefix
+	sep, fsep, fprefix := ",", ":", ""
+	if je.indent {
+		sep, fsep, fprefix = ",\n", ": ", eprefix+JSONIndent
+		je.writeStr("[\n" + eprefix + "{\n")
+	} else {
+		je.writeStr("[{")
+	}
+	je.writeStr(fmt.Sprintf("%v\"n\"%v%d", fprefix, fsep, len(sl)))
+	for _, kid := range sl {
+		tb, _ := json.Marshal(kit.Types.TypeName(kid.Type()))
+		nb, _ := json.Marshal(kid.UniqueName())
+		je.writeStr(sep + fprefix + "\"type\"" + fsep)
+		je.write(tb)
+		je.writeStr(sep + fprefix + "\"name\"" + fsep)
+		je.write(nb)
+	}
+	if je.indent {
+		je.writeStr("\n" + eprefix)
+	}
+	je.writeStr("}")
+	for _, kid := range sl {
+		je.writeStr(sep)
+		if je.indent {
+			je.writeStr(eprefix)
+		}
+		if err := je.encodeNode(kid, eprefix); err != nil {
+			return err
+		}
+	}
+	if je.indent {
+		je.writeStr("\n" + prefix)
+	}
+	je.writeStr("]")
+	return je.err
+}
+
+// jsonMemberValue returns the start and end of the value of given top-level
+// member of the JSON object in b -- start is just after the key, so the
+// range includes the : separator.
+func jsonMemberValue(b []byte, key string) (st, ed int, ok bool) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return 0, 0, false
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return 0, 0, false
+		}
+		st = int(dec.InputOffset())
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return 0, 0, false
+		}
+		if t == key {
+			return st, int(dec.InputOffset()), true
+		}
+	}
+	return 0, 0, false
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  jsonDecoder
+
+// jsonDecoder reads a Ki tree from a stream of JSON tokens, node by node,
+// configuring the children of each node from the Slice header record and
+// then loading each child in turn.  Only the fields of the current node
+// are accumulated in memory.
+type jsonDecoder struct {
+	dec *json.Decoder
+
+	// migrate is set when the stream has a type header, which records the
+	// versions of the types -- nodes of older versions are then migrated
+	migrate bool
+
+	// versions are the saved versions of the types, from the header
+	versions map[string]int
+
+	// noMigrate is a node that has already been migrated
+	noMigrate Ki
+
+	// migrated records the migrations applied
+	migrated []Migrated
+}
+
+// newJSONDecoder returns a new decoder reading from given reader
+func newJSONDecoder(r io.Reader) *jsonDecoder {
+	return &jsonDecoder{dec: json.NewDecoder(r)}
+}
+
+// errorAt returns an error at given input offset
+func (jd *jsonDecoder) errorAt(off int64, format string, args ...interface{}) error {
+	return fmt.Errorf("ki JSON decode: offset %d: %v", off, fmt.Sprintf(format, args...))
+}
+
+// wrapErr adds the input offset to errors from the json.Decoder
+func (jd *jsonDecoder) wrapErr(err error) error {
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		return jd.errorAt(err.Offset, "%v", err)
+	case *json.UnmarshalTypeError:
+		return jd.errorAt(err.Offset, "%v", err)
+	}
+	if err == io.EOF {
+		return jd.errorAt(jd.dec.InputOffset(), "unexpected end of input")
+	}
+	return err
+}
+
+// token reads the next token, also returning the input offset just before
+// it (it starts at the next non-space, non-separator character).
+func (jd *jsonDecoder) token() (json.Token, int64, error) {
+	off := jd.dec.InputOffset()
+	t, err := jd.dec.Token()
+	if err != nil {
+		return nil, off, jd.wrapErr(err)
+	}
+	return t, off, nil
+}
+
+// delim reads the next token, which must be the given delimiter
+func (jd *jsonDecoder) delim(d json.Delim) error {
+	t, off, err := jd.token()
+	if err != nil {
+		return err
+	}
+	if t != d {
+		return jd.errorAt(off, "expected %v, got: %v", d, t)
+	}
+	return nil
+}
+
+// readRootType reads the JSONTypePrefix record from the start of the
+// stream, returning the root type name, or "" if the stream does not start
+// with that record -- br must be the reader of the decoder.  The saved type
+// versions in the record enable migration of older versions.
+func (jd *jsonDecoder) readRootType(br *bufio.Reader) (string, error) {
+	pb, _ := br.Peek(len(JSONTypePrefix))
+	if !bytes.Equal(pb, JSONTypePrefix) {
+		return "", nil
+	}
+	var hdr struct {
+		RootType string         `json:"ki.RootType"`
+		Versions map[string]int `json:"ki.Versions"`
+	}
+	if err := jd.dec.Decode(&hdr); err != nil {
+		return "", jd.wrapErr(err)
+	}
+	jd.migrate = true
+	jd.versions = hdr.Versions
+	return hdr.RootType, nil
+}
+
+// jsonHeader returns the JSONTypePrefix record for given root type, which
+// also records the current versions of any types with migrations.
+func jsonHeader(typ reflect.Type) []byte {
+	var b bytes.Buffer
+	tb, _ := json.Marshal(kit.Types.TypeName(typ))
+	b.Write(JSONTypePrefix)
+	b.Write(tb)
+	if vers := kit.Types.Versions(); len(vers) > 0 {
+		vb, _ := json.Marshal(vers)
+		b.WriteString(", \"ki.Versions\": ")
+		b.Write(vb)
+	}
+	b.Write(JSONTypeSuffix)
+	return b.Bytes()
+}
+
+// decodeNode reads the next JSON object into given node, loading the Kids
+// directly into the children of the node, and all other fields via
+// json.Unmarshal.  Nodes of older versions are migrated.  path is the path
+// of the node relative to the root, for the record of migrations.
+func (jd *jsonDecoder) decodeNode(k Ki, path string) error {
+	if jd.migrate && k != jd.noMigrate {
+		tn := kit.Types.TypeName(k.Type())
+		if from := jd.versions[tn]; kit.Types.Version(k.Type()) > from {
+			return jd.decodeMigrate(k, path, from)
+		}
+	}
+	off := jd.dec.InputOffset()
+	if _, ok := k.(json.Unmarshaler); ok {
+		var raw json.RawMessage
+		if err := jd.dec.Decode(&raw); err != nil {
+			return jd.wrapErr(err)
+		}
+		if err := json.Unmarshal(raw, k); err != nil {
+			return jd.errorAt(off, "node %v: %v", k.Name(), err)
+		}
+		return nil
+	}
+	t, off, err := jd.token()
+	if err != nil {
+		return err
+	}
+	if t == nil { // null
+		return nil
+	}
+	if t != json.Delim('{') {
+		return jd.errorAt(off, "expected { for node %v, got: %v", k.Name(), t)
+	}
+	var flds bytes.Buffer
+	flds.WriteByte('{')
+	for jd.dec.More() {
+		t, _, err := jd.token()
+		if err != nil {
+			return err
+		}
+		key, _ := t.(string)
+		if strings.EqualFold(key, "Kids") {
+			if err := jd.decodeSlice(k.Children(), path); err != nil {
+				return err
+			}
+			continue
+		}
+		var raw json.RawMessage
+		if err := jd.dec.Decode(&raw); err != nil {
+			return jd.wrapErr(err)
+		}
+		if flds.Len() > 1 {
+			flds.WriteByte(',')
+		}
+		kb, _ := json.Marshal(key)
+		flds.Write(kb)
+		flds.WriteByte(':')
+		flds.Write(raw)
+	}
+	if err := jd.delim('}'); err != nil {
+		return err
+	}
+	flds.WriteByte('}')
+	if err := json.Unmarshal(flds.Bytes(), k); err != nil {
+		return jd.errorAt(off, "node %v: %v", k.Name(), err)
+	}
+	return nil
+}
+
+// decodeSlice reads the next JSON value into given slice: either null or
+// the header record followed by each of the elements.  The number of
+// elements must match the header.  path is the path of the parent node.
+func (jd *jsonDecoder) decodeSlice(sl *Slice, path string) error {
+	t, off, err := jd.token()
+	if err != nil {
+		return err
+	}
+	if t == nil { // null
+		*sl = nil
+		return nil
+	}
+	if t != json.Delim('[') {
+		return jd.errorAt(off, "expected [ for Slice, got: %v", t)
+	}
+	if !jd.dec.More() {
+		return jd.delim(']')
+	}
+	tnl, err := jd.decodeSliceHeader()
+	if err != nil {
+		return err
+	}
+	sl.Config(nil, tnl, true) // true = uniq names
+	i := 0
+	for jd.dec.More() {
+		if i >= len(tnl) {
+			return jd.errorAt(jd.dec.InputOffset(), "Slice has more elements than n = %d in header", len(tnl))
+		}
+		if err := jd.decodeNode((*sl)[i], jsonKidPath(path, tnl[i].Name)); err != nil {
+			return err
+		}
+		i++
+	}
+	if i < len(tnl) {
+		return jd.errorAt(jd.dec.InputOffset(), "Slice has %d elements but n = %d in header", i, len(tnl))
+	}
+	return jd.delim(']')
+}
+
+// jsonKidPath returns the path of a kid of given name, given parent path
+func jsonKidPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "/" + name
+}
+
+// decodeSliceHeader reads the header record of a Slice, which has the
+// number of elements, n, followed by type and name pairs for each element.
+// The type and name counts must both equal n.
+func (jd *jsonDecoder) decodeSliceHeader() (kit.TypeAndNameList, error) {
+	hoff := jd.dec.InputOffset()
+	if err := jd.delim('{'); err != nil {
+		return nil, err
+	}
+	var tnl kit.TypeAndNameList
+	n, nt, nn := -1, 0, 0
+	for jd.dec.More() {
+		t, koff, err := jd.token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := t.(string)
+		t, off, err := jd.token()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "n":
+			f, ok := t.(float64)
+			if !ok || f < 0 || f != float64(int(f)) {
+				return nil, jd.errorAt(off, "Slice header n is not a count: %v", t)
+			}
+			if n >= 0 {
+				return nil, jd.errorAt(koff, "Slice header has more than one n")
+			}
+			n = int(f)
+		case "type":
+			tn, ok := t.(string)
+			if !ok {
+				return nil, jd.errorAt(off, "Slice header type is not a string: %v", t)
+			}
+			typ := kit.Types.Type(tn)
+			if typ == nil {
+				return nil, jd.errorAt(off, "kit.Types type name not found: %v", tn)
+			}
+			if nt == len(tnl) {
+				tnl = append(tnl, kit.TypeAndName{})
+			}
+			tnl[nt].Type = typ
+			nt++
+		case "name":
+			nm, ok := t.(string)
+			if !ok {
+				return nil, jd.errorAt(off, "Slice header name is not a string: %v", t)
+			}
+			if nn == len(tnl) {
+				tnl = append(tnl, kit.TypeAndName{})
+			}
+			tnl[nn].Name = nm
+			nn++
+		default:
+			return nil, jd.errorAt(koff, "unexpected Slice header key: %v", t)
+		}
+	}
+	if n < 0 {
+		return nil, jd.errorAt(hoff, "Slice header has no n")
+	}
+	if nt != n || nn != n {
+		return nil, jd.errorAt(hoff, "Slice header n = %d does not match %d types and %d names", n, nt, nn)
+	}
+	return tnl, jd.delim('}')
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJSONStreamFormat(t *testing.T) {
+	root := diffTestTree()
+	root.Embed(KiT_NodeField).(*NodeField).Field1.AddNewChild(KiT_NodeEmbed, "fieldkid")
+	root.Child(0).SetName(`quote"and,comma`)
+	root.Child(1).DeleteProp("stringprop") // Props map order is not fixed
+	for _, indent := range []bool{false, true} {
+		var buf bytes.Buffer
+		err := root.WriteJSON(&buf, indent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []byte
+		if indent {
+			want, err = json.MarshalIndent(root, "", JSONIndent)
+		} else {
+			want, err = json.Marshal(root)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		hdr := string(JSONTypePrefix) + `"ki.NodeField"` + string(JSONTypeSuffix)
+		want = append([]byte(hdr), want...)
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("streamed output (indent: %v) not same as Marshal:\n%v\nvs.\n%v\n", indent, buf.String(), string(want))
+		}
+
+		nwnd, err := ReadNewJSON(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if es, err := Diff(root, nwnd); err != nil || len(es) != 0 {
+			t.Errorf("round trip (indent: %v) diffs: %v\n%v", indent, err, es)
+		}
+		if nwnd.Child(0).Name() != `quote"and,comma` || nwnd.Child(1).Child(0).Parent() != nwnd.Child(1) {
+			t.Errorf("round trip (indent: %v) names or parents wrong", indent)
+		}
+	}
+}
+
+func TestJSONStreamOldFormat(t *testing.T) {
+	// format as written by earlier versions, with no escaping of names
+	old := `{"ki.RootType": "ki.NodeField"}
+{"Nm":"par1","UniqueNm":"par1","Props":null,"Kids":[{"n":2,"type":"ki.NodeEmbed", "name": "child1","type":"ki.NodeEmbed", "name": "child2"},{"Nm":"child1","UniqueNm":"child1","Props":null,"Kids":null,"Mbr1":"a","Mbr2":1},{"Nm":"child2","UniqueNm":"child2","Props":{"intprop":42},"Kids":[{"n":1,"type":"ki.NodeEmbed", "name": "sub"},{"Nm":"sub","UniqueNm":"sub","Props":null,"Kids":null,"Mbr1":"","Mbr2":0}],"Mbr1":"","Mbr2":2}],"Mbr1":"","Mbr2":0,"Field1":{"Nm":"Field1","UniqueNm":"Field1","Props":null,"Kids":null,"Mbr1":"","Mbr2":0}}`
+	nwnd, err := ReadNewJSON(strings.NewReader(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nwnd.NumChildren() != 2 || nwnd.Child(0).(*NodeEmbed).Mbr1 != "a" || nwnd.Child(1).Child(0).Name() != "sub" {
+		t.Errorf("old format not loaded correctly: %v", nwnd.Children())
+	}
+	if nwnd.Child(1).Prop("intprop") != float64(42) {
+		t.Errorf("old format props not loaded: %v", nwnd.Child(1).Properties())
+	}
+
+	// ReadJSON over existing tree, without type prefix
+	root := diffTestTree()
+	err = root.ReadJSON(strings.NewReader(old[strings.Index(old, "\n")+1:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if es, err := Diff(nwnd, root); err != nil || len(es) != 0 {
+		t.Errorf("ReadJSON diffs: %v\n%v", err, es)
+	}
+}
+
+func TestJSONStreamLarge(t *testing.T) {
+	root := NodeEmbed{}
+	root.InitName(&root, "root")
+	for i := 0; i < 50; i++ {
+		k := root.AddNewChild(KiT_NodeEmbed, fmt.Sprintf("k%d", i))
+		for j := 0; j < 50; j++ {
+			k.AddNewChild(KiT_NodeEmbed, fmt.Sprintf("s%d", j)).(*NodeEmbed).Mbr2 = j
+		}
+	}
+	var buf bytes.Buffer
+	err := root.WriteJSON(&buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nwnd := NodeEmbed{}
+	nwnd.InitName(&nwnd, "root")
+	err = nwnd.ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if es, err := Diff(root.This(), nwnd.This()); err != nil || len(es) != 0 {
+		t.Errorf("round trip diffs: %v %v", err, len(es))
+	}
+}
+
+func TestJSONStreamConcurrent(t *testing.T) {
+	root := diffTestTree()
+	root.Embed(KiT_NodeField).(*NodeField).Field1.AddNewChild(KiT_NodeEmbed, "fieldkid")
+	root.Child(1).DeleteProp("stringprop") // Props map order is not fixed
+	NewTreeLock(root)
+	nk := root.NumChildren()
+	var want bytes.Buffer
+	if err := root.WriteJSON(&want, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				var buf bytes.Buffer
+				root.WriteJSON(&buf, false)
+				if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+					t.Errorf("concurrent save output differs")
+				}
+				if n := root.NumChildren(); n != nk {
+					t.Errorf("save changed number of children: %v", n)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSliceUnmarshalJSON(t *testing.T) {
+	names := []string{`a,b`, `{br}aces}`, `esc"aped\"quote`, `uni,"}{[`}
+	var sl Slice
+	for _, nm := range names {
+		sl = append(sl, NewOfType(KiT_NodeEmbed))
+		sl[len(sl)-1].InitName(sl[len(sl)-1], nm)
+	}
+	b, err := json.Marshal(sl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nsl Slice
+	err = json.Unmarshal(b, &nsl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nsl) != len(names) {
+		t.Fatalf("expected %d elements, got: %d", len(names), len(nsl))
+	}
+	for i, nm := range names {
+		if nsl[i].Name() != nm || nsl[i].Type() != KiT_NodeEmbed {
+			t.Errorf("element %d: expected name %q, got: %q", i, nm, nsl[i].Name())
+		}
+	}
+	err = json.Unmarshal([]byte(`[{"n":0}]`), &nsl)
+	if err != nil || len(nsl) != 0 {
+		t.Errorf("empty header: %v %v", err, len(nsl))
+	}
+
+	tests := []struct {
+		js  string
+		off string
+	}{
+		{`[{"n":2,"type":"ki.NodeEmbed","name":"a"},{}]`, "offset 1:"},
+		{`[{"n":1,"type":"ki.NodeEmbed","name":"a","type":"ki.NodeEmbed","name":"b"},{},{}]`, "offset 1:"},
+		{`[{"n":1,"type":"ki.NodeEmbed","name":"a"}]`, "offset 41:"},
+		{`[{"n":1,"type":"ki.NodeEmbed","name":"a"},{},{}]`, "offset 44:"},
+		{`[{"type":"ki.NodeEmbed","name":"a"},{}]`, "offset 1:"},
+		{`[{"n":1.5,"type":"ki.NodeEmbed","name":"a"},{}]`, "offset 5:"},
+		{`[{"n":1,"type":"ki.NoSuchType","name":"a"},{}]`, "offset 14:"},
+		{`[{"n":1,"type":"ki.NodeEmbed","name":3},{}]`, "offset 36:"},
+		{`[{"n":1,"type":"ki.NodeEmbed","nm":"a"},{}]`, "offset 29:"},
+		{`[{"n":1,"type":"ki.NodeEmbed","name":"a"},{"Mbr2":"x"}]`, "offset 41:"},
+		{`[{"n":1,"type":"ki.NodeEmbed","name":"a"},[]]`, "offset 41:"},
+	}
+	for _, ts := range tests {
+		var esl Slice
+		err := esl.UnmarshalJSON([]byte(ts.js))
+		if err == nil {
+			t.Errorf("expected error for: %v", ts.js)
+		} else if !strings.Contains(err.Error(), ts.off) {
+			t.Errorf("expected %v in error for: %v, got: %v", ts.off, ts.js, err)
+		}
+	}
+}
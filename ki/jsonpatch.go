@@ -0,0 +1,770 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/goki/ki/kit"
+)
+
+// JSONPatchOp is one operation in an RFC 6902 JSON Patch document.
+type JSONPatchOp struct {
+	Op    string          `json:"op" desc:"operation: add, remove, replace, move, copy or test"`
+	Path  string          `json:"path" desc:"JSON Pointer to the target location"`
+	From  string          `json:"from,omitempty" desc:"JSON Pointer to the source location, for move and copy"`
+	Value json.RawMessage `json:"value,omitempty" desc:"value for add, replace and test"`
+}
+
+// JSONPatch is an RFC 6902 JSON Patch document, which applies to a Ki tree
+// by mapping each reference token of a JSON Pointer (RFC 6901) as follows,
+// starting at the root node passed to Apply:
+//
+//   - A token containing . uses the FindPathUnique syntax for Ki fields:
+//     "kid.Field1" is the Ki field Field1 of child kid, and ".Field1" is the
+//     Ki field of the current node.
+//
+//   - Otherwise, the child with that UniqueName, or at [idx] (as in
+//     FindPathUnique), or a Ki field of that name.  As the last token, "-"
+//     refers to the end of the children, for add.
+//
+//   - Otherwise, an exported struct field of the node (other than those of
+//     Node itself) -- any further tokens index into struct fields, map keys
+//     and slice / array elements (with "-" for the end of a slice) as usual.
+//
+//   - Otherwise, "Props" followed by a key, or just a key already present
+//     in the Props, refers to that property, and any further tokens index
+//     into the property value.
+//
+//   - The last token of an add that does not exist yet is a new child if
+//     the value is a JSON object with a "type" key (the kit.Types name of
+//     the type of node to create -- the rest of the object is the JSON of
+//     the node), and a new property otherwise.
+//
+// Children referred to by name have JSON object semantics (add replaces an
+// existing child), while children referred to by [idx] or "-" have array
+// semantics (add inserts).  Move and copy of children operate on the nodes
+// themselves (copy uses Clone).
+type JSONPatch []JSONPatchOp
+
+// ApplyJSONPatch decodes the given RFC 6902 JSON Patch document and
+// applies it to the tree at root -- see JSONPatch for details.
+func ApplyJSONPatch(root Ki, patch []byte) error {
+	var jp JSONPatch
+	if err := json.Unmarshal(patch, &jp); err != nil {
+		return fmt.Errorf("ki.ApplyJSONPatch: %v", err)
+	}
+	return jp.Apply(root)
+}
+
+// Apply applies the patch to the tree at root, within a single UpdateStart /
+// UpdateEnd.  Values are converted to the target type using kit.SetRobust,
+// falling back on JSON decoding for composite values.  Application is
+// atomic: if any operation fails, all of the changes made by the patch are
+// rolled back and the error is returned.  Rollback uses the UndoStack of the
+// tree, if any (where the patch is then recorded as part of the current
+// transaction), and otherwise a temporary one.
+func (jp JSONPatch) Apply(root Ki) error {
+	us := UndoStackFor(root)
+	tmp := us == nil
+	if tmp {
+		us = NewUndoStack(root.Root(), 0)
+	}
+	updt := root.UpdateStart()
+	mark := us.mark(root)
+	var err error
+	for i := range jp {
+		op := &jp[i]
+		if err = op.apply(root); err != nil {
+			err = fmt.Errorf("ki.JSONPatch: op %v: %v %v: %v", i, op.Op, op.Path, err)
+			us.rollback(mark)
+			break
+		}
+	}
+	root.UpdateEnd(updt)
+	us.end(root)
+	if tmp {
+		us.Close()
+	}
+	return err
+}
+
+// apply applies the one operation
+func (op *JSONPatchOp) apply(root Ki) error {
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("missing value")
+		}
+		t, err := jpResolve(root, op.Path, op.Op == "add" && jpIsNode(op.Value))
+		if err != nil {
+			return err
+		}
+		switch op.Op {
+		case "add":
+			return t.add(op.Value)
+		case "replace":
+			return t.replace(op.Value)
+		}
+		return t.test(op.Value)
+	case "remove":
+		t, err := jpResolve(root, op.Path, false)
+		if err != nil {
+			return err
+		}
+		return t.remove()
+	case "move", "copy":
+		return op.moveCopy(root)
+	}
+	return fmt.Errorf("invalid op: %v", op.Op)
+}
+
+// moveCopy does the move and copy operations
+func (op *JSONPatchOp) moveCopy(root Ki) error {
+	if op.Op == "move" {
+		if op.From == op.Path {
+			return nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return fmt.Errorf("cannot move to a child of from: %v", op.From)
+		}
+	}
+	ft, err := jpResolve(root, op.From, false)
+	if err != nil {
+		return err
+	}
+	if (ft.kind == jpChild && ft.kid != nil) || (ft.kind == jpNode && op.Op == "copy") { // nodes are moved / copied directly
+		kid := ft.kid
+		if ft.kind == jpNode {
+			kid = ft.node
+		}
+		if op.Op == "move" {
+			if err := ft.node.DeleteChildAtIndex(ft.idx, false); err != nil {
+				return err
+			}
+		} else {
+			kid = kid.Clone()
+		}
+		t, err := jpResolve(root, op.Path, true)
+		if err != nil {
+			return err
+		}
+		if t.kind != jpChild {
+			return fmt.Errorf("node can only be moved or copied to a child location")
+		}
+		return t.insertKid(kid)
+	}
+	val, err := ft.get()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	if op.Op == "move" {
+		if err := ft.remove(); err != nil {
+			return err
+		}
+	}
+	t, err := jpResolve(root, op.Path, false)
+	if err != nil {
+		return err
+	}
+	return t.add(raw)
+}
+
+// jpTargKinds are the kinds of locations a JSON Pointer can refer to
+type jpTargKinds int
+
+const (
+	// jpNode is a node that is not in a child slot -- root or Ki field
+	jpNode jpTargKinds = iota
+
+	// jpChild is a child slot, which may be empty (kid == nil) for add
+	jpChild
+
+	// jpValue is a value within a field or property
+	jpValue
+
+	// jpElem is an element of a slice or array
+	jpElem
+
+	// jpMapKey is a key of a map
+	jpMapKey
+
+	// jpProp is a property
+	jpProp
+)
+
+// jpTarget is a location referred to by a JSON Pointer
+type jpTarget struct {
+	kind  jpTargKinds
+	node  Ki            // node itself for jpNode, parent for jpChild, else node containing the value
+	kid   Ki            // jpChild: existing child, or nil
+	idx   int           // jpChild, jpElem: index
+	byIdx bool          // jpChild: referred to by index, with array semantics
+	name  string        // jpChild: name, if referred to by name -- jpProp: key
+	val   reflect.Value // jpValue: the value -- jpElem: the slice -- jpMapKey: the map
+	key   reflect.Value // jpMapKey: the key
+	top   reflect.Value // top-level struct field containing the value, if any -- for undo
+}
+
+// jpIsNode returns true if the value is an object with a "type" key,
+// specifying a new node
+func jpIsNode(raw json.RawMessage) bool {
+	var hdr map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &hdr); err != nil {
+		return false
+	}
+	_, ok := hdr["type"]
+	return ok
+}
+
+// jpField returns the exported, non-Node, non-Ki struct field of given name
+func jpField(k Ki, nm string) reflect.Value {
+	if nodeFieldNames[nm] {
+		return reflect.Value{}
+	}
+	f, ok := kit.FlatFieldByName(k.Type(), nm)
+	if !ok || f.PkgPath != "" {
+		return reflect.Value{}
+	}
+	return kit.FlatFieldValueByName(k, nm)
+}
+
+// jpIndex parses a JSON Pointer array index, with - = n
+func jpIndex(tok string, n int) (int, bool) {
+	if tok == "-" {
+		return n, true
+	}
+	idx, err := strconv.Atoi(tok)
+	if err != nil || idx < 0 || idx > n {
+		return 0, false
+	}
+	return idx, true
+}
+
+// jpResolve returns the target for given JSON Pointer -- newKid indicates
+// that a non-existent last token is a new child, otherwise a new property.
+func jpResolve(root Ki, ptr string, newKid bool) (*jpTarget, error) {
+	if ptr == "" {
+		return &jpTarget{kind: jpNode, node: root}, nil
+	}
+	if ptr[0] != '/' {
+		return nil, fmt.Errorf("JSON Pointer must start with /: %v", ptr)
+	}
+	toks := strings.Split(ptr[1:], "/")
+	for i := range toks {
+		toks[i] = strings.Replace(strings.Replace(toks[i], "~1", "/", -1), "~0", "~", -1)
+	}
+	curn := root
+	for i, tok := range toks {
+		last := i == len(toks)-1
+		if strings.Contains(tok, ".") {
+			fels := strings.Split(tok, ".")
+			if fels[0] != "" {
+				idx, ok := findPathChild(curn, fels[0])
+				if !ok {
+					return nil, fmt.Errorf("child: %v not found", fels[0])
+				}
+				curn = (*curn.Children())[idx]
+			}
+			for _, fe := range fels[1:] {
+				fk := curn.KiFieldByName(fe)
+				if fk == nil {
+					return nil, fmt.Errorf("Ki field: %v not found", fe)
+				}
+				curn = fk
+			}
+			if last {
+				return &jpTarget{kind: jpNode, node: curn}, nil
+			}
+			continue
+		}
+		if tok == "" {
+			return nil, fmt.Errorf("empty reference token")
+		}
+		byIdx := tok[0] == '['
+		if idx, ok := findPathChild(curn, tok); ok {
+			kid := (*curn.Children())[idx]
+			if last {
+				t := &jpTarget{kind: jpChild, node: curn, kid: kid, idx: idx, byIdx: byIdx}
+				if !byIdx {
+					t.name = kid.Name()
+				}
+				return t, nil
+			}
+			curn = kid
+			continue
+		}
+		if fk := curn.KiFieldByName(tok); fk != nil {
+			if last {
+				return &jpTarget{kind: jpNode, node: fk}, nil
+			}
+			curn = fk
+			continue
+		}
+		if fv := jpField(curn, tok); fv.IsValid() {
+			return jpResolveValue(&jpTarget{kind: jpValue, node: curn, val: fv, top: fv}, toks[i+1:])
+		}
+		isProp := tok == "Props" && !last
+		if isProp {
+			i++
+			tok = toks[i]
+			last = i == len(toks)-1
+		}
+		if pv, has := (*curn.Properties())[tok]; has || (last && (isProp || !newKid)) {
+			if last {
+				return &jpTarget{kind: jpProp, node: curn, name: tok}, nil
+			}
+			return jpResolveValue(&jpTarget{kind: jpValue, node: curn, val: reflect.ValueOf(pv)}, toks[i+1:])
+		}
+		if last {
+			nk := curn.NumChildren()
+			switch {
+			case tok == "-" || tok == fmt.Sprintf("[%v]", nk):
+				return &jpTarget{kind: jpChild, node: curn, idx: nk, byIdx: true}, nil
+			case !byIdx:
+				return &jpTarget{kind: jpChild, node: curn, idx: nk, name: tok}, nil
+			}
+		}
+		return nil, fmt.Errorf("%v not found", tok)
+	}
+	return nil, fmt.Errorf("invalid path: %v", ptr)
+}
+
+// jpResolveValue resolves the remaining tokens within a value
+func jpResolveValue(t *jpTarget, toks []string) (*jpTarget, error) {
+	for i, tok := range toks {
+		last := i == len(toks)-1
+		v := t.val
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, fmt.Errorf("%v: nil value", tok)
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			f, ok := v.Type().FieldByName(tok)
+			if !ok || f.PkgPath != "" {
+				return nil, fmt.Errorf("field: %v not found", tok)
+			}
+			t.val = v.FieldByIndex(f.Index)
+		case reflect.Map:
+			key := reflect.New(v.Type().Key())
+			if !kit.SetRobust(key.Interface(), tok) {
+				return nil, fmt.Errorf("invalid map key: %v", tok)
+			}
+			if last {
+				t.kind = jpMapKey
+				t.val = v
+				t.key = key.Elem()
+				return t, nil
+			}
+			mv := v.MapIndex(key.Elem())
+			if !mv.IsValid() {
+				return nil, fmt.Errorf("map key: %v not found", tok)
+			}
+			t.val = mv
+		case reflect.Slice, reflect.Array:
+			idx, ok := jpIndex(tok, v.Len())
+			if !ok {
+				return nil, fmt.Errorf("invalid index: %v", tok)
+			}
+			if last {
+				t.kind = jpElem
+				t.val = v
+				t.idx = idx
+				return t, nil
+			}
+			if idx == v.Len() {
+				return nil, fmt.Errorf("index out of range: %v", tok)
+			}
+			t.val = v.Index(idx)
+		default:
+			return nil, fmt.Errorf("cannot index %v into value of type: %v", tok, v.Type())
+		}
+	}
+	return t, nil
+}
+
+// record records the current value of the target for rollback / undo,
+// prior to changing it.
+func (t *jpTarget) record() error {
+	us := undoRecorder(t.node)
+	switch {
+	case t.top.IsValid():
+		if us != nil {
+			us.record(&undoField{k: t.node, fv: t.top, val: deepCopyValue(t.top)})
+		}
+	case t.kind == jpMapKey:
+		if us != nil {
+			cur := t.val.MapIndex(t.key)
+			us.record(&undoMapKey{k: t.node, m: t.val, key: t.key, val: cur, has: cur.IsValid()})
+		}
+	default:
+		return fmt.Errorf("value cannot be set")
+	}
+	return nil
+}
+
+// get returns the current value at the target
+func (t *jpTarget) get() (interface{}, error) {
+	switch t.kind {
+	case jpNode:
+		return t.node, nil
+	case jpChild:
+		if t.kid == nil {
+			return nil, fmt.Errorf("child not found")
+		}
+		return t.kid, nil
+	case jpValue:
+		return t.val.Interface(), nil
+	case jpElem:
+		if t.idx >= t.val.Len() {
+			return nil, fmt.Errorf("index out of range: %v", t.idx)
+		}
+		return t.val.Index(t.idx).Interface(), nil
+	case jpMapKey:
+		mv := t.val.MapIndex(t.key)
+		if !mv.IsValid() {
+			return nil, fmt.Errorf("map key: %v not found", t.key)
+		}
+		return mv.Interface(), nil
+	case jpProp:
+		pv, has := (*t.node.Properties())[t.name]
+		if !has {
+			return nil, fmt.Errorf("property: %v not found", t.name)
+		}
+		return pv, nil
+	}
+	return nil, fmt.Errorf("invalid target")
+}
+
+// add does the add operation on the target
+func (t *jpTarget) add(raw json.RawMessage) error {
+	switch t.kind {
+	case jpChild:
+		if t.kid != nil && !t.byIdx {
+			return t.replace(raw)
+		}
+		return t.newKid(raw)
+	case jpValue:
+		if err := t.record(); err != nil {
+			return err
+		}
+		return jpSetValue(t.val, raw)
+	case jpElem:
+		if t.val.Kind() != reflect.Slice || !t.val.CanSet() {
+			return fmt.Errorf("cannot add element to: %v", t.val.Type())
+		}
+		nv := reflect.New(t.val.Type().Elem()).Elem()
+		if err := jpSetValue(nv, raw); err != nil {
+			return err
+		}
+		if err := t.record(); err != nil {
+			return err
+		}
+		n := t.val.Len()
+		ns := reflect.MakeSlice(t.val.Type(), n+1, n+1)
+		reflect.Copy(ns, t.val.Slice(0, t.idx))
+		ns.Index(t.idx).Set(nv)
+		reflect.Copy(ns.Slice(t.idx+1, n+1), t.val.Slice(t.idx, n))
+		t.val.Set(ns)
+		return nil
+	case jpMapKey:
+		nv := reflect.New(t.val.Type().Elem()).Elem()
+		if err := jpSetValue(nv, raw); err != nil {
+			return err
+		}
+		if t.val.IsNil() {
+			return fmt.Errorf("cannot add key to nil map")
+		}
+		if err := t.record(); err != nil {
+			return err
+		}
+		t.val.SetMapIndex(t.key, nv)
+		return nil
+	case jpProp:
+		val, err := t.propValue(raw)
+		if err != nil {
+			return err
+		}
+		t.node.SetProp(t.name, val)
+		t.node.SetFlag(int(PropUpdated))
+		return nil
+	}
+	return fmt.Errorf("can only replace or test a node that is not a child")
+}
+
+// replace does the replace operation on the target
+func (t *jpTarget) replace(raw json.RawMessage) error {
+	switch t.kind {
+	case jpChild:
+		if t.kid == nil {
+			return fmt.Errorf("child not found")
+		}
+		if err := t.node.DeleteChildAtIndex(t.idx, true); err != nil {
+			return err
+		}
+		if t.name == "" {
+			t.name = t.kid.Name()
+		}
+		t.kid = nil
+		return t.newKid(raw)
+	case jpNode:
+		return fmt.Errorf("can only test a node that is not a child")
+	case jpValue:
+		return t.add(raw)
+	case jpElem:
+		if t.idx >= t.val.Len() {
+			return fmt.Errorf("index out of range: %v", t.idx)
+		}
+		nv := reflect.New(t.val.Type().Elem()).Elem()
+		if err := jpSetValue(nv, raw); err != nil {
+			return err
+		}
+		if !t.val.CanSet() {
+			return fmt.Errorf("value of type %v cannot be set", t.val.Type())
+		}
+		if err := t.record(); err != nil {
+			return err
+		}
+		if t.val.Kind() == reflect.Array {
+			t.val.Index(t.idx).Set(nv)
+			return nil
+		}
+		ns := reflect.MakeSlice(t.val.Type(), t.val.Len(), t.val.Len())
+		reflect.Copy(ns, t.val)
+		ns.Index(t.idx).Set(nv)
+		t.val.Set(ns)
+		return nil
+	}
+	if _, err := t.get(); err != nil {
+		return err
+	}
+	return t.add(raw)
+}
+
+// remove does the remove operation on the target
+func (t *jpTarget) remove() error {
+	if _, err := t.get(); err != nil {
+		return err
+	}
+	switch t.kind {
+	case jpChild:
+		return t.node.DeleteChildAtIndex(t.idx, true)
+	case jpElem:
+		if t.val.Kind() != reflect.Slice || !t.val.CanSet() {
+			return fmt.Errorf("cannot remove element from: %v", t.val.Type())
+		}
+		if err := t.record(); err != nil {
+			return err
+		}
+		n := t.val.Len()
+		ns := reflect.MakeSlice(t.val.Type(), n-1, n-1)
+		reflect.Copy(ns, t.val.Slice(0, t.idx))
+		reflect.Copy(ns.Slice(t.idx, n-1), t.val.Slice(t.idx+1, n))
+		t.val.Set(ns)
+		return nil
+	case jpMapKey:
+		if err := t.record(); err != nil {
+			return err
+		}
+		t.val.SetMapIndex(t.key, reflect.Value{})
+		return nil
+	case jpProp:
+		t.node.DeleteProp(t.name)
+		t.node.SetFlag(int(PropUpdated))
+		return nil
+	}
+	return fmt.Errorf("cannot remove a node that is not a child, or a struct field")
+}
+
+// test does the test operation on the target
+func (t *jpTarget) test(raw json.RawMessage) error {
+	cur, err := t.get()
+	if err != nil {
+		return err
+	}
+	cb, err := json.Marshal(cur)
+	if err != nil {
+		return err
+	}
+	var cv, tv interface{}
+	json.Unmarshal(cb, &cv)
+	if err := json.Unmarshal(raw, &tv); err != nil {
+		return err
+	}
+	if t.kind == jpNode || t.kind == jpChild {
+		if tm, ok := tv.(map[string]interface{}); ok {
+			delete(tm, "type")
+		}
+	}
+	if !reflect.DeepEqual(cv, tv) {
+		return fmt.Errorf("test failed: value is: %v", string(cb))
+	}
+	return nil
+}
+
+// newKid adds a new child at the (empty) target, from given JSON value
+func (t *jpTarget) newKid(raw json.RawMessage) error {
+	var hdr struct {
+		Type string `json:"type"`
+		Nm   string
+	}
+	if err := json.Unmarshal(raw, &hdr); err != nil {
+		return err
+	}
+	var typ reflect.Type
+	if hdr.Type != "" {
+		typ = kit.Types.Type(hdr.Type)
+		if typ == nil {
+			return fmt.Errorf("type %v not registered in kit.Types", hdr.Type)
+		}
+	}
+	name := t.name
+	if name == "" {
+		name = hdr.Nm
+	}
+	if name == "" {
+		return fmt.Errorf("new child must have a name")
+	}
+	unm := SafeUniqueName(name)
+	if _, ok := t.node.Children().IndexByUniqueName(unm, 0); ok {
+		return fmt.Errorf("child: %v already exists", unm)
+	}
+	kid := t.node.InsertNewChild(typ, t.idx, name)
+	if kid == nil {
+		return fmt.Errorf("could not create child")
+	}
+	if err := json.Unmarshal(raw, kid); err != nil {
+		return err
+	}
+	kid.SetNameRaw(name)
+	kid.SetUniqueName(unm)
+	kid.UnmarshalPost()
+	return nil
+}
+
+// insertKid inserts an existing node at the target child slot, for move
+// and copy.
+func (t *jpTarget) insertKid(kid Ki) error {
+	if t.kid != nil && !t.byIdx {
+		if err := t.node.DeleteChildAtIndex(t.idx, true); err != nil {
+			return err
+		}
+	}
+	if err := t.node.InsertChild(kid, t.idx); err != nil {
+		return err
+	}
+	kid.ClearFlag(int(NodeDeleted))
+	if t.name != "" {
+		kid.SetName(t.name) // after insert so it is recorded
+	}
+	return nil
+}
+
+// propValue returns the value for the property target from given JSON,
+// converting to the type of any existing value using kit.SetRobust, and
+// otherwise decoding using Props JSON decoding, which restores typed values
+func (t *jpTarget) propValue(raw json.RawMessage) (interface{}, error) {
+	if cur, has := (*t.node.Properties())[t.name]; has && cur != nil {
+		nv := reflect.New(reflect.TypeOf(cur)).Elem()
+		if err := jpSetValue(nv, raw); err == nil {
+			return nv.Interface(), nil
+		}
+	}
+	kb, _ := json.Marshal(t.name)
+	pb := append(append(append([]byte("{"), kb...), ':'), raw...)
+	pb = append(pb, '}')
+	var pv Props
+	if err := json.Unmarshal(pb, &pv); err != nil {
+		return nil, err
+	}
+	return pv[t.name], nil
+}
+
+// jpSetValue sets the value from JSON, using kit.SetRobust for basic values
+// and JSON decoding otherwise
+func jpSetValue(v reflect.Value, raw json.RawMessage) error {
+	if !v.CanSet() {
+		return fmt.Errorf("value of type %v cannot be set", v.Type())
+	}
+	var dv interface{}
+	if err := json.Unmarshal(raw, &dv); err != nil {
+		return err
+	}
+	if dv == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Interface {
+		v.Set(reflect.ValueOf(dv))
+		return nil
+	}
+	switch dv.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		if kit.SetRobust(kit.PtrValue(v).Interface(), dv) {
+			return nil
+		}
+	}
+	nv := reflect.New(v.Type())
+	if err := json.Unmarshal(raw, nv.Interface()); err != nil {
+		return err
+	}
+	v.Set(nv.Elem())
+	return nil
+}
+
+// deepCopyValue returns a copy of given value that shares no slices or
+// maps with it, so it can be restored after those have been modified.
+func deepCopyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		nv := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			nv.Index(i).Set(deepCopyValue(v.Index(i)))
+		}
+		return nv
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		nv := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			nv.SetMapIndex(k, deepCopyValue(v.MapIndex(k)))
+		}
+		return nv
+	case reflect.Struct, reflect.Array:
+		nv := reflect.New(v.Type()).Elem()
+		nv.Set(v)
+		if v.Kind() == reflect.Array {
+			for i := 0; i < v.Len(); i++ {
+				nv.Index(i).Set(deepCopyValue(v.Index(i)))
+			}
+			return nv
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if nv.Field(i).CanSet() {
+				nv.Field(i).Set(deepCopyValue(v.Field(i)))
+			}
+		}
+		return nv
+	}
+	nv := reflect.New(v.Type()).Elem()
+	nv.Set(v)
+	return nv
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONPatch(t *testing.T) {
+	root := diffTestTree()
+	patch := []byte(`[
+	{"op": "test", "path": "/child2/Props/intprop", "value": 42},
+	{"op": "replace", "path": "/child2/Mbr1", "value": "patched"},
+	{"op": "replace", "path": "/child2/Mbr2", "value": "17"},
+	{"op": "add", "path": "/child2/intprop", "value": 43},
+	{"op": "remove", "path": "/child2/stringprop"},
+	{"op": "add", "path": "/child2/newprop", "value": "new"},
+	{"op": "move", "from": "/child2/subchild1", "path": "/child3/moved"},
+	{"op": "copy", "from": "/child2/subchild2", "path": "/child3/-"},
+	{"op": "replace", "path": "/.Field1/Mbr2", "value": 5},
+	{"op": "replace", "path": "/child4.Field1/Mbr1", "value": "field"},
+	{"op": "remove", "path": "/[0]"}
+]`)
+	err := ApplyJSONPatch(root, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.NumChildren() != 3 || root.Child(0).Name() != "child2" {
+		t.Errorf("remove child failed")
+	}
+	c2 := root.ChildByName("child2", 0).(*NodeEmbed)
+	if c2.Mbr1 != "patched" || c2.Mbr2 != 17 {
+		t.Errorf("replace field failed: %v %v", c2.Mbr1, c2.Mbr2)
+	}
+	if c2.Prop("intprop") != 43 || c2.Prop("stringprop") != nil || c2.Prop("newprop") != "new" {
+		t.Errorf("props failed: %v", c2.Props)
+	}
+	if c2.NumChildren() != 1 {
+		t.Errorf("move child failed")
+	}
+	c3 := root.ChildByName("child3", 0)
+	if c3.NumChildren() != 2 || c3.Child(0).Name() != "moved" || c3.Child(1).Name() != "subchild2" {
+		t.Errorf("move / copy child failed: %v", c3.Children())
+	}
+	if c3.Child(0).Parent() != c3 {
+		t.Errorf("moved child not parented")
+	}
+	if root.Embed(KiT_NodeField).(*NodeField).Field1.Mbr2 != 5 {
+		t.Errorf("Ki field replace failed")
+	}
+	if root.ChildByName("child4", 0).Embed(KiT_NodeField).(*NodeField).Field1.Mbr1 != "field" {
+		t.Errorf("child Ki field replace failed")
+	}
+}
+
+func TestJSONPatchNewChild(t *testing.T) {
+	root := diffTestTree()
+	err := ApplyJSONPatch(root, []byte(`[
+	{"op": "add", "path": "/child1/[0]", "value": {"type": "ki.NodeEmbed", "Nm": "gkid", "Mbr2": 3}},
+	{"op": "add", "path": "/child1/gkid2", "value": {"type": "ki.NodeEmbed", "Mbr1": "two"}},
+	{"op": "test", "path": "/child1/gkid2/Mbr1", "value": "two"}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1 := root.ChildByName("child1", 0)
+	if c1.NumChildren() != 2 || c1.Child(0).Name() != "gkid" || c1.Child(1).Name() != "gkid2" {
+		t.Errorf("add child failed: %v", c1.Children())
+	}
+	if c1.Child(0).(*NodeEmbed).Mbr2 != 3 || c1.Child(0).Parent() != c1 {
+		t.Errorf("add child value failed")
+	}
+}
+
+func TestJSONPatchRollback(t *testing.T) {
+	root := diffTestTree()
+	c2 := root.ChildByName("child2", 0)
+	start := root.Clone()
+	patch := []byte(`[
+	{"op": "replace", "path": "/child2/Mbr1", "value": "patched"},
+	{"op": "add", "path": "/child2/intprop", "value": 43},
+	{"op": "remove", "path": "/child2/stringprop"},
+	{"op": "remove", "path": "/child1"},
+	{"op": "move", "from": "/child2/subchild1", "path": "/child3/moved"},
+	{"op": "add", "path": "/child3/[0]", "value": {"type": "ki.NodeEmbed", "Nm": "gkid"}},
+	{"op": "test", "path": "/child2/Mbr2", "value": 99}
+]`)
+	err := ApplyJSONPatch(root, patch)
+	if err == nil {
+		t.Errorf("expected test failure")
+	}
+	if es, err := Diff(start, root); err != nil || len(es) != 0 {
+		t.Errorf("rollback failed, diffs: %v\n%v", err, es)
+	}
+	if root.ChildByName("child2", 0) != c2 || c2.Child(0).Parent() != c2 {
+		t.Errorf("rollback should restore the original nodes")
+	}
+	if UndoStackFor(root) != nil {
+		t.Errorf("temporary undo stack should be closed")
+	}
+
+	// with an undo stack, the patch is one transaction
+	us := NewUndoStack(root, 0)
+	defer us.Close()
+	err = ApplyJSONPatch(root, []byte(`[{"op": "remove"`))
+	if err == nil {
+		t.Errorf("expected invalid JSON error")
+	}
+	err = ApplyJSONPatch(root, []byte(`[
+	{"op": "replace", "path": "/child2/Mbr1", "value": "patched"},
+	{"op": "remove", "path": "/child1"}
+]`))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(us.Undos) != 1 {
+		t.Errorf("expected 1 undo transaction, got: %v", len(us.Undos))
+	}
+	us.Undo()
+	if es, err := Diff(start, root); err != nil || len(es) != 0 {
+		t.Errorf("undo failed, diffs: %v\n%v", err, es)
+	}
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	root := diffTestTree()
+	for _, ps := range []string{
+		`[{"op": "remove", "path": "/nosuch"}]`,
+		`[{"op": "remove", "path": "/child2/Mbr1"}]`,
+		`[{"op": "replace", "path": "/child2/Mbr2", "value": "abc"}]`,
+		`[{"op": "add", "path": "/child2/Mbr1"}]`,
+		`[{"op": "bogus", "path": "/child2"}]`,
+		`[{"op": "add", "path": "/child1/new", "value": {"type": "ki.NoSuchType"}}]`,
+		`[{"op": "move", "from": "/child2", "path": "/child2/sub"}]`,
+	} {
+		if err := ApplyJSONPatch(root, []byte(ps)); err == nil {
+			t.Errorf("expected error for: %v", ps)
+		}
+	}
+}
+
+func TestJSONPatchKiField(t *testing.T) {
+	root := diffTestTree()
+	c4 := root.ChildByName("child4", 0).(*NodeField)
+	c4.Field1.Mbr1 = "field"
+	fb, err := json.Marshal(&c4.Field1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ApplyJSONPatch(root, []byte(`[
+	{"op": "test", "path": "/child4/Field1", "value": `+string(fb)+`},
+	{"op": "copy", "from": "/child4/Field1", "path": "/child3/fcopy"}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, ok := root.ChildByName("child3", 0).ChildByName("fcopy", 0).(*NodeEmbed)
+	if !ok || fc.Mbr1 != "field" {
+		t.Errorf("copy of Ki field failed: %v", fc)
+	}
+	cb, _ := json.Marshal(c4)
+	err = ApplyJSONPatch(root, []byte(`[{"op": "test", "path": "/child4/Field1", "value": `+string(cb)+`}]`))
+	if err == nil {
+		t.Errorf("test of Ki field should not match its parent")
+	}
+}
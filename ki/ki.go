@@ -5,6 +5,8 @@
 package ki
 
 import (
+	"context"
+	"errors"
 	"io"
 	"log"
 	"reflect"
@@ -127,6 +129,15 @@ type Ki interface {
 	// unique -- should generally only be used by UniquifyNames.
 	SetUniqueName(name string)
 
+	// ID returns the stable identifier of this node (Node.Uid), which is
+	// preserved through save and load, and is not changed by renaming -- 0
+	// if none has been allocated (see NodeIDs, IDIndex).
+	ID() uint64
+
+	// SetID sets the stable identifier of this node, returning an error if it
+	// is already used by another node in the IDIndex of its tree.
+	SetID(id uint64) error
+
 	// UniquifyNames ensures all of my children have unique, non-empty names
 	// -- duplicates are named sequentially _1, _2 etc, and empty names get a
 	// name based on my name or my type name.
@@ -295,6 +306,13 @@ type Ki interface {
 	// to this node is subtracted from the start of the path if present there.
 	// There is also support for [idx] index-based access for any given path
 	// element, for cases when indexes are more useful than names.
+	// Path elements can also be . (this node), .. (parent), * or glob patterns
+	// (e.g., "but*", matched against unique names, and Ki field names), and
+	// ** (this node and all nodes below it) -- if there are multiple matches,
+	// the first in depth-first order is returned -- see FindPaths for all.
+	// A path element that is exactly the unique name of a child (or name of a
+	// Ki field) always matches just that node, even if it contains glob
+	// characters, so literal names such as "a[b]" still resolve.
 	// Returns nil if not found.
 	FindPathUnique(path string) Ki
 
@@ -306,6 +324,25 @@ type Ki interface {
 	// Returns error if not found.
 	FindPathUniqueTry(path string) (Ki, error)
 
+	// FindPaths returns all Ki objects that match given unique path, starting
+	// from this node, in depth-first order -- see FindPathUnique for the path
+	// syntax, including . .. * ** and glob patterns.  Returns nil if none.
+	FindPaths(path string) []Ki
+
+	// QueryOne returns the first node within the subtree below this node (in
+	// depth-first order, not including this node) that matches given
+	// CSS-style selector, e.g., "Frame > Button.primary[Nm^=ok]" -- see
+	// Selector for the syntax.  Compiled selectors are cached.  Returns nil
+	// if not found, or if the selector is invalid (error is logged).
+	QueryOne(sel string) Ki
+
+	// QueryAll returns all nodes within the subtree below this node (in
+	// depth-first order, not including this node) that match given
+	// CSS-style selector -- see Selector for the syntax.  Compiled selectors
+	// are cached.  Returns nil if none match, or if the selector is invalid
+	// (error is logged).
+	QueryAll(sel string) []Ki
+
 	//////////////////////////////////////////////////////////////////////////
 	//  Adding, Inserting Children
 
@@ -424,7 +461,9 @@ type Ki interface {
 	// SetParent(nil), so to transfer to another list, set new parent first --
 	// destroy will add removed child to deleted list, to be destroyed later
 	// -- otherwise child remains intact but parent is nil -- could be
-	// inserted elsewhere.
+	// inserted elsewhere.  If child's parent = this node, it is first sent a
+	// NodeSignalDeleteQuery, and any receiver can veto the deletion, in which
+	// case an error is returned.
 	DeleteChildAtIndex(idx int, destroy bool) error
 
 	// DeleteChild deletes child node, returning error if not found in
@@ -448,8 +487,15 @@ type Ki interface {
 	// Delete deletes this node from its parent children list -- destroy will
 	// add removed child to deleted list, to be destroyed later -- otherwise
 	// child remains intact but parent is nil -- could be inserted elsewhere.
+	// Any receiver of the NodeSignalDeleteQuery sent first can veto the
+	// deletion -- use DeleteTry to find out if it was.
 	Delete(destroy bool)
 
+	// DeleteTry is Delete, returning an error if the deletion was vetoed by
+	// a receiver of the NodeSignalDeleteQuery, or the node was not found
+	// in the children of its parent.
+	DeleteTry(destroy bool) error
+
 	// Destroy calls DisconnectAll to cut all signal connections,
 	// and remove all children and their childrens-children, etc.
 	Destroy()
@@ -655,6 +701,29 @@ type Ki interface {
 	// traversal of that branch of the tree is aborted, but other branches continue.
 	FuncDownBreadthFirst(level int, data interface{}, fun Func)
 
+	// FuncDownParallel calls function on this node and all the nodes below it,
+	// including Ki fields, spreading the subtrees over a pool of the given
+	// number of worker goroutines (0 = runtime.GOMAXPROCS).  The function is
+	// always called on a node before any of its children, but otherwise in no
+	// particular order.  If fun returns SkipChildren, the children of that
+	// node are not processed.  The first other non-nil error returned by fun
+	// stops the traversal and is returned, as is the error of ctx if it is
+	// cancelled.  Returns once all function calls are done.  The function
+	// must be safe for concurrent use, and must not modify the tree structure.
+	FuncDownParallel(ctx context.Context, workers, level int, data interface{}, fun FuncErr) error
+
+	// FuncDownMeLastParallel calls function on all the nodes below this node,
+	// including Ki fields, and then on this node, spreading the subtrees over
+	// a pool of the given number of worker goroutines (0 =
+	// runtime.GOMAXPROCS).  The function is only called on a node after it
+	// has returned for all of its children (and fields), but otherwise in no
+	// particular order.  The first non-nil error returned by fun stops the
+	// traversal and is returned (SkipChildren is ignored), as is the error of
+	// ctx if it is cancelled.  Returns once all function calls are done.  The
+	// function must be safe for concurrent use, and must not modify the tree
+	// structure.
+	FuncDownMeLastParallel(ctx context.Context, workers, level int, data interface{}, fun FuncErr) error
+
 	//////////////////////////////////////////////////////////////////////////
 	//  State update signaling -- automatically consolidates all changes across
 	//   levels so there is only one update at end (optionally per node or only
@@ -665,6 +734,30 @@ type Ki interface {
 	// update, child signals.
 	NodeSignal() *Signal
 
+	// Links returns the records of the signal connections from this node
+	// made with Link, with the current paths of the receivers.
+	Links() []SignalLink
+
+	// Link connects given receiver to the Signal field of given name on this
+	// node (NodeSig for the NodeSignal), with the receiver function of given
+	// name in the RecvFuncs registry, at given priority (see
+	// Signal.ConnectPri), and records the connection in the Links of this
+	// node, so that it is saved and re-established after loading.  The
+	// receiver must be in the same tree.
+	Link(signal string, recv Ki, fun string, pri int) (*SignalConn, error)
+
+	// Unlink disconnects and removes all the Links from this node to given
+	// receiver, on the Signal field of given name, or on all signals if empty.
+	Unlink(signal string, recv Ki)
+
+	// ConnectLinks (re-)establishes the connections of all the Links in the
+	// tree from this node down, finding the receivers by their paths from the
+	// root of the tree -- this is called automatically in UnmarshalPost, and
+	// by the Read* methods and functions, which return its error.  Returns
+	// an error listing all the links that could not be connected, which are
+	// kept so they are saved again.
+	ConnectLinks() error
+
 	// UpdateStart should be called when starting to modify the tree (state or
 	// structure) -- returns whether this node was first to set the Updating
 	// flag (if so, all children have their Updating flag set -- pass the
@@ -700,6 +793,14 @@ type Ki interface {
 	// UpdateEnd's.  Returns true if an update signal was sent.
 	UpdateSig() bool
 
+	// Changes returns a copy of the list of changes recorded within the tree
+	// under this node during its last update batch (from UpdateStart to
+	// UpdateEnd), in the order they happened.  The list is reset at the next
+	// UpdateStart, and by UpdateSig -- receivers of the NodeSignalUpdated
+	// signal should use the snapshot sent in its UpdatedData instead (see
+	// WithChanges).
+	Changes() []Change
+
 	// UpdateReset resets Updating flag for this node and all children -- in
 	// case they are out-of-sync due to more complex tree maninpulations --
 	// only call at a known point of non-updating.
@@ -784,9 +885,10 @@ type Ki interface {
 	//  IO: for JSON and XML formats -- see also Slice, Ptr
 	//  see https://github.com/goki/ki/wiki/Naming for IO naming conventions
 
-	// WriteJSON writes the tree to an io.Writer, using MarshalJSON -- also
-	// saves a critical starting record that allows file to be loaded de-novo
-	// and recreate the proper root type for the tree.
+	// WriteJSON writes the tree to an io.Writer, streaming it node by node in
+	// the same format as MarshalJSON -- also saves a critical starting record
+	// that allows file to be loaded de-novo and recreate the proper root type
+	// for the tree.
 	WriteJSON(writer io.Writer, indent bool) error
 
 	// SaveJSON saves the tree to a JSON-encoded file, using WriteJSON.
@@ -796,28 +898,69 @@ type Ki interface {
 	// JSON-encoded byte stream via io.Reader.  First element in the stream
 	// must be of same type as this node -- see ReadNewJSON function to
 	// construct a new tree.  Uses ConfigureChildren to minimize changes from
-	// current tree relative to loading one -- wraps UnmarshalJSON and calls
-	// UnmarshalPost to recover pointers from paths.
+	// current tree relative to loading one -- streams the tree node by node
+	// in the UnmarshalJSON format, and calls UnmarshalPost to recover pointers
+	// from paths.  Nodes saved with an older version of their type are upgraded
+	// by the migrations registered with kit.Types.AddMigration, which are logged
+	// -- see ReadJSONMigrated to get the list of migrations instead.
 	ReadJSON(reader io.Reader) error
 
+	// ReadJSONMigrated reads the tree as in ReadJSON, returning the migrations
+	// applied to nodes saved with an older version of their type, which are
+	// not logged.
+	ReadJSONMigrated(reader io.Reader) ([]Migrated, error)
+
 	// OpenJSON opens file over this tree from a JSON-encoded file -- see
 	// ReadJSON for details, and OpenNewJSON for opening an entirely new tree.
 	OpenJSON(filename string) error
 
+	// WriteBinary writes the tree to an io.Writer in the compact binary
+	// format -- see ReadNewBinary to load a new tree, and ReadBinary to load
+	// onto an existing one.
+	WriteBinary(writer io.Writer) error
+
+	// ReadBinary reads the tree starting at this node from the binary format
+	// written by WriteBinary.  The root type in the stream must be the same
+	// as this node -- see ReadNewBinary to construct a new tree.  Uses
+	// ConfigureChildren to minimize changes from current tree relative to
+	// loading one, and calls UnmarshalPost to recover pointers from paths.
+	ReadBinary(reader io.Reader) error
+
+	// WriteYAML writes the tree to an io.Writer in the YAML format,
+	// including the type of the root node, so the file can be loaded de-novo
+	// using ReadNewYAML.
+	WriteYAML(writer io.Writer) error
+
+	// ReadYAML reads the tree starting at this node from the YAML format.
+	// Uses ConfigureChildren to minimize changes from current tree relative
+	// to loading one, and calls UnmarshalPost to recover pointers from paths.
+	ReadYAML(reader io.Reader) error
+
 	// WriteXML writes the tree to an XML-encoded byte string over io.Writer
-	// using MarshalXML.
+	// using MarshalXML -- saves all fields, Props (with their types) and
+	// children, so ReadXML restores the same tree as ReadJSON.
 	WriteXML(writer io.Writer, indent bool) error
 
 	// ReadXML reads the tree from an XML-encoded byte string over io.Reader, calls
-	// UnmarshalPost to recover pointers from paths.
+	// UnmarshalPost to recover pointers from paths.  Nodes saved with an older
+	// version of their type are migrated before the tree is loaded, as in
+	// ReadJSON.
 	ReadXML(reader io.Reader) error
 
+	// ReadXMLMigrated reads the tree as in ReadXML, returning the migrations
+	// applied to nodes saved with an older version of their type, which are
+	// not logged.  The tree is not changed if a migration fails.
+	ReadXMLMigrated(reader io.Reader) ([]Migrated, error)
+
 	// ParentAllChildren walks the tree down from current node and call
-	// SetParent on all children -- needed after an Unmarshal.
+	// SetParent on all children, including those of Ki fields -- needed after
+	// an Unmarshal.
 	ParentAllChildren()
 
 	// UnmarshalPost must be called after an Unmarshal -- calls
-	// SetPtrsFmPaths and ParentAllChildren.
+	// SetPtrsFmPaths and ParentAllChildren, and ConnectLinks, logging any
+	// links that could not be connected -- the Read* methods and functions
+	// return that error instead, with the rest of the tree loaded.
 	UnmarshalPost()
 }
 
@@ -842,6 +985,15 @@ const (
 	Break = false
 )
 
+// FuncErr is a function to call on ki objects in the parallel tree walking
+// methods (e.g., FuncDownParallel) -- a non-nil error stops the traversal,
+// except for SkipChildren, which just skips the children of this node.
+type FuncErr func(k Ki, level int, data interface{}) error
+
+// SkipChildren can be returned from a FuncErr function to skip the
+// children of the current node, without stopping the traversal.
+var SkipChildren = errors.New("ki: skip children")
+
 // KiType is a Ki reflect.Type, suitable for checking for Type.Implements.
 var KiType = reflect.TypeOf((*Ki)(nil)).Elem()
 
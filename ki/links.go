@@ -0,0 +1,277 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/goki/ki/kit"
+)
+
+// RecvFuncRegistry is a registry of named signal receiver functions, which
+// allows signal connections to be saved and loaded as SignalLink records
+// -- see Node.Link.  To register a function, add:
+//
+// var MyRecvFunc = ki.RecvFuncs.AddFunc("mypkg.MyRecvFunc", func(recv, send ki.Ki, sig int64, data interface{}) { ... })
+//
+// and use the name to make links.  The names should be package-qualified
+// to avoid conflicts.
+type RecvFuncRegistry struct {
+	// Funcs is a map from the name to the receiver function
+	Funcs map[string]RecvFunc
+
+	mu sync.RWMutex
+}
+
+// RecvFuncs is the master registry of named signal receiver functions
+var RecvFuncs RecvFuncRegistry
+
+// AddFunc adds given receiver function to the registry under given name,
+// replacing any existing function of that name, and returns the function,
+// so it can be used to initialize a global variable.
+func (rf *RecvFuncRegistry) AddFunc(name string, fun RecvFunc) RecvFunc {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.Funcs == nil {
+		rf.Funcs = make(map[string]RecvFunc)
+	}
+	rf.Funcs[name] = fun
+	return fun
+}
+
+// Func returns the receiver function of given name, or nil if not found.
+func (rf *RecvFuncRegistry) Func(name string) RecvFunc {
+	rf.mu.RLock()
+	defer rf.mu.RUnlock()
+	return rf.Funcs[name]
+}
+
+// SignalLink is a declarative record of a signal connection, kept in the
+// Links of the sending node, and saved and loaded with it -- after loading
+// (in UnmarshalPost), the connection is re-established by finding the
+// receiver by its path within the tree, and the function by its name in
+// the RecvFuncs registry -- see Node.Link.
+type SignalLink struct {
+	Signal string `desc:"name of the Signal field on the sending node -- NodeSig for the NodeSignal"`
+	Recv   string `desc:"unique path (PathUnique) of the receiving node, updated from the receiver when saved"`
+	Func   string `desc:"name of the receiving function in the RecvFuncs registry"`
+	Pri    int    `desc:"priority of the connection -- see Signal.ConnectPri"`
+
+	conn *SignalConn
+}
+
+// signalLink is the encoded form of SignalLink, without its methods
+type signalLink SignalLink
+
+// recvPath returns the current path of the receiver if connected, or the
+// saved Recv path otherwise
+func (sl *SignalLink) recvPath() string {
+	if sl.conn.IsConnected() && sl.conn.Recv.This() != nil {
+		return sl.conn.Recv.PathUnique()
+	}
+	return sl.Recv
+}
+
+// recvPathLocked is recvPath under the tree lock
+func (sl *SignalLink) recvPathLocked() string {
+	if sl.conn.IsConnected() && sl.conn.Recv.This() != nil {
+		return sl.conn.Recv.AsNode().pathUniqueLocked()
+	}
+	return sl.Recv
+}
+
+// String returns a description of the link
+func (sl SignalLink) String() string {
+	return fmt.Sprintf("%v -> %v: %v", sl.Signal, sl.recvPath(), sl.Func)
+}
+
+// MarshalJSON saves the link, with the current path of the receiver
+func (sl SignalLink) MarshalJSON() ([]byte, error) {
+	sl.Recv = sl.recvPath()
+	return json.Marshal(signalLink(sl))
+}
+
+// MarshalXML saves the link, with the current path of the receiver
+func (sl SignalLink) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	sl.Recv = sl.recvPath()
+	return e.EncodeElement(signalLink(sl), start)
+}
+
+// MarshalBinary saves the link, with the current path of the receiver, for
+// WriteBinary
+func (sl SignalLink) MarshalBinary() ([]byte, error) {
+	return sl.MarshalJSON()
+}
+
+// UnmarshalBinary loads the link, for ReadBinary
+func (sl *SignalLink) UnmarshalBinary(b []byte) error {
+	return json.Unmarshal(b, (*signalLink)(sl))
+}
+
+// signalByName returns the Signal field of given name on the node, or the
+// NodeSignal for NodeSig -- assumed to be under the tree lock.
+func signalByName(k Ki, name string) (*Signal, error) {
+	if name == "NodeSig" {
+		return k.NodeSignal(), nil
+	}
+	f, ok := kit.FlatFieldByName(k.Type(), name)
+	if !ok || f.Type != KiT_Signal || f.PkgPath != "" {
+		return nil, fmt.Errorf("ki %v: no exported Signal field named: %v", k.AsNode().pathUniqueLocked(), name)
+	}
+	return kit.FlatFieldValueByName(k, name).Addr().Interface().(*Signal), nil
+}
+
+// Links returns the records of the signal connections from this node made
+// with Link, with the current paths of the receivers.
+func (n *Node) Links() []SignalLink {
+	defer n.rlockTree().RUnlock()
+	return n.linksCopy()
+}
+
+// linksCopy returns a copy of the Links with the current paths of the
+// receivers, under the tree lock
+func (n *Node) linksCopy() []SignalLink {
+	links := make([]SignalLink, len(n.SigLinks))
+	for i, sl := range n.SigLinks {
+		sl.Recv = sl.recvPathLocked()
+		links[i] = sl
+	}
+	return links
+}
+
+// Link connects given receiver to the Signal field of given name on this
+// node (NodeSig for the NodeSignal), with the receiver function of given
+// name in the RecvFuncs registry, at given priority (see
+// Signal.ConnectPri), and records the connection in the Links of this
+// node, so that it is saved and re-established after loading.  The
+// receiver must be in the same tree.
+func (n *Node) Link(signal string, recv Ki, fun string, pri int) (*SignalConn, error) {
+	defer n.lockTree().Unlock()
+	sig, err := signalByName(n.This(), signal)
+	if err != nil {
+		return nil, err
+	}
+	rf := RecvFuncs.Func(fun)
+	if rf == nil {
+		return nil, fmt.Errorf("ki %v Link: receiver function not registered in RecvFuncs: %v", n.pathUniqueLocked(), fun)
+	}
+	n.recordLinks()
+	sl := SignalLink{Signal: signal, Recv: recv.AsNode().pathUniqueLocked(), Func: fun, Pri: pri}
+	sl.conn = sig.ConnectPri(recv, pri, rf)
+	n.SigLinks = append(n.SigLinks, sl)
+	return sl.conn, nil
+}
+
+// Unlink disconnects and removes all the Links from this node to given
+// receiver, on the Signal field of given name, or on all signals if empty.
+func (n *Node) Unlink(signal string, recv Ki) {
+	path := recv.PathUnique()
+	defer n.lockTree().Unlock()
+	del := func(sl *SignalLink) bool {
+		return (signal == "" || sl.Signal == signal) && ((sl.conn.IsConnected() && sl.conn.Recv == recv) || sl.recvPathLocked() == path)
+	}
+	if !slices.ContainsFunc(n.SigLinks, func(sl SignalLink) bool { return del(&sl) }) {
+		return
+	}
+	n.recordLinks()
+	var links []SignalLink
+	for _, sl := range n.SigLinks {
+		if del(&sl) {
+			sl.conn.Disconnect()
+			continue
+		}
+		links = append(links, sl)
+	}
+	n.SigLinks = links
+}
+
+// recordLinks records the current Links prior to them being changed, for
+// undo and in the changes of the current update batch -- assumed to be
+// under the tree lock.
+func (n *Node) recordLinks() {
+	if us := undoRecorder(n.This()); us != nil {
+		us.record(&undoLinks{k: n.This(), links: n.linksCopy()})
+	}
+	n.addChange(Change{Type: ChangeField, Name: "SigLinks"})
+}
+
+// ConnectLinks (re-)establishes the connections of all the Links in the
+// tree from this node down, finding the receivers by their paths from the
+// root of the tree -- this is called automatically in UnmarshalPost, and by
+// the Read* methods and functions, which return its error.  Returns an
+// error listing all the links that could not be connected, which are kept
+// so they are saved again.
+func (n *Node) ConnectLinks() error {
+	defer n.lockTree().Unlock()
+	root := rootLocked(n.This())
+	var errs []string
+	walkDownLocked(n.This(), func(k Ki) bool {
+		errs = append(errs, k.AsNode().connectLinks(root)...)
+		return Continue
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("ki.ConnectLinks: %v", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// connectLinks (re-)establishes the connections of the Links of this node,
+// finding the receivers by their paths from given root, and returns the
+// errors for those that could not be connected -- under the tree lock.
+func (n *Node) connectLinks(root Ki) []string {
+	var errs []string
+	for i := range n.SigLinks {
+		sl := &n.SigLinks[i]
+		sl.conn.Disconnect()
+		sl.conn = nil
+		sig, err := signalByName(n.This(), sl.Signal)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		rf := RecvFuncs.Func(sl.Func)
+		if rf == nil {
+			errs = append(errs, fmt.Sprintf("ki %v: receiver function not registered in RecvFuncs: %v", n.pathUniqueLocked(), sl.Func))
+			continue
+		}
+		recvs := root.AsNode().findPathsLocked(sl.Recv, false)
+		if len(recvs) == 0 {
+			errs = append(errs, fmt.Sprintf("ki %v: receiver of link to %v not found at path: %v", n.pathUniqueLocked(), sl.Func, sl.Recv))
+			continue
+		}
+		sl.conn = sig.ConnectPri(recvs[0], sl.Pri, rf)
+	}
+	return errs
+}
+
+// undoLinks records the Links of a node prior to Link or Unlink -- links
+// holds the links to swap in, which are reconnected.
+type undoLinks struct {
+	k     Ki
+	links []SignalLink
+}
+
+func (op *undoLinks) node() Ki { return op.k }
+
+func (op *undoLinks) undo() {
+	nb := op.k.AsNode()
+	cur := nb.linksCopy()
+	for _, sl := range cur {
+		sl.conn.Disconnect()
+	}
+	nb.SigLinks = op.links
+	nb.connectLinks(rootLocked(op.k))
+	op.links = cur
+	nb.addChange(Change{Type: ChangeField, Name: "SigLinks"})
+}
+
+func (op *undoLinks) redo() { op.undo() }
+
+func (op *undoLinks) release() {}
@@ -0,0 +1,201 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goki/ki/kit"
+)
+
+type NodeSigs struct {
+	NodeEmbed
+	Changed Signal `json:"-" xml:"-"`
+}
+
+var KiT_NodeSigs = kit.Types.AddType(&NodeSigs{}, nil)
+
+var linkRes []string
+
+var linkTestRecv = RecvFuncs.AddFunc("ki.linkTestRecv", func(recv, send Ki, sig int64, data interface{}) {
+	linkRes = append(linkRes, fmt.Sprintf("%v <- %v %v", recv.Name(), send.Name(), data))
+})
+
+func linkTestTree(t *testing.T) Ki {
+	root := NodeSigs{}
+	root.InitName(&root, "root")
+	a := root.AddNewChild(KiT_NodeSigs, "a").(*NodeSigs)
+	b := root.AddNewChild(KiT_NodeSigs, "b")
+	sub := b.AddNewChild(KiT_NodeSigs, "sub")
+	if _, err := a.Link("Changed", sub, "ki.linkTestRecv", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Link("NodeSig", &root, "ki.linkTestRecv", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Link("Missing", b, "ki.linkTestRecv", 0); err == nil {
+		t.Errorf("Link to missing signal should fail")
+	}
+	if _, err := a.Link("Changed", b, "ki.noSuchRecv", 0); err == nil {
+		t.Errorf("Link to missing function should fail")
+	}
+	b.SetName("b2") // path saved is from the current receiver
+	return root.This()
+}
+
+func TestLinksSaveLoad(t *testing.T) {
+	root := linkTestTree(t)
+	check := func(fmt string, nr Ki) {
+		t.Helper()
+		linkRes = nil
+		a := nr.ChildByName("a", 0).(*NodeSigs)
+		a.Changed.Emit(a, 0, "changed")
+		a.NodeSignal().Emit(a, 0, "node")
+		trg := []string{"sub <- a changed", "root <- a node"}
+		if !reflect.DeepEqual(linkRes, trg) {
+			t.Errorf("%v: links not restored: %v", fmt, linkRes)
+		}
+		if len(a.Links()) != 2 || a.Links()[0].Recv != "/root/b2/sub" {
+			t.Errorf("%v: links not loaded: %v", fmt, a.Links())
+		}
+	}
+	check("orig", root)
+
+	var buf bytes.Buffer
+	if err := root.WriteJSON(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	nr, err := ReadNewJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("JSON", nr)
+
+	buf.Reset()
+	if err := root.WriteXML(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	xr := NodeSigs{}
+	xr.InitName(&xr, "")
+	if err := xr.ReadXML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	check("XML", xr.This())
+
+	buf.Reset()
+	if err := root.WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	br, err := ReadNewBinary(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("binary", br)
+
+	buf.Reset()
+	if err := root.WriteYAML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	yr := NodeSigs{}
+	yr.InitName(&yr, "")
+	if err := yr.ReadYAML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	check("YAML", yr.This())
+}
+
+func TestLinksUnresolved(t *testing.T) {
+	root := linkTestTree(t)
+	root.ChildByName("b2", 0).Delete(true) // saved with path from before rename
+	var buf bytes.Buffer
+	if err := root.WriteJSON(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	nr, err := ReadNewJSON(bytes.NewReader(b)) // the tree is loaded anyway
+	if err == nil || !strings.Contains(err.Error(), "/root/b/sub") || nr == nil {
+		t.Fatalf("ReadNewJSON should return the unresolved link error: %v", err)
+	}
+	if err := nr.ReadJSON(bytes.NewReader(b)); err == nil {
+		t.Errorf("ReadJSON should return the unresolved link error")
+	}
+	var xb bytes.Buffer
+	if err := root.WriteXML(&xb, false); err != nil {
+		t.Fatal(err)
+	}
+	xr := NodeSigs{}
+	xr.InitName(&xr, "")
+	if err := xr.ReadXML(&xb); err == nil {
+		t.Errorf("ReadXML should return the unresolved link error")
+	}
+	a := nr.ChildByName("a", 0).(*NodeSigs)
+	if err := a.ConnectLinks(); err == nil {
+		t.Errorf("ConnectLinks should fail for unresolved path")
+	}
+	if len(a.Links()) != 2 || a.Links()[0].Recv != "/root/b/sub" {
+		t.Errorf("unresolved link not kept: %v", a.Links())
+	}
+	linkRes = nil
+	a.Changed.Emit(a, 0, "changed")
+	a.NodeSignal().Emit(a, 0, "node")
+	if !reflect.DeepEqual(linkRes, []string{"root <- a node"}) {
+		t.Errorf("resolved link not connected: %v", linkRes)
+	}
+
+	sub := nr.AddNewChild(KiT_NodeSigs, "b").AddNewChild(KiT_NodeSigs, "sub")
+	if err := a.ConnectLinks(); err != nil {
+		t.Errorf("ConnectLinks: %v", err)
+	}
+	linkRes = nil
+	a.Changed.Emit(a, 0, "changed")
+	if !reflect.DeepEqual(linkRes, []string{"sub <- a changed"}) {
+		t.Errorf("link not connected when resolved: %v", linkRes)
+	}
+
+	a.Unlink("", nr)
+	a.Unlink("Changed", sub)
+	linkRes = nil
+	a.Changed.Emit(a, 0, "changed")
+	a.NodeSignal().Emit(a, 0, "node")
+	if len(a.Links()) != 0 || len(linkRes) != 0 {
+		t.Errorf("Unlink: %v %v", a.Links(), linkRes)
+	}
+}
+
+func TestLinksUndo(t *testing.T) {
+	root := linkTestTree(t)
+	us := NewUndoStack(root, 0)
+	defer us.Close()
+	a := root.ChildByName("a", 0).(*NodeSigs)
+	sub := root.ChildByName("b2", 0).Child(0)
+	a.Unlink("Changed", sub)
+	if _, err := a.Link("Changed", root, "ki.linkTestRecv", 0); err != nil {
+		t.Fatal(err)
+	}
+	emit := func() []string {
+		linkRes = nil
+		a.Changed.Emit(a, 0, "changed")
+		return linkRes
+	}
+	if res := emit(); !reflect.DeepEqual(res, []string{"root <- a changed"}) {
+		t.Errorf("Unlink, Link: %v", res)
+	}
+	us.Undo()
+	if res := emit(); len(res) != 0 || len(a.Links()) != 1 {
+		t.Errorf("undo Link: %v %v", res, a.Links())
+	}
+	us.Undo()
+	if res := emit(); !reflect.DeepEqual(res, []string{"sub <- a changed"}) || len(a.Links()) != 2 {
+		t.Errorf("undo Unlink: %v %v", res, a.Links())
+	}
+	us.Redo()
+	if res := emit(); len(res) != 0 || len(a.Links()) != 1 {
+		t.Errorf("redo Unlink: %v %v", res, a.Links())
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Mailbox is a queue of calls to signal receiver functions, which are run
+// serially, in the order they were posted, by the one goroutine running
+// its loop (see Run, Start) -- this is the event loop of a GUI-style app.
+// A receiver node, or a whole tree (by binding its root), is bound to a
+// Mailbox with BindMailbox, and then signals sent to it by Emit, EmitGo,
+// EmitFiltered, EmitGoFiltered and SendSig from any goroutine are queued
+// on its Mailbox instead of being called directly, so the receiver
+// functions of all the nodes bound to the same Mailbox never run
+// concurrently with each other.  Emitting a signal from the loop itself
+// also queues the calls, which then run after the current one returns.
+// Note that EmitCollect does not call bound receivers, and ConsFunc always
+// calls the receivers directly, so it must only be used on the loop
+// goroutine for bound receivers.
+type Mailbox struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []func()
+	busy    bool // a call is in progress
+	looping bool // Run is active
+	closed  bool
+}
+
+// NewMailbox returns a new Mailbox -- call Start, or Run on the goroutine
+// that is to be its loop, to start running the calls posted to it.
+func NewMailbox() *Mailbox {
+	mb := &Mailbox{}
+	mb.cond = sync.NewCond(&mb.mu)
+	return mb
+}
+
+// Post queues given function to be called by the loop -- it is ignored if
+// the Mailbox has been closed.  Safe to call from any goroutine.
+func (mb *Mailbox) Post(fun func()) {
+	mb.mu.Lock()
+	if !mb.closed {
+		mb.queue = append(mb.queue, fun)
+		mb.cond.Broadcast()
+	}
+	mb.mu.Unlock()
+}
+
+// Len returns the number of calls waiting in the queue.
+func (mb *Mailbox) Len() int {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return len(mb.queue)
+}
+
+// Start starts a new goroutine running the loop of the Mailbox.
+func (mb *Mailbox) Start() {
+	go mb.Run()
+}
+
+// Run runs the loop of the Mailbox on the calling goroutine, calling the
+// posted functions in order, until Close is called -- any calls still
+// waiting at that point are discarded.
+func (mb *Mailbox) Run() {
+	mb.mu.Lock()
+	mb.looping = true
+	for {
+		for len(mb.queue) == 0 && !mb.closed {
+			mb.cond.Wait()
+		}
+		if mb.closed {
+			break
+		}
+		mb.runNext()
+	}
+	mb.looping = false
+	mb.queue = nil
+	mb.cond.Broadcast()
+	mb.mu.Unlock()
+}
+
+// runNext calls the next function in the queue, assumed to be under the
+// lock, which is released during the call.
+func (mb *Mailbox) runNext() {
+	fun := mb.queue[0]
+	mb.queue[0] = nil
+	mb.queue = mb.queue[1:]
+	mb.busy = true
+	mb.mu.Unlock()
+	defer func() {
+		mb.mu.Lock()
+		mb.busy = false
+		mb.cond.Broadcast()
+	}()
+	fun()
+}
+
+// Close stops the loop, and any further calls that are posted are
+// ignored.
+func (mb *Mailbox) Close() {
+	mb.mu.Lock()
+	mb.closed = true
+	mb.cond.Broadcast()
+	mb.mu.Unlock()
+}
+
+// Flush waits until all the calls posted to the Mailbox, including any
+// posted by those calls in turn, have been run by the loop -- if no loop
+// is running, it runs them on the calling goroutine, as Drain does.  This
+// is mainly for tests, and must not be called from the loop itself.
+func (mb *Mailbox) Flush() {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	for (len(mb.queue) > 0 || mb.busy) && !mb.closed {
+		if !mb.looping && !mb.busy {
+			mb.runNext()
+			continue
+		}
+		mb.cond.Wait()
+	}
+}
+
+// Drain runs all the calls waiting in the queue, including any posted by
+// those calls in turn, on the calling goroutine, returning the number run
+// -- this is for using a Mailbox without a loop goroutine, e.g., in tests,
+// or driven by an external loop.  It must not be used while Run is
+// active.
+func (mb *Mailbox) Drain() int {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	n := 0
+	for len(mb.queue) > 0 && !mb.closed {
+		mb.runNext()
+		n++
+	}
+	return n
+}
+
+// mailboxes is the registry of Mailbox's keyed by the node bound to them
+var mailboxes = struct {
+	sync.RWMutex
+	m map[Ki]*Mailbox
+}{m: make(map[Ki]*Mailbox)}
+
+// mailboxActive is the number of bound nodes -- avoids any lookup cost
+// when mailboxes are not in use.
+var mailboxActive int32
+
+// BindMailbox binds given node, and all the nodes in the tree below it
+// that are not bound to another Mailbox, to given Mailbox, so that signals
+// sent to them are queued on it -- a nil Mailbox unbinds the node.  The
+// binding is removed when the node is destroyed.
+func BindMailbox(k Ki, mb *Mailbox) {
+	k = k.This()
+	mailboxes.Lock()
+	if mb == nil {
+		delete(mailboxes.m, k)
+	} else {
+		mailboxes.m[k] = mb
+	}
+	atomic.StoreInt32(&mailboxActive, int32(len(mailboxes.m)))
+	mailboxes.Unlock()
+}
+
+// MailboxFor returns the Mailbox that given node is bound to, either
+// directly or via the closest of its parents that is bound, or nil if
+// none.
+func MailboxFor(k Ki) *Mailbox {
+	if atomic.LoadInt32(&mailboxActive) == 0 || k == nil || k.This() == nil {
+		return nil
+	}
+	// collect the parents first: Parent takes the tree lock, which must not
+	// be taken under the mailboxes lock
+	ks := append([]Ki{k.This()}, slices.Collect(Ancestors(k))...)
+	mailboxes.RLock()
+	defer mailboxes.RUnlock()
+	for _, a := range ks {
+		if mb, ok := mailboxes.m[a]; ok {
+			return mb
+		}
+	}
+	return nil
+}
+
+// mailboxDestroyed removes the binding of given node when it is destroyed
+// -- called by Destroy.
+func mailboxDestroyed(k Ki) {
+	mailboxes.Lock()
+	delete(mailboxes.m, k)
+	atomic.StoreInt32(&mailboxActive, int32(len(mailboxes.m)))
+	mailboxes.Unlock()
+}
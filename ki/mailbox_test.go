@@ -0,0 +1,99 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestMailboxDrain(t *testing.T) {
+	parent := TestNode{}
+	parent.InitName(&parent, "par1")
+	child1 := parent.AddNewChild(nil, "child1")
+	child2 := parent.AddNewChild(nil, "child2")
+	sub := child1.AddNewChild(nil, "sub")
+
+	var res []string
+	recv := func(receiver, sender Ki, sig int64, data interface{}) {
+		res = append(res, fmt.Sprintf("%v %v", receiver.Name(), data))
+	}
+	parent.sig1.Connect(sub, recv)
+	c2 := parent.sig1.Connect(child2, recv)
+	parent.sig2.Connect(child2, func(receiver, sender Ki, sig int64, data interface{}) {
+		res = append(res, "sig2")
+		parent.sig1.Emit(sender, 0, "again") // queued after this one
+	})
+
+	mb := NewMailbox()
+	BindMailbox(child1, mb) // binds sub, but not child2
+	parent.sig1.Emit(&parent, 0, 1)
+	if !reflect.DeepEqual(res, []string{"child2 1"}) || mb.Len() != 1 {
+		t.Errorf("Emit to bound receiver not queued: %v %v", res, mb.Len())
+	}
+	if n := mb.Drain(); n != 1 || !reflect.DeepEqual(res, []string{"child2 1", "sub 1"}) {
+		t.Errorf("Drain: %v %v", n, res)
+	}
+
+	res = res[:0]
+	BindMailbox(&parent, mb)
+	parent.sig2.Emit(&parent, 0, nil)
+	parent.sig1.Emit(&parent, 0, 2)
+	c2.Disconnect() // skipped when run
+	if len(res) != 0 || mb.Len() != 3 {
+		t.Errorf("Emit to bound tree not queued: %v %v", res, mb.Len())
+	}
+	mb.Flush() // no loop: runs on this goroutine
+	if !reflect.DeepEqual(res, []string{"sig2", "sub 2", "sub again"}) || mb.Len() != 0 {
+		t.Errorf("Flush: %v", res)
+	}
+
+	child1.Destroy()
+	BindMailbox(&parent, nil)
+	if MailboxFor(child2) != nil || mailboxActive != 0 {
+		t.Errorf("bindings not removed: %v", mailboxActive)
+	}
+}
+
+func TestMailboxLoop(t *testing.T) {
+	parent := TestNode{}
+	parent.InitName(&parent, "par1")
+	var kids []Ki
+	for i := 0; i < 4; i++ {
+		kids = append(kids, parent.AddNewChild(nil, fmt.Sprintf("child%d", i)))
+	}
+	cnt := 0 // not synchronized: the race detector checks it is only used by the loop
+	for _, k := range kids {
+		parent.sig1.Connect(k, func(receiver, sender Ki, sig int64, data interface{}) {
+			cnt++
+		})
+	}
+	defer func(trace bool) { SignalTrace = trace }(SignalTrace)
+	SignalTrace = false // tracing is not thread-safe
+	mb := NewMailbox()
+	BindMailbox(&parent, mb)
+	defer BindMailbox(&parent, nil)
+	mb.Start()
+	defer mb.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				parent.sig1.Emit(&parent, 0, nil)
+				parent.sig1.EmitGo(&parent, 0, nil)
+			}
+		}()
+	}
+	wg.Wait()
+	mb.Flush()
+	if cnt != 8*25*2*len(kids) || mb.Len() != 0 {
+		t.Errorf("loop ran %v calls", cnt)
+	}
+}
@@ -0,0 +1,719 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goki/ki/kit"
+)
+
+// Saved trees record the current versions of all types that have migrations
+// registered with kit.Types.AddMigration, in the ki.Versions key of the
+// JSON header (next to ki.RootType), and in the ki processing instruction at
+// the start of XML files.  Types not listed are at version 0.  When loading,
+// each node saved with an older version of its type is first read into its
+// raw representation, which is then upgraded by the migrations before the
+// fields are set.  The raw representation is a map of field names to the
+// values as decoded by encoding/json, where the Kids are a []interface{}
+// list of the raw representations of the children, each with its type name
+// in the RawTypeKey key, so migrations can also restructure the children.
+// For XML, the fields are converted to the same values via the field types
+// of the current version of the node type, so the same migrations apply.
+// The migrations applied are logged by ReadJSON, ReadNewJSON and ReadXML,
+// and returned by ReadJSONMigrated, ReadNewJSONMigrated and
+// ReadXMLMigrated.
+
+// RawTypeKey is the key for the type name of each child in the raw
+// representation of the Kids used in migrations
+var RawTypeKey = "ki.Type"
+
+// Migrated records the migration of a node when loading a tree
+type Migrated struct {
+	Path string `desc:"path of the node relative to the root, using unique names"`
+	Type string `desc:"type name of the node"`
+	From int    `desc:"saved version of the type"`
+	To   int    `desc:"version after migration -- the current version"`
+}
+
+// String satisfies the fmt.Stringer interface
+func (mg Migrated) String() string {
+	path := mg.Path
+	if path == "" {
+		path = "root"
+	}
+	return fmt.Sprintf("%v (%v) from version %d to %d", path, mg.Type, mg.From, mg.To)
+}
+
+// logMigrated reports the migrations applied in loading a tree
+func logMigrated(fun string, migs []Migrated) {
+	for _, mg := range migs {
+		log.Printf("%v: migrated %v\n", fun, mg)
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  JSON
+
+// decodeMigrate reads the next JSON object in its raw representation,
+// applies the migrations of the node type from given version, and then
+// loads the node from the migrated representation.
+func (jd *jsonDecoder) decodeMigrate(k Ki, path string, from int) error {
+	off := jd.dec.InputOffset()
+	rv, err := jd.rawValue()
+	if err != nil || rv == nil {
+		return err
+	}
+	raw, ok := rv.(map[string]interface{})
+	if !ok {
+		return jd.errorAt(off, "expected { for node %v, got: %v", k.Name(), rv)
+	}
+	tn := kit.Types.TypeName(k.Type())
+	to, err := kit.Types.Migrate(k.Type(), from, raw)
+	if err != nil {
+		return jd.errorAt(off, "node %v: %v", path, err)
+	}
+	jd.migrated = append(jd.migrated, Migrated{Path: path, Type: tn, From: from, To: to})
+	var b bytes.Buffer
+	if err := writeRaw(&b, raw); err != nil {
+		return jd.errorAt(off, "node %v: migrated: %v", path, err)
+	}
+	sub := &jsonDecoder{dec: json.NewDecoder(&b), migrate: true, versions: jd.versions, noMigrate: k}
+	err = sub.decodeNode(k, path)
+	jd.migrated = append(jd.migrated, sub.migrated...)
+	if err != nil {
+		return jd.errorAt(off, "node %v: migrated: %v", path, err)
+	}
+	return nil
+}
+
+// rawValue reads the next JSON value in the raw representation used for
+// migrations
+func (jd *jsonDecoder) rawValue() (interface{}, error) {
+	t, off, err := jd.token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		m := make(map[string]interface{})
+		for jd.dec.More() {
+			kt, _, err := jd.token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := kt.(string)
+			var v interface{}
+			if key == "Kids" {
+				v, err = jd.rawKids()
+			} else {
+				v, err = jd.rawValue()
+			}
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, jd.delim('}')
+	case json.Delim('['):
+		l := make([]interface{}, 0)
+		for jd.dec.More() {
+			v, err := jd.rawValue()
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		return l, jd.delim(']')
+	}
+	if _, ok := t.(json.Delim); ok {
+		return nil, jd.errorAt(off, "unexpected %v", t)
+	}
+	return t, nil
+}
+
+// rawKids reads the next Slice value as a list of the raw kids, with their
+// type names in the RawTypeKey key
+func (jd *jsonDecoder) rawKids() (interface{}, error) {
+	t, off, err := jd.token()
+	if err != nil {
+		return nil, err
+	}
+	if t == nil { // null
+		return nil, nil
+	}
+	if t != json.Delim('[') {
+		return nil, jd.errorAt(off, "expected [ for Slice, got: %v", t)
+	}
+	kids := make([]interface{}, 0)
+	if !jd.dec.More() {
+		return kids, jd.delim(']')
+	}
+	tnl, err := jd.decodeSliceHeader()
+	if err != nil {
+		return nil, err
+	}
+	for jd.dec.More() {
+		off := jd.dec.InputOffset()
+		if len(kids) >= len(tnl) {
+			return nil, jd.errorAt(off, "Slice has more elements than n = %d in header", len(tnl))
+		}
+		v, err := jd.rawValue()
+		if err != nil {
+			return nil, err
+		}
+		kid, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, jd.errorAt(off, "expected { for Slice element, got: %v", v)
+		}
+		kid[RawTypeKey] = kit.Types.TypeName(tnl[len(kids)].Type)
+		kids = append(kids, kid)
+	}
+	if len(kids) < len(tnl) {
+		return nil, jd.errorAt(jd.dec.InputOffset(), "Slice has %d elements but n = %d in header", len(kids), len(tnl))
+	}
+	return kids, jd.delim(']')
+}
+
+// writeRaw writes the JSON encoding of a raw value, including the Kids in
+// the Slice format
+func writeRaw(b *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			kb, _ := json.Marshal(key)
+			b.Write(kb)
+			b.WriteByte(':')
+			var err error
+			if key == "Kids" {
+				err = writeRawKids(b, v[key])
+			} else {
+				err = writeRaw(b, v[key])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	case []interface{}:
+		b.WriteByte('[')
+		for i, ev := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := writeRaw(b, ev); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	default:
+		vb, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.Write(vb)
+	}
+	return nil
+}
+
+// writeRawKids writes a raw list of kids in the Slice format
+func writeRawKids(b *bytes.Buffer, v interface{}) error {
+	kids, ok := v.([]interface{})
+	if v != nil && !ok {
+		return fmt.Errorf("Kids must be a list, not: %T", v)
+	}
+	if len(kids) == 0 {
+		b.WriteString("null")
+		return nil
+	}
+	b.WriteString(fmt.Sprintf("[{\"n\":%d", len(kids)))
+	for i, kv := range kids {
+		kid, ok := kv.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Kids element %d must be a map, not: %T", i, kv)
+		}
+		tn, _ := kid[RawTypeKey].(string)
+		if kit.Types.Type(tn) == nil {
+			return fmt.Errorf("Kids element %d: kit.Types type name not found: %v", i, tn)
+		}
+		nm, ok := kid["UniqueNm"].(string)
+		if !ok || nm == "" {
+			nm, _ = kid["Nm"].(string)
+		}
+		tb, _ := json.Marshal(tn)
+		nb, _ := json.Marshal(nm)
+		b.WriteString(",\"type\":")
+		b.Write(tb)
+		b.WriteString(",\"name\":")
+		b.Write(nb)
+	}
+	b.WriteByte('}')
+	for _, kv := range kids {
+		kid := kv.(map[string]interface{})
+		tn := kid[RawTypeKey]
+		delete(kid, RawTypeKey)
+		b.WriteByte(',')
+		err := writeRaw(b, kid)
+		kid[RawTypeKey] = tn
+		if err != nil {
+			return err
+		}
+	}
+	b.WriteByte(']')
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  XML
+
+// XMLProcInstTarget is the target of the processing instruction at the start
+// of ki tree XML files, which records the root type and type versions, e.g.,
+// <?ki RootType="ki.Node" Versions="pkg.Type:2"?>
+var XMLProcInstTarget = "ki"
+
+// xmlHeader returns the processing instruction recording given root type and
+// the current versions of any types with migrations
+func xmlHeader(typ reflect.Type) []byte {
+	inst := fmt.Sprintf("RootType=%q", kit.Types.TypeName(typ))
+	vers := kit.Types.Versions()
+	if len(vers) > 0 {
+		vl := make([]string, 0, len(vers))
+		for tn, v := range vers {
+			vl = append(vl, fmt.Sprintf("%v:%d", tn, v))
+		}
+		sort.Strings(vl)
+		inst += fmt.Sprintf(" Versions=%q", strings.Join(vl, " "))
+	}
+	return []byte(fmt.Sprintf("<?%v %v?>", XMLProcInstTarget, inst))
+}
+
+// readXMLHeader reads the ki processing instruction from the start of XML,
+// returning the root type (or "" if there is no header) and the versions.
+func readXMLHeader(b []byte) (string, map[string]int) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return "", nil
+		}
+		switch tv := t.(type) {
+		case xml.ProcInst:
+			if tv.Target != XMLProcInstTarget {
+				continue
+			}
+			// parse the attribute-like pairs as an element
+			var hdr struct {
+				RootType string `xml:"RootType,attr"`
+				Versions string `xml:"Versions,attr"`
+			}
+			if err := xml.Unmarshal([]byte("<h "+string(tv.Inst)+"/>"), &hdr); err != nil {
+				return "", nil
+			}
+			vers := make(map[string]int)
+			for _, tv := range strings.Fields(hdr.Versions) {
+				ci := strings.LastIndex(tv, ":")
+				if ci < 0 {
+					continue
+				}
+				v, _ := strconv.Atoi(tv[ci+1:])
+				vers[tv[:ci]] = v
+			}
+			return hdr.RootType, vers
+		case xml.StartElement:
+			return "", nil
+		}
+	}
+}
+
+// xmlEl is the generic representation of an XML element, used to migrate
+// the nodes in an XML tree before it is loaded
+type xmlEl struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Text    string     `xml:",chardata"`
+	Els     []xmlEl    `xml:",any"`
+}
+
+// encode writes the element, dropping the spacing between sub-elements
+func (el *xmlEl) encode(e *xml.Encoder) error {
+	start := xml.StartElement{Name: el.XMLName, Attr: el.Attrs}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if len(el.Els) == 0 {
+		if err := e.EncodeToken(xml.CharData(el.Text)); err != nil {
+			return err
+		}
+	}
+	for i := range el.Els {
+		if err := el.Els[i].encode(e); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// xmlBytes returns the XML encoding of given elements
+func xmlBytes(els ...xmlEl) ([]byte, error) {
+	var b bytes.Buffer
+	e := xml.NewEncoder(&b)
+	for i := range els {
+		if err := els[i].encode(e); err != nil {
+			return nil, err
+		}
+	}
+	err := e.Flush()
+	return b.Bytes(), err
+}
+
+// xmlEls decodes all the elements in given XML
+func xmlEls(b []byte) ([]xmlEl, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	var els []xmlEl
+	for {
+		var el xmlEl
+		err := d.Decode(&el)
+		if err == io.EOF {
+			return els, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		els = append(els, el)
+	}
+}
+
+// xmlNeedsMigrate returns true if any type with migrations is at a newer
+// version than that saved in given versions
+func xmlNeedsMigrate(vers map[string]int) bool {
+	for tn, v := range kit.Types.Versions() {
+		if v > vers[tn] {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateXML applies the migrations to all the nodes in the XML tree
+// (excluding the header) saved with older versions of their types, given
+// the type of the root and the saved versions, returning the migrated XML,
+// which can then be loaded, and the migrations applied.  As for JSON, each
+// node is migrated in its raw representation, with the field values
+// converted via their types in the current version of the node type --
+// fields that are not in the current version have string values, or maps of
+// the values of their sub-elements.
+func migrateXML(b []byte, typ reflect.Type, vers map[string]int) ([]byte, []Migrated, error) {
+	if !xmlNeedsMigrate(vers) {
+		return b, nil, nil
+	}
+	els, err := xmlEls(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(els) != 1 {
+		return nil, nil, fmt.Errorf("expected one root element, got: %d", len(els))
+	}
+	var migs []Migrated
+	if err := xmlMigrateNode(&els[0], typ, "", vers, &migs); err != nil {
+		return nil, nil, err
+	}
+	b, err = xmlBytes(els[0])
+	return b, migs, err
+}
+
+// xmlMigrateNode migrates the element of a node of given type if saved at
+// an older version, and then its children
+func xmlMigrateNode(el *xmlEl, typ reflect.Type, path string, vers map[string]int, migs *[]Migrated) error {
+	tn := kit.Types.TypeName(typ)
+	if from := vers[tn]; kit.Types.Version(typ) > from {
+		raw, err := xmlToRaw(el, typ)
+		if err != nil {
+			return fmt.Errorf("node %v: %v", path, err)
+		}
+		to, err := kit.Types.Migrate(typ, from, raw)
+		if err != nil {
+			return fmt.Errorf("node %v: %v", path, err)
+		}
+		*migs = append(*migs, Migrated{Path: path, Type: tn, From: from, To: to})
+		if *el, err = rawToXML(raw, typ, el.XMLName); err != nil {
+			return fmt.Errorf("node %v: migrated: %v", path, err)
+		}
+	}
+	for i := range el.Els {
+		if el.Els[i].XMLName.Local != "Kids" {
+			continue
+		}
+		hdr, kids, err := xmlKids(&el.Els[i])
+		if err != nil {
+			return fmt.Errorf("node %v: %v", path, err)
+		}
+		for ki, kel := range kids {
+			if err := xmlMigrateNode(kel, hdr[ki].Type, jsonKidPath(path, hdr[ki].Name), vers, migs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// xmlKids returns the types and names from the header of the Kids element,
+// and the elements of the kids
+func xmlKids(el *xmlEl) (kit.TypeAndNameList, []*xmlEl, error) {
+	var tnl kit.TypeAndNameList
+	var kids []*xmlEl
+	for i := range el.Els {
+		sub := &el.Els[i]
+		switch {
+		case sub.XMLName.Local == "N":
+		case sub.XMLName.Local == "Type" && len(kids) == 0:
+			tn := strings.TrimSpace(sub.Text)
+			typ := kit.Types.Type(tn)
+			if typ == nil {
+				return nil, nil, fmt.Errorf("Kids: kit.Types type name not found: %v", tn)
+			}
+			var nm string
+			for _, attr := range sub.Attrs {
+				if attr.Name.Local == "name" {
+					nm = attr.Value
+				}
+			}
+			tnl.Add(typ, nm)
+		default:
+			kids = append(kids, sub)
+		}
+	}
+	if len(kids) != len(tnl) {
+		return nil, nil, fmt.Errorf("Kids has %d elements but %d types", len(kids), len(tnl))
+	}
+	return tnl, kids, nil
+}
+
+// xmlToRaw returns the raw representation of the element of a node of given
+// type
+func xmlToRaw(el *xmlEl, typ reflect.Type) (map[string]interface{}, error) {
+	raw := make(map[string]interface{})
+	for i := 0; i < len(el.Els); i++ {
+		nm := el.Els[i].XMLName.Local
+		if nm == "Kids" {
+			kids, err := xmlKidsToRaw(&el.Els[i])
+			if err != nil {
+				return nil, err
+			}
+			raw[nm] = kids
+			continue
+		}
+		// consecutive elements of the same name are the elements of a slice
+		j := i + 1
+		for j < len(el.Els) && el.Els[j].XMLName.Local == nm {
+			j++
+		}
+		v, err := xmlFieldToRaw(el.Els[i:j], typ)
+		if err != nil {
+			return nil, fmt.Errorf("field %v: %v", nm, err)
+		}
+		raw[nm] = v
+		i = j - 1
+	}
+	return raw, nil
+}
+
+// xmlKidsToRaw returns the raw representation of the Kids element
+func xmlKidsToRaw(el *xmlEl) ([]interface{}, error) {
+	tnl, kels, err := xmlKids(el)
+	if err != nil {
+		return nil, err
+	}
+	kids := make([]interface{}, len(kels))
+	for i, kel := range kels {
+		kid, err := xmlToRaw(kel, tnl[i].Type)
+		if err != nil {
+			return nil, err
+		}
+		kid[RawTypeKey] = kit.Types.TypeName(tnl[i].Type)
+		kids[i] = kid
+	}
+	return kids, nil
+}
+
+// xmlFieldToRaw returns the raw representation of the elements of the
+// field of given node type, which are decoded via the type of the field
+// if it has one, so the values are the same as for JSON.
+func xmlFieldToRaw(els []xmlEl, typ reflect.Type) (interface{}, error) {
+	f, ok := kit.FlatFieldByName(typ, els[0].XMLName.Local)
+	if !ok {
+		return xmlUnknownToRaw(&els[0]), nil
+	}
+	if f.Type.Kind() == reflect.Struct && IsKi(f.Type) {
+		return xmlToRaw(&els[0], f.Type)
+	}
+	b, err := xmlBytes(els...)
+	if err != nil {
+		return nil, err
+	}
+	pv := reflect.New(f.Type)
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for range els {
+		if err := d.Decode(pv.Interface()); err != nil {
+			return nil, err
+		}
+	}
+	jb, err := json.Marshal(pv.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = json.Unmarshal(jb, &v)
+	return v, err
+}
+
+// xmlUnknownToRaw returns the raw representation of an element that is not
+// a field of the current type: its text, or a map of its sub-elements
+func xmlUnknownToRaw(el *xmlEl) interface{} {
+	if len(el.Els) == 0 {
+		return el.Text
+	}
+	m := make(map[string]interface{}, len(el.Els))
+	for i := range el.Els {
+		m[el.Els[i].XMLName.Local] = xmlUnknownToRaw(&el.Els[i])
+	}
+	return m
+}
+
+// rawToXML returns the element of a node of given type, with given name,
+// from its raw representation -- values that are not fields of the type
+// are kept as in xmlUnknownToRaw, for the migrations of the children.
+func rawToXML(raw map[string]interface{}, typ reflect.Type, name xml.Name) (xmlEl, error) {
+	el := xmlEl{XMLName: name}
+	var unknown []string
+	for key := range raw {
+		if _, ok := kit.FlatFieldByName(typ, key); !ok && key != RawTypeKey {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		el.Els = append(el.Els, rawUnknownToXML(key, raw[key]))
+	}
+	for _, f := range kit.FlatFields(typ) {
+		v, has := raw[f.Name]
+		if !has || f.PkgPath != "" || f.Tag.Get("xml") == "-" {
+			continue
+		}
+		fnm := xml.Name{Local: f.Name}
+		switch {
+		case f.Name == "Kids":
+			kel, err := rawKidsToXML(v)
+			if err != nil {
+				return el, err
+			}
+			el.Els = append(el.Els, kel)
+		case f.Type.Kind() == reflect.Struct && IsKi(f.Type):
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return el, fmt.Errorf("field %v must be a map, not: %T", f.Name, v)
+			}
+			fel, err := rawToXML(m, f.Type, fnm)
+			if err != nil {
+				return el, err
+			}
+			el.Els = append(el.Els, fel)
+		default:
+			jb, err := json.Marshal(v)
+			if err != nil {
+				return el, fmt.Errorf("field %v: %v", f.Name, err)
+			}
+			pv := reflect.New(f.Type)
+			if err := json.Unmarshal(jb, pv.Interface()); err != nil {
+				return el, fmt.Errorf("field %v: %v", f.Name, err)
+			}
+			var b bytes.Buffer
+			e := xml.NewEncoder(&b)
+			if err := e.EncodeElement(pv.Interface(), xml.StartElement{Name: fnm}); err != nil {
+				return el, fmt.Errorf("field %v: %v", f.Name, err)
+			}
+			e.Flush()
+			fels, err := xmlEls(b.Bytes())
+			if err != nil {
+				return el, fmt.Errorf("field %v: %v", f.Name, err)
+			}
+			el.Els = append(el.Els, fels...)
+		}
+	}
+	return el, nil
+}
+
+// rawUnknownToXML returns the element of given name for a raw value that is
+// not a field of the type
+func rawUnknownToXML(name string, v interface{}) xmlEl {
+	el := xmlEl{XMLName: xml.Name{Local: name}}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		if v != nil {
+			el.Text = fmt.Sprint(v)
+		}
+		return el
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		el.Els = append(el.Els, rawUnknownToXML(key, m[key]))
+	}
+	return el
+}
+
+// rawKidsToXML returns the Kids element from the raw list of kids
+func rawKidsToXML(v interface{}) (xmlEl, error) {
+	el := xmlEl{XMLName: xml.Name{Local: "Kids"}}
+	kids, ok := v.([]interface{})
+	if v != nil && !ok {
+		return el, fmt.Errorf("Kids must be a list, not: %T", v)
+	}
+	el.Els = append(el.Els, xmlEl{XMLName: xml.Name{Local: "N"}, Text: strconv.Itoa(len(kids))})
+	typs := make([]reflect.Type, len(kids))
+	for i, kv := range kids {
+		kid, ok := kv.(map[string]interface{})
+		if !ok {
+			return el, fmt.Errorf("Kids element %d must be a map, not: %T", i, kv)
+		}
+		tn, _ := kid[RawTypeKey].(string)
+		if typs[i] = kit.Types.Type(tn); typs[i] == nil {
+			return el, fmt.Errorf("Kids element %d: kit.Types type name not found: %v", i, tn)
+		}
+		nm, ok := kid["UniqueNm"].(string)
+		if !ok || nm == "" {
+			nm, _ = kid["Nm"].(string)
+		}
+		el.Els = append(el.Els, xmlEl{XMLName: xml.Name{Local: "Type"}, Attrs: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: nm}}, Text: tn})
+	}
+	for i, kv := range kids {
+		kel, err := rawToXML(kv.(map[string]interface{}), typs[i], xml.Name{Local: typs[i].Name()})
+		if err != nil {
+			return el, fmt.Errorf("Kids element %d: %v", i, err)
+		}
+		el.Els = append(el.Els, kel)
+	}
+	return el, nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/goki/ki/kit"
+)
+
+type NodeMigrate struct {
+	Node
+	Label string
+}
+
+var KiT_NodeMigrate = kit.Types.AddType(&NodeMigrate{}, nil)
+
+// addNodeMigrations registers the migrations of NodeMigrate, returning a
+// function to remove them again, so other tests are not affected.
+func addNodeMigrations() func() {
+	kit.Types.AddMigration(KiT_NodeMigrate, 0, func(raw map[string]interface{}) error {
+		raw["Label"] = raw["Title"]
+		delete(raw, "Title")
+		return nil
+	})
+	kit.Types.AddMigration(KiT_NodeMigrate, 1, func(raw map[string]interface{}) error {
+		kids, _ := raw["Kids"].([]interface{})
+		raw["Kids"] = append(kids, map[string]interface{}{RawTypeKey: "ki.NodeEmbed", "Nm": "extra", "Mbr2": 2})
+		return nil
+	})
+	return func() {
+		kit.TypesMu.Lock()
+		delete(kit.Types.Migrations, kit.LongTypeName(KiT_NodeMigrate))
+		kit.TypesMu.Unlock()
+	}
+}
+
+func TestMigrateJSON(t *testing.T) {
+	root := NodeEmbed{}
+	root.InitName(&root, "root")
+	m := root.AddNewChild(KiT_NodeMigrate, "m").(*NodeMigrate)
+	m.Label = "first"
+	m.AddNewChild(KiT_NodeMigrate, "sub").(*NodeMigrate).Label = "second"
+	m.AddNewChild(KiT_NodeEmbed, "emb")
+
+	// version 0 file, where Label was Title
+	var buf bytes.Buffer
+	if err := root.WriteJSON(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	old := strings.Replace(buf.String(), `"Label"`, `"Title"`, -1)
+	if strings.Contains(old, "ki.Versions") {
+		t.Errorf("header should not have versions without migrations:\n%v", old)
+	}
+
+	defer addNodeMigrations()()
+
+	nwnd, migs, err := ReadNewJSONMigrated(strings.NewReader(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm := nwnd.ChildByName("m", 0).(*NodeMigrate)
+	if nm.Label != "first" || nm.Child(0).(*NodeMigrate).Label != "second" {
+		t.Errorf("fields not migrated: %v %v", nm.Label, nm.Child(0).(*NodeMigrate).Label)
+	}
+	if nm.NumChildren() != 3 || nm.Child(2).Name() != "extra" || nm.Child(2).(*NodeEmbed).Mbr2 != 2 || nm.Child(2).Parent() != nm {
+		t.Errorf("kids not migrated: %v", *nm.Children())
+	}
+	if nm.Child(0).NumChildren() != 1 {
+		t.Errorf("nested node not migrated: %v", *nm.Child(0).Children())
+	}
+	want := []Migrated{{Path: "m", Type: "ki.NodeMigrate", From: 0, To: 2}, {Path: "m/sub", Type: "ki.NodeMigrate", From: 0, To: 2}}
+	if fmt.Sprint(migs) != fmt.Sprint(want) {
+		t.Errorf("migrations: expected %v, got: %v", want, migs)
+	}
+
+	// current files record the version and are not migrated again
+	buf.Reset()
+	nwnd.WriteJSON(&buf, false)
+	if !strings.HasPrefix(buf.String(), `{"ki.RootType": "ki.NodeEmbed", "ki.Versions": {"ki.NodeMigrate":2}}`) {
+		t.Errorf("header missing versions: %v", buf.String()[:80])
+	}
+	migs, err = root.ReadJSONMigrated(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migs) != 0 || m.NumChildren() != 3 || m.Label != "first" {
+		t.Errorf("current version should not be migrated: %v", migs)
+	}
+
+	// migration errors
+	kit.Types.AddMigration(KiT_NodeMigrate, 1, func(raw map[string]interface{}) error {
+		return fmt.Errorf("cannot migrate")
+	})
+	_, err = ReadNewJSON(strings.NewReader(old))
+	if err == nil || !strings.Contains(err.Error(), "cannot migrate") {
+		t.Errorf("expected migration error, got: %v", err)
+	}
+	kit.Types.AddMigration(KiT_NodeMigrate, 1, func(raw map[string]interface{}) error {
+		raw["Kids"] = []interface{}{map[string]interface{}{RawTypeKey: "ki.NoSuchType"}}
+		return nil
+	})
+	if _, err = ReadNewJSON(strings.NewReader(old)); err == nil {
+		t.Errorf("expected error for migrated kid of unknown type")
+	}
+}
+
+func TestMigrateXML(t *testing.T) {
+	root := NodeEmbed{}
+	root.InitName(&root, "root")
+	m := root.AddNewChild(KiT_NodeMigrate, "m").(*NodeMigrate)
+	m.Label = "first"
+	m.SetProp("p", 1)
+	m.AddNewChild(KiT_NodeMigrate, "sub").(*NodeMigrate).Label = "second"
+	var buf bytes.Buffer
+	if err := root.WriteXML(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	// version 0 file, where Label was Title
+	old := strings.Replace(buf.String(), "Label>", "Title>", -1)
+	if !strings.HasPrefix(old, `<?ki RootType="ki.NodeEmbed"?>`) {
+		t.Errorf("XML header missing: %v", old)
+	}
+
+	defer addNodeMigrations()()
+
+	nwnd := NodeEmbed{}
+	nwnd.InitName(&nwnd, "root")
+	migs, err := nwnd.ReadXMLMigrated(strings.NewReader(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migrated{{Path: "m", Type: "ki.NodeMigrate", From: 0, To: 2}, {Path: "m/sub", Type: "ki.NodeMigrate", From: 0, To: 2}}
+	if fmt.Sprint(migs) != fmt.Sprint(want) {
+		t.Errorf("migrations: expected %v, got: %v", want, migs)
+	}
+	nm := nwnd.ChildByName("m", 0).(*NodeMigrate)
+	// props of migrated nodes have the JSON types, as for ReadJSON
+	if p, _ := kit.ToInt(nm.Prop("p")); nm.Label != "first" || nm.Child(0).(*NodeMigrate).Label != "second" || p != 1 {
+		t.Errorf("fields not migrated: %v %v %v", nm.Label, nm.Child(0).(*NodeMigrate).Label, nm.Prop("p"))
+	}
+	if nm.NumChildren() != 2 || nm.Child(1).Name() != "extra" || nm.Child(1).(*NodeEmbed).Mbr2 != 2 || nm.Child(1).Parent() != nm {
+		t.Errorf("kids not migrated: %v", *nm.Children())
+	}
+	if nm.Child(0).NumChildren() != 1 {
+		t.Errorf("nested node not migrated: %v", *nm.Child(0).Children())
+	}
+
+	// current files record the version and are not migrated again
+	buf.Reset()
+	nwnd.WriteXML(&buf, false)
+	if !strings.HasPrefix(buf.String(), `<?ki RootType="ki.NodeEmbed" Versions="ki.NodeMigrate:2"?>`) {
+		t.Errorf("XML header missing versions: %v", buf.String())
+	}
+	migs, err = root.ReadXMLMigrated(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Errorf("XML at current version: %v", err)
+	}
+	if len(migs) != 0 || m.NumChildren() != 2 || m.Label != "first" {
+		t.Errorf("current version should not be migrated: %v", migs)
+	}
+
+	// failed migrations leave the tree unchanged
+	kit.Types.AddMigration(KiT_NodeMigrate, 1, func(raw map[string]interface{}) error {
+		return fmt.Errorf("cannot migrate")
+	})
+	if err := root.ReadXML(strings.NewReader(old)); err == nil || !strings.Contains(err.Error(), "cannot migrate") {
+		t.Errorf("expected migration error, got: %v", err)
+	}
+	if m.NumChildren() != 2 || m.Label != "first" || root.NumChildren() != 1 {
+		t.Errorf("tree changed by failed migration")
+	}
+}
@@ -8,15 +8,17 @@
 package ki
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/gob"
-	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	pathpkg "path"
+	"slices"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -42,15 +44,20 @@ import (
 type Node struct {
 	Nm        string    `copy:"-" label:"Name" desc:"Ki.Name() user-supplied name of this node -- can be empty or non-unique"`
 	UniqueNm  string    `tableview:"-" copy:"-" label:"UniqueName" desc:"Ki.UniqueName() automatically-updated version of Name that is guaranteed to be unique within the slice of Children within one Node -- used e.g., for saving Unique Paths in Ptr pointers"`
+	Uid       uint64    `tableview:"-" copy:"-" json:",omitempty" xml:",omitempty" label:"ID" desc:"Ki.ID() optional stable identifier of this node, which is preserved through save and load and not affected by renaming -- see NodeIDs and IDIndex"`
 	Flag      int64     `tableview:"-" copy:"-" json:"-" xml:"-" max-width:"80" height:"3" desc:"bit flags for internal node state"`
-	Props     Props     `tableview:"-" xml:"-" copy:"-" label:"Properties" desc:"Ki.Properties() property map for arbitrary extensible properties, including style properties"`
+	Props     Props     `tableview:"-" copy:"-" label:"Properties" desc:"Ki.Properties() property map for arbitrary extensible properties, including style properties"`
 	Par       Ki        `tableview:"-" copy:"-" json:"-" xml:"-" label:"Parent" view:"-" desc:"Ki.Parent() parent of this node -- set automatically when this node is added as a child of parent"`
 	Kids      Slice     `tableview:"-" copy:"-" label:"Children" desc:"Ki.Children() list of children of this node -- all are set to have this node as their parent -- can reorder etc but generally use Ki Node methods to Add / Delete to ensure proper usage"`
 	NodeSig   Signal    `copy:"-" json:"-" xml:"-" view:"-" desc:"Ki.NodeSignal() signal for node structure / state changes -- emits NodeSignals signals -- can also extend to custom signals (see signal.go) but in general better to create a new Signal instead"`
+	SigLinks  []SignalLink `tableview:"-" copy:"-" json:",omitempty" xml:",omitempty" view:"-" label:"Links" desc:"Ki.Links() records of the signal connections from this node made with Link, which are saved with it and re-established after loading"`
 	Ths       Ki        `copy:"-" json:"-" xml:"-" view:"-" desc:"we need a pointer to ourselves as a Ki, which can always be used to extract the true underlying type of object when Node is embedded in other structs -- function receivers do not have this ability so this is necessary.  This is set to nil when deleted.  Typically use This() convenience accessor which protects against concurrent access."`
-	index     int       `copy:"-" json:"-" xml:"-" view:"-" desc:"last value of our index -- used as a starting point for finding us in our parent next time -- is not guaranteed to be accurate!  use Index() method"`
-	depth     int       `copy:"-" json:"-" xml:"-" view:"-" desc:"optional depth parameter of this node -- only valid during specific contexts, not generally -- e.g., used in FuncDownBreadthFirst function"`
-	fieldOffs []uintptr `copy:"-" json:"-" xml:"-" view:"-" desc:"cached version of the field offsets relative to base Node address -- used in generic field access."`
+	index     int64          `copy:"-" json:"-" xml:"-" view:"-" desc:"last value of our index -- used as a starting point for finding us in our parent next time -- is not guaranteed to be accurate!  use Index() method"`
+	depth     int64          `copy:"-" json:"-" xml:"-" view:"-" desc:"optional depth parameter of this node -- only valid during specific contexts, not generally -- e.g., used in FuncDownBreadthFirst function"`
+	fieldOffs []uintptr      `copy:"-" json:"-" xml:"-" view:"-" desc:"cached version of the field offsets relative to base Node address -- used in generic field access."`
+	tlock     unsafe.Pointer `copy:"-" json:"-" xml:"-" view:"-" desc:"*TreeLock of the tree this node is in, when in concurrent mode -- see NewTreeLock"`
+	changes   []Change       `copy:"-" json:"-" xml:"-" view:"-" desc:"changes recorded within the tree during the last update batch started by this node -- see Changes"`
+	updtLock  *TreeLock      `copy:"-" json:"-" xml:"-" view:"-" desc:"TreeLock held by the update batch started by this node, released by UpdateEnd -- kept here as the node can move to another tree within the batch"`
 }
 
 // must register all new types so type names can be looked up by name -- also props
@@ -100,6 +107,9 @@ func (n *Node) Init(this Ki) {
 	n.ClearFlagMask(int64(UpdateFlagsMask))
 	if n.Ths != this {
 		n.Ths = this
+		if NodeIDs && n.ID() == 0 {
+			atomic.StoreUint64(&n.Uid, NewID())
+		}
 		if !n.HasKiFields() {
 			return
 		}
@@ -199,13 +209,26 @@ func (n *Node) UniqueName() string {
 // already set to that value -- returns false in that case.  Does NOT
 // wrap in UpdateStart / End.
 func (n *Node) SetName(name string) bool {
+	defer n.lockTree().Unlock()
+	return n.setNameLocked(name)
+}
+
+// setNameLocked is SetName under the tree lock
+func (n *Node) setNameLocked(name string) bool {
 	if n.Nm == name {
 		return false
 	}
+	if us := undoRecorder(n.This()); us != nil {
+		un := uniqSnap(n.Par)
+		if un == nil {
+			un = uniqNames{n.This(): n.UniqueNm}
+		}
+		us.record(&undoRename{k: n.This(), nm: n.Nm, uniqs: un})
+	}
 	n.Nm = name
 	n.SetUniqueName(SafeUniqueName(name))
 	if n.Par != nil {
-		n.Par.UniquifyNames()
+		n.Par.AsNode().uniquifyNamesLocked()
 	}
 	return true
 }
@@ -242,6 +265,12 @@ var UniquifyPreserveNameLimit = 100
 // 100), above which the index is appended, guaranteeing uniqueness at the
 // cost of making paths longer and less user-friendly
 func (n *Node) UniquifyNames() {
+	defer n.lockTree().Unlock()
+	n.uniquifyNamesLocked()
+}
+
+// uniquifyNamesLocked is UniquifyNames under the tree lock
+func (n *Node) uniquifyNamesLocked() {
 	// pr := prof.Start("ki.Node.UniquifyNames")
 	// defer pr.End()
 
@@ -290,17 +319,52 @@ func (n *Node) UniquifyNames() {
 // Parent returns the parent of this Ki (Node.Par) -- Ki has strict
 // one-parent, no-cycles structure -- see SetParent.
 func (n *Node) Parent() Ki {
+	defer n.rlockTree().RUnlock()
 	return n.Par
 }
 
 // SetParent just sets parent of node (and inherits update count from
 // parent, to keep consistent) -- does NOT remove from existing parent --
 // use Add / Insert / Delete Child functions properly move or delete nodes.
+// In concurrent mode, the node joins the TreeLock of the parent's tree,
+// and leaves it when the parent is set to nil.
 func (n *Node) SetParent(parent Ki) {
+	var tl *TreeLock
+	if parent != nil {
+		tl = parent.AsNode().lockTree()
+	} else {
+		tl = n.lockTree()
+	}
+	defer tl.Unlock()
+	defer lockOther(n, tl).Unlock()
+	n.setParentLocked(parent)
+}
+
+// setParentLocked is SetParent under the write locks of the trees of both
+// the node and the new parent
+func (n *Node) setParentLocked(parent Ki) {
+	var oldRoot Ki
+	if atomic.LoadInt32(&idIndexActive) != 0 {
+		oldRoot = rootLocked(n.This())
+	}
 	n.Par = parent
+	if oldRoot != nil {
+		newRoot := n.This()
+		if parent != nil {
+			newRoot = rootLocked(parent)
+		}
+		idIndexMove(n.This(), oldRoot, newRoot)
+	}
+	var tl *TreeLock
+	if parent != nil {
+		tl = parent.AsNode().treeLock()
+	}
+	if n.treeLock() != tl {
+		setTreeLockDown(n, tl)
+	}
 	if parent != nil && !parent.OnlySelfUpdate() {
 		parup := parent.IsUpdating()
-		n.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		walkDownLocked(n.This(), func(k Ki) bool {
 			k.SetFlagState(parup, int(Updating))
 			return true
 		})
@@ -309,6 +373,7 @@ func (n *Node) SetParent(parent Ki) {
 
 // IsRoot tests if this node is the root node -- checks Parent = nil.
 func (n *Node) IsRoot() bool {
+	defer n.rlockTree().RUnlock()
 	if n.This() == nil || n.Par == nil || n.Par.This() == nil {
 		return true
 	}
@@ -317,10 +382,11 @@ func (n *Node) IsRoot() bool {
 
 // Root returns the root object of this tree (the node with a nil parent).
 func (n *Node) Root() Ki {
-	if n.IsRoot() {
-		return n.This()
+	defer n.rlockTree().RUnlock()
+	if n.This() == nil {
+		return nil
 	}
-	return n.Par.Root()
+	return rootLocked(n.This())
 }
 
 // FieldRoot returns the field root object for this node -- the node that
@@ -350,12 +416,13 @@ func (n *Node) FieldRoot() Ki {
 // last value and uses that for an optimized search so subsequent calls
 // are typically quite fast.  Returns false if we don't have a parent.
 func (n *Node) IndexInParent() (int, bool) {
+	defer n.rlockTree().RUnlock()
 	if n.Par == nil {
 		return -1, false
 	}
-	var ok bool
-	n.index, ok = n.Par.Children().IndexOf(n.This(), n.index) // very fast if index is close..
-	return n.index, ok
+	idx, ok := n.Par.Children().IndexOf(n.This(), int(atomic.LoadInt64(&n.index))) // very fast if index is close..
+	atomic.StoreInt64(&n.index, int64(idx))
+	return idx, ok
 }
 
 // ParentLevel finds a given potential parent node recursively up the
@@ -382,6 +449,7 @@ func (n *Node) HasParent(par Ki) bool {
 // ParentByName finds first parent recursively up hierarchy that matches
 // given name -- returns nil if not found.
 func (n *Node) ParentByName(name string) Ki {
+	defer n.rlockTree().RUnlock()
 	if n.IsRoot() {
 		return nil
 	}
@@ -405,6 +473,7 @@ func (n *Node) ParentByNameTry(name string) (Ki, error) {
 // returns nil if not found. If embeds is true, then it looks for any
 // type that embeds the given type at any level of anonymous embedding.
 func (n *Node) ParentByType(t reflect.Type, embeds bool) Ki {
+	defer n.rlockTree().RUnlock()
 	if n.IsRoot() {
 		return nil
 	}
@@ -482,9 +551,8 @@ func (n *Node) KiFieldByName(name string) Ki {
 		return nil
 	}
 	foffs := n.KiFieldOffs()
-	op := uintptr(unsafe.Pointer(n))
 	for _, fo := range foffs {
-		fn := (*Node)(unsafe.Pointer(op + fo))
+		fn := (*Node)(unsafe.Pointer(uintptr(unsafe.Pointer(n)) + fo))
 		if fn.Nm == name {
 			return fn.This()
 		}
@@ -553,11 +621,13 @@ func (n *Node) KiFieldsInit() (foff []uintptr, fnm []string) {
 
 // HasChildren tests whether this node has children (i.e., non-terminal).
 func (n *Node) HasChildren() bool {
+	defer n.rlockTree().RUnlock()
 	return len(n.Kids) > 0
 }
 
 // NumChildren returns the number of children of this node.
 func (n *Node) NumChildren() int {
+	defer n.rlockTree().RUnlock()
 	return len(n.Kids)
 }
 
@@ -565,6 +635,7 @@ func (n *Node) NumChildren() int {
 // methods on ki.Slice for further ways to access (ByName, ByType, etc).
 // Slice can be modified directly (e.g., sort, reorder) but Add* / Delete*
 // methods on parent node should be used to ensure proper tracking.
+// In concurrent mode, only use while holding the TreeLock.
 func (n *Node) Children() *Slice {
 	return &n.Kids
 }
@@ -572,6 +643,7 @@ func (n *Node) Children() *Slice {
 // IsValidIndex returns error if given index is not valid for accessing children
 // nil otherwise.
 func (n *Node) IsValidIndex(idx int) error {
+	defer n.rlockTree().RUnlock()
 	sz := len(n.Kids)
 	if idx >= 0 && idx < sz {
 		return nil
@@ -582,12 +654,14 @@ func (n *Node) IsValidIndex(idx int) error {
 // Child returns the child at given index -- will panic if index is invalid.
 // See methods on ki.Slice for more ways to access.
 func (n *Node) Child(idx int) Ki {
+	defer n.rlockTree().RUnlock()
 	return n.Kids[idx]
 }
 
 // ChildTry returns the child at given index.  Try version returns error if index is invalid.
 // See methods on ki.Slice for more ways to acces.
 func (n *Node) ChildTry(idx int) (Ki, error) {
+	defer n.rlockTree().RUnlock()
 	if err := n.IsValidIndex(idx); err != nil {
 		return nil, err
 	}
@@ -599,6 +673,7 @@ func (n *Node) ChildTry(idx int) (Ki, error) {
 // an idea where it might be -- can be key speedup for large lists -- pass
 // -1 to start in the middle (good default).
 func (n *Node) ChildByName(name string, startIdx int) Ki {
+	defer n.rlockTree().RUnlock()
 	return n.Kids.ElemByName(name, startIdx)
 }
 
@@ -607,6 +682,7 @@ func (n *Node) ChildByName(name string, startIdx int) Ki {
 // an idea where it might be -- can be key speedup for large lists -- pass
 // -1 to start in the middle (good default).
 func (n *Node) ChildByNameTry(name string, startIdx int) (Ki, error) {
+	defer n.rlockTree().RUnlock()
 	idx, ok := n.Kids.IndexByName(name, startIdx)
 	if !ok {
 		return nil, fmt.Errorf("ki %v: child named: %v not found", n.Nm, name)
@@ -621,6 +697,7 @@ func (n *Node) ChildByNameTry(name string, startIdx int) (Ki, error) {
 // an idea where it might be -- can be key speedup for large lists -- pass
 // -1 to start in the middle (good default).
 func (n *Node) ChildByType(t reflect.Type, embeds bool, startIdx int) Ki {
+	defer n.rlockTree().RUnlock()
 	return n.Kids.ElemByType(t, embeds, startIdx)
 }
 
@@ -632,6 +709,7 @@ func (n *Node) ChildByType(t reflect.Type, embeds bool, startIdx int) Ki {
 // an idea where it might be -- can be key speedup for large lists -- pass
 // -1 to start in the middle (good default).
 func (n *Node) ChildByTypeTry(t reflect.Type, embeds bool, startIdx int) (Ki, error) {
+	defer n.rlockTree().RUnlock()
 	idx, ok := n.Kids.IndexByType(t, embeds, startIdx)
 	if !ok {
 		return nil, fmt.Errorf("ki %v: child of type: %t not found", n.Nm, t)
@@ -646,6 +724,7 @@ func (n *Node) ChildByTypeTry(t reflect.Type, embeds bool, startIdx int) (Ki, er
 // Name's (may be empty or non-unique), with nodes separated by / and
 // fields by . -- only use for informational purposes.
 func (n *Node) Path() string {
+	defer n.rlockTree().RUnlock()
 	if n.Par != nil {
 		if n.IsField() {
 			return n.Par.Path() + "." + n.Nm
@@ -659,11 +738,17 @@ func (n *Node) Path() string {
 // with nodes separated by / and fields by . -- suitable for reliably
 // finding this node.
 func (n *Node) PathUnique() string {
+	defer n.rlockTree().RUnlock()
+	return n.pathUniqueLocked()
+}
+
+// pathUniqueLocked is PathUnique under the tree lock
+func (n *Node) pathUniqueLocked() string {
 	if n.Par != nil {
 		if n.IsField() {
-			return n.Par.PathUnique() + "." + n.UniqueNm
+			return n.Par.AsNode().pathUniqueLocked() + "." + n.UniqueNm
 		}
-		return n.Par.PathUnique() + "/" + n.UniqueNm
+		return n.Par.AsNode().pathUniqueLocked() + "/" + n.UniqueNm
 	}
 	return "/" + n.UniqueNm
 }
@@ -672,6 +757,7 @@ func (n *Node) PathUnique() string {
 // regular user-given Name's (may be empty or non-unique), with nodes
 // separated by / and fields by . -- only use for informational purposes.
 func (n *Node) PathFrom(par Ki) string {
+	defer n.rlockTree().RUnlock()
 	if n.Par != nil && n.Par != par {
 		if n.IsField() {
 			return n.Par.PathFrom(par) + "." + n.Nm
@@ -685,6 +771,7 @@ func (n *Node) PathFrom(par Ki) string {
 // unique names, with nodes separated by / and fields by . -- suitable for
 // reliably finding this node.
 func (n *Node) PathFromUnique(par Ki) string {
+	defer n.rlockTree().RUnlock()
 	if n.Par != nil {
 		ppath := ""
 		if n.Par == par {
@@ -723,46 +810,166 @@ func findPathChild(k Ki, child string) (int, bool) {
 // to this node is subtracted from the start of the path if present there.
 // There is also support for [idx] index-based access for any given path
 // element, for cases when indexes are more useful than names.
+// Path elements can also be . (this node), .. (parent), * or glob patterns
+// (e.g., "but*", matched against unique names, and Ki field names), and
+// ** (this node and all nodes below it) -- if there are multiple matches,
+// the first in depth-first order is returned -- see FindPaths for all.
+// A path element that is exactly the unique name of a child (or name of a
+// Ki field) always matches just that node, even if it contains glob
+// characters, so literal names such as "a[b]" still resolve.
 // Returns nil if not found.
 func (n *Node) FindPathUnique(path string) Ki {
+	defer n.rlockTree().RUnlock()
+	res := n.findPathsLocked(path, false)
+	if len(res) == 0 {
+		return nil
+	}
+	return res[0]
+}
+
+// FindPaths returns all Ki objects that match given unique path, starting
+// from this node, in depth-first order -- see FindPathUnique for the path
+// syntax, including . .. * ** and glob patterns.  Returns nil if none.
+func (n *Node) FindPaths(path string) []Ki {
+	defer n.rlockTree().RUnlock()
+	return n.findPathsLocked(path, true)
+}
+
+// findPathsLocked implements FindPathUnique and FindPaths, under the tree
+// lock
+func (n *Node) findPathsLocked(path string, all bool) []Ki {
 	if n.Par != nil { // we are not root..
-		myp := n.PathUnique()
+		myp := n.pathUniqueLocked()
 		path = strings.TrimPrefix(path, myp)
 	}
-	curn := Ki(n)
+	start := n.This()
+	cur := []Ki{start}
 	pels := strings.Split(strings.Trim(strings.TrimSpace(path), "\""), "/")
 	for i, pe := range pels {
 		if len(pe) == 0 {
 			continue
 		}
-		if i <= 1 && curn.UniqueName() == pe {
+		if i <= 1 && len(cur) == 1 && cur[0] == start && start.UniqueName() == pe {
 			continue
 		}
-		if strings.Contains(pe, ".") { // has fields
+		var nxt []Ki
+		switch pe {
+		case ".":
+			continue
+		case "..":
+			for _, k := range cur {
+				if par := k.AsNode().Par; par != nil {
+					nxt = append(nxt, par)
+				}
+			}
+		case "**":
+			for _, k := range cur {
+				nxt = appendDescendants(nxt, k)
+			}
+		default:
 			fels := strings.Split(pe, ".")
-			// find the child first, then the fields
-			idx, ok := findPathChild(curn, fels[0])
-			if !ok {
-				return nil
+			for _, k := range cur {
+				nxt = appendPathChildren(nxt, k, fels[0])
 			}
-			curn = (*(curn.Children()))[idx]
-			for i := 1; i < len(fels); i++ {
-				fe := fels[i]
-				fk := curn.KiFieldByName(fe)
-				if fk == nil {
-					return nil
+			for _, fe := range fels[1:] {
+				var fnxt []Ki
+				for _, k := range nxt {
+					fnxt = appendPathFields(fnxt, k, fe)
 				}
-				curn = fk
+				nxt = fnxt
 			}
-		} else {
-			idx, ok := findPathChild(curn, pe)
-			if !ok {
-				return nil
-			}
-			curn = (*(curn.Children()))[idx]
 		}
+		cur = uniqueKis(nxt)
+		if len(cur) == 0 {
+			return nil
+		}
+	}
+	if !all && len(cur) > 1 {
+		return cur[:1]
+	}
+	return cur
+}
+
+// isPathGlob returns true if path element is a glob pattern, not an [idx]
+func isPathGlob(pe string) bool {
+	if !strings.ContainsAny(pe, "*?[") {
+		return false
+	}
+	if pe[0] == '[' && pe[len(pe)-1] == ']' {
+		if _, err := strconv.Atoi(pe[1 : len(pe)-1]); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// appendPathChildren appends the children of k that match path element pe
+// -- empty pe is k itself, and a pe that is exactly the unique name of a
+// child matches only that child, before trying it as a glob pattern
+func appendPathChildren(ks []Ki, k Ki, pe string) []Ki {
+	if pe == "" {
+		return append(ks, k)
+	}
+	if idx, ok := findPathChild(k, pe); ok || !isPathGlob(pe) {
+		if ok {
+			ks = append(ks, (*(k.Children()))[idx])
+		}
+		return ks
+	}
+	for _, kid := range *k.Children() {
+		if ok, _ := pathpkg.Match(pe, kid.UniqueName()); ok {
+			ks = append(ks, kid)
+		}
+	}
+	return ks
+}
+
+// appendPathFields appends the Ki fields of k that match path element pe
+// -- an exact field name matches only that field, as in appendPathChildren
+func appendPathFields(ks []Ki, k Ki, pe string) []Ki {
+	if fk := k.KiFieldByName(pe); fk != nil || !isPathGlob(pe) {
+		if fk != nil {
+			ks = append(ks, fk)
+		}
+		return ks
+	}
+	nf := k.NumKiFields()
+	for i := 0; i < nf; i++ {
+		fk := k.KiField(i)
+		if ok, _ := pathpkg.Match(pe, fk.Name()); ok {
+			ks = append(ks, fk)
+		}
+	}
+	return ks
+}
+
+// appendDescendants appends k and all of its children, recursively, in
+// depth-first order
+func appendDescendants(ks []Ki, k Ki) []Ki {
+	ks = append(ks, k)
+	for _, kid := range *k.Children() {
+		if kid != nil {
+			ks = appendDescendants(ks, kid)
+		}
+	}
+	return ks
+}
+
+// uniqueKis removes duplicates from the list, preserving order
+func uniqueKis(ks []Ki) []Ki {
+	if len(ks) <= 1 {
+		return ks
+	}
+	has := make(map[Ki]struct{}, len(ks))
+	uk := ks[:0]
+	for _, k := range ks {
+		if _, ok := has[k]; ok {
+			continue
+		}
+		has[k] = struct{}{}
+		uk = append(uk, k)
 	}
-	return curn
+	return uk
 }
 
 // FindPathUniqueTry returns Ki object at given unique path, starting from
@@ -779,6 +986,34 @@ func (n *Node) FindPathUniqueTry(path string) (Ki, error) {
 	return nil, fmt.Errorf("ki %v: element at path: %v not found", n.Nm, path)
 }
 
+// QueryOne returns the first node within the subtree below this node (in
+// depth-first order, not including this node) that matches given
+// CSS-style selector -- see Selector for the syntax.  Compiled selectors
+// are cached.  Returns nil if not found, or if the selector is invalid
+// (error is logged).
+func (n *Node) QueryOne(sel string) Ki {
+	s, err := cachedSelector(sel)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return s.QueryOne(n.This())
+}
+
+// QueryAll returns all nodes within the subtree below this node (in
+// depth-first order, not including this node) that match given CSS-style
+// selector -- see Selector for the syntax.  Compiled selectors are cached.
+// Returns nil if none match, or if the selector is invalid (error is
+// logged).
+func (n *Node) QueryAll(sel string) []Ki {
+	s, err := cachedSelector(sel)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return s.QueryAll(n.This())
+}
+
 //////////////////////////////////////////////////////////////////////////
 //  Adding, Inserting Children
 
@@ -798,8 +1033,17 @@ func (n *Node) SetChildType(t reflect.Type) error {
 // NewOfType creates a new child of given type -- if nil, uses ChildType,
 // else uses the same type as this struct.
 func (n *Node) NewOfType(typ reflect.Type) Ki {
+	defer n.rlockTree().RUnlock()
+	return n.newOfTypeLocked(typ)
+}
+
+// newOfTypeLocked is NewOfType under the tree lock
+func (n *Node) newOfTypeLocked(typ reflect.Type) Ki {
 	if typ == nil {
-		ct, ok := n.PropInherit("ChildType", false, true) // no inherit but yes from type
+		ct, ok := n.Props["ChildType"]
+		if !ok {
+			ct, ok = kit.Types.Prop(n.Type(), "ChildType") // no inherit but yes from type
+		}
 		if ok {
 			if ctt, ok := ct.(reflect.Type); ok {
 				typ = ctt
@@ -816,16 +1060,20 @@ func (n *Node) NewOfType(typ reflect.Type) Ki {
 
 // AddChildCheck checks if it is safe to add child -- it cannot be a parent of us -- prevent loops!
 func (n *Node) AddChildCheck(kid Ki) error {
-	var err error
-	n.FuncUp(0, n, func(k Ki, level int, d interface{}) bool {
+	defer n.rlockTree().RUnlock()
+	return n.addChildCheckLocked(kid)
+}
+
+// addChildCheckLocked is AddChildCheck under the tree lock
+func (n *Node) addChildCheckLocked(kid Ki) error {
+	for _, k := range lineageLocked(n.This()) {
 		if k == kid {
-			err = fmt.Errorf("ki.Node Attempt to add child to node %v that is my own parent -- no cycles permitted", (d.(Ki)).PathUnique())
+			err := fmt.Errorf("ki.Node Attempt to add child to node %v that is my own parent -- no cycles permitted", n.pathUniqueLocked())
 			log.Println(err)
-			return Break
+			return err
 		}
-		return Continue
-	})
-	return err
+	}
+	return nil
 }
 
 // AddChild adds given child at end of children list -- if child is in an
@@ -835,29 +1083,37 @@ func (n *Node) AddChildCheck(kid Ki) error {
 // See Fast version if adding many children -- UniquifyNames can get
 // very expensive if called repeatedly on many nodes.
 func (n *Node) AddChild(kid Ki) error {
+	tl := n.lockTree()
+	defer tl.Unlock()
+	defer lockOther(kid, tl).Unlock()
 	if err := n.ThisCheck(); err != nil {
 		return err
 	}
-	if err := n.AddChildCheck(kid); err != nil {
+	if err := n.addChildCheckLocked(kid); err != nil {
 		return err
 	}
-	updt := n.UpdateStart()
+	updt := n.updateStartLocked()
+	us := undoRecorder(n.This())
+	un := uniqSnap(us.snapPar(n.This())).with(kid)
 	kid.Init(kid)
 	n.Kids = append(n.Kids, kid)
-	oldPar := kid.Parent()
-	kid.SetParent(n.This()) // key to set new parent before deleting: indicates move instead of delete
+	oldPar := kid.AsNode().Par
+	us.record(&undoChildIns{par: n.This(), kid: kid, idx: len(n.Kids) - 1, oldPar: oldPar, uniqs: un})
+	kid.AsNode().setParentLocked(n.This()) // key to set new parent before deleting: indicates move instead of delete
 	if oldPar != nil {
-		oldPar.DeleteChild(kid, false)
+		oldPar.AsNode().deleteChildLocked(kid, false)
 		kid.SetFlag(int(ChildMoved))
 	} else {
 		kid.SetFlag(int(ChildAdded))
 	}
 	n.SetFlag(int(ChildAdded))
+	idx, _ := n.Kids.IndexOf(kid, len(n.Kids)-1)
+	n.addChange(Change{Type: ChangeChildAdded, Kid: kid, Index: idx})
 	if kid.UniqueName() == "" {
 		kid.SetUniqueName(SafeUniqueName(kid.Name()))
 	}
-	n.UniquifyNames()
-	n.UpdateEnd(updt)
+	n.uniquifyNamesLocked()
+	n.updateEndLocked(updt)
 	return nil
 }
 
@@ -865,20 +1121,25 @@ func (n *Node) AddChild(kid Ki) error {
 // ChildType, else type of this struct -- and add at end of children list
 // -- assigns name (can be empty) and enforces UniqueName.
 func (n *Node) AddNewChild(typ reflect.Type, name string) Ki {
+	defer n.lockTree().Unlock()
 	if err := n.ThisCheck(); err != nil {
 		return nil
 	}
-	updt := n.UpdateStart()
-	kid := n.NewOfType(typ)
+	updt := n.updateStartLocked()
+	us := undoRecorder(n.This())
+	un := uniqSnap(us.snapPar(n.This()))
+	kid := n.newOfTypeLocked(typ)
 	kid.Init(kid)
 	n.Kids = append(n.Kids, kid)
+	us.record(&undoChildIns{par: n.This(), kid: kid, idx: len(n.Kids) - 1, uniqs: un})
 	kid.SetNameRaw(name)
-	kid.SetParent(n.This())
+	kid.AsNode().setParentLocked(n.This())
 	kid.SetFlag(int(ChildAdded))
 	n.SetFlag(int(ChildAdded))
+	n.addChange(Change{Type: ChangeChildAdded, Kid: kid, Index: len(n.Kids) - 1})
 	kid.SetUniqueName(SafeUniqueName(name))
-	n.UniquifyNames() // this is the killer time-sync for large node-count
-	n.UpdateEnd(updt)
+	n.uniquifyNamesLocked() // this is the killer time-sync for large node-count
+	n.updateEndLocked(updt)
 	return kid
 }
 
@@ -887,15 +1148,20 @@ func (n *Node) AddNewChild(typ reflect.Type, name string) Ki {
 // ensure names are unique, or run other checks, including if child
 // already has a parent.
 func (n *Node) AddChildFast(kid Ki) {
+	tl := n.lockTree()
+	defer tl.Unlock()
+	defer lockOther(kid, tl).Unlock()
 	if err := n.ThisCheck(); err != nil {
 		return
 	}
-	updt := n.UpdateStart()
+	updt := n.updateStartLocked()
 	n.Kids = append(n.Kids, kid)
-	kid.SetParent(n.This())
+	undoRecorder(n.This()).record(&undoChildIns{par: n.This(), kid: kid, idx: len(n.Kids) - 1})
+	kid.AsNode().setParentLocked(n.This())
 	kid.SetFlag(int(ChildAdded))
 	n.SetFlag(int(ChildAdded))
-	n.UpdateEnd(updt)
+	n.addChange(Change{Type: ChangeChildAdded, Kid: kid, Index: len(n.Kids) - 1})
+	n.updateEndLocked(updt)
 }
 
 // AddNewChildFast creates a new child of given type -- if nil, uses
@@ -905,19 +1171,22 @@ func (n *Node) AddChildFast(kid Ki) {
 // that all the names are indeed unique when added, or call UniquifyNames
 // after adding all the nodes.
 func (n *Node) AddNewChildFast(typ reflect.Type, name string) Ki {
+	defer n.lockTree().Unlock()
 	if err := n.ThisCheck(); err != nil {
 		return nil
 	}
-	updt := n.UpdateStart()
-	kid := n.NewOfType(typ)
+	updt := n.updateStartLocked()
+	kid := n.newOfTypeLocked(typ)
 	kid.Init(kid)
 	kid.SetNameRaw(name)
 	n.Kids = append(n.Kids, kid)
-	kid.SetParent(n.This())
+	undoRecorder(n.This()).record(&undoChildIns{par: n.This(), kid: kid, idx: len(n.Kids) - 1})
+	kid.AsNode().setParentLocked(n.This())
 	kid.SetFlag(int(ChildAdded))
 	n.SetFlag(int(ChildAdded))
+	n.addChange(Change{Type: ChangeChildAdded, Kid: kid, Index: len(n.Kids) - 1})
 	kid.SetUniqueName(name)
-	n.UpdateEnd(updt)
+	n.updateEndLocked(updt)
 	return kid
 }
 
@@ -926,29 +1195,40 @@ func (n *Node) AddNewChildFast(typ reflect.Type, name string) Ki {
 // NodeMoved signal is emitted for the child -- UniquifyNames is called
 // after adding to ensure name is unique (assumed to already have a name).
 func (n *Node) InsertChild(kid Ki, at int) error {
+	tl := n.lockTree()
+	defer tl.Unlock()
+	defer lockOther(kid, tl).Unlock()
 	if err := n.ThisCheck(); err != nil {
 		return err
 	}
-	if err := n.AddChildCheck(kid); err != nil {
+	if err := n.addChildCheckLocked(kid); err != nil {
 		return err
 	}
-	updt := n.UpdateStart()
+	updt := n.updateStartLocked()
+	us := undoRecorder(n.This())
+	un := uniqSnap(us.snapPar(n.This())).with(kid)
 	kid.Init(kid)
 	n.Kids.Insert(kid, at)
-	oldPar := kid.Parent()
-	kid.SetParent(n.This()) // key to set new parent before deleting: indicates move instead of delete
+	oldPar := kid.AsNode().Par
+	if us != nil {
+		idx, _ := n.Kids.IndexOf(kid, at)
+		us.record(&undoChildIns{par: n.This(), kid: kid, idx: idx, oldPar: oldPar, uniqs: un})
+	}
+	kid.AsNode().setParentLocked(n.This()) // key to set new parent before deleting: indicates move instead of delete
 	if oldPar != nil {
-		oldPar.DeleteChild(kid, false)
+		oldPar.AsNode().deleteChildLocked(kid, false)
 		kid.SetFlag(int(ChildMoved))
 	} else {
 		kid.SetFlag(int(ChildAdded))
 	}
 	n.SetFlag(int(ChildAdded))
+	idx, _ := n.Kids.IndexOf(kid, at)
+	n.addChange(Change{Type: ChangeChildAdded, Kid: kid, Index: idx})
 	if kid.UniqueName() == "" {
 		kid.SetUniqueName(SafeUniqueName(kid.Name()))
 	}
-	n.UniquifyNames()
-	n.UpdateEnd(updt)
+	n.uniquifyNamesLocked()
+	n.updateEndLocked(updt)
 	return nil
 }
 
@@ -956,20 +1236,29 @@ func (n *Node) InsertChild(kid Ki, at int) error {
 // ChildType, else type of this struct -- and add at given position in
 // children list -- assigns name (can be empty) and enforces UniqueName.
 func (n *Node) InsertNewChild(typ reflect.Type, at int, name string) Ki {
+	defer n.lockTree().Unlock()
 	if err := n.ThisCheck(); err != nil {
 		return nil
 	}
-	updt := n.UpdateStart()
-	kid := n.NewOfType(typ)
+	updt := n.updateStartLocked()
+	us := undoRecorder(n.This())
+	un := uniqSnap(us.snapPar(n.This()))
+	kid := n.newOfTypeLocked(typ)
 	kid.Init(kid)
 	n.Kids.Insert(kid, at)
+	if us != nil {
+		idx, _ := n.Kids.IndexOf(kid, at)
+		us.record(&undoChildIns{par: n.This(), kid: kid, idx: idx, uniqs: un})
+	}
 	kid.SetNameRaw(name)
-	kid.SetParent(n.This())
+	kid.AsNode().setParentLocked(n.This())
 	kid.SetFlag(int(ChildAdded))
 	n.SetFlag(int(ChildAdded))
+	idx, _ := n.Kids.IndexOf(kid, at)
+	n.addChange(Change{Type: ChangeChildAdded, Kid: kid, Index: idx})
 	kid.SetUniqueName(SafeUniqueName(name))
-	n.UniquifyNames() // this is the killer time-sync for large node-count
-	n.UpdateEnd(updt)
+	n.uniquifyNamesLocked() // this is the killer time-sync for large node-count
+	n.updateEndLocked(updt)
 	return kid
 }
 
@@ -980,19 +1269,26 @@ func (n *Node) InsertNewChild(typ reflect.Type, at int, name string) Ki {
 // that all the names are indeed unique when added, or call UniquifyNames
 // after adding all the nodes.
 func (n *Node) InsertNewChildFast(typ reflect.Type, at int, name string) Ki {
+	defer n.lockTree().Unlock()
 	if err := n.ThisCheck(); err != nil {
 		return nil
 	}
-	updt := n.UpdateStart()
-	kid := n.NewOfType(typ)
+	updt := n.updateStartLocked()
+	kid := n.newOfTypeLocked(typ)
 	kid.Init(kid)
 	kid.SetNameRaw(name)
 	n.Kids.Insert(kid, at)
-	kid.SetParent(n.This())
+	if us := undoRecorder(n.This()); us != nil {
+		idx, _ := n.Kids.IndexOf(kid, at)
+		us.record(&undoChildIns{par: n.This(), kid: kid, idx: idx})
+	}
+	kid.AsNode().setParentLocked(n.This())
 	kid.SetFlag(int(ChildAdded))
 	n.SetFlag(int(ChildAdded))
+	idx, _ := n.Kids.IndexOf(kid, at)
+	n.addChange(Change{Type: ChangeChildAdded, Kid: kid, Index: idx})
 	kid.SetUniqueName(name)
-	n.UpdateEnd(updt)
+	n.updateEndLocked(updt)
 	return kid
 }
 
@@ -1002,6 +1298,9 @@ func (n *Node) InsertNewChildFast(typ reflect.Type, at int, name string) Ki {
 // names -- this is for high-volume child creation -- call UniquifyNames
 // afterward if needed, but better to ensure that names are unique up front.
 func (n *Node) SetChild(kid Ki, idx int, name string) error {
+	tl := n.lockTree()
+	defer tl.Unlock()
+	defer lockOther(kid, tl).Unlock()
 	if err := n.Kids.IsValidIndex(idx); err != nil {
 		return err
 	}
@@ -1011,7 +1310,8 @@ func (n *Node) SetChild(kid Ki, idx int, name string) error {
 		kid.Init(kid)
 	}
 	n.Kids[idx] = kid
-	kid.SetParent(n.This())
+	kid.AsNode().setParentLocked(n.This())
+	n.addChange(Change{Type: ChangeChildAdded, Kid: kid, Index: idx})
 	return nil
 }
 
@@ -1019,12 +1319,15 @@ func (n *Node) SetChild(kid Ki, idx int, name string) error {
 // children (see also corresponding Slice method, which does not
 // signal, like this one does).  Returns error if either index is invalid.
 func (n *Node) MoveChild(frm, to int) error {
-	updt := n.UpdateStart()
+	defer n.lockTree().Unlock()
+	updt := n.updateStartLocked()
 	err := n.Kids.Move(frm, to)
 	if err == nil {
 		n.SetFlag(int(ChildMoved))
+		n.addChange(Change{Type: ChangeChildMoved, Kid: n.Kids[to], From: frm, To: to})
+		undoRecorder(n.This()).record(&undoChildMove{par: n.This(), frm: frm, to: to})
 	}
-	n.UpdateEnd(updt)
+	n.updateEndLocked(updt)
 	return err
 }
 
@@ -1032,12 +1335,16 @@ func (n *Node) MoveChild(frm, to int) error {
 // Slice method which does not signal like this one does).  Returns error if
 // either index is invalid.
 func (n *Node) SwapChildren(i, j int) error {
-	updt := n.UpdateStart()
+	defer n.lockTree().Unlock()
+	updt := n.updateStartLocked()
 	err := n.Kids.Swap(i, j)
 	if err == nil {
 		n.SetFlag(int(ChildMoved))
+		n.addChange(Change{Type: ChangeChildMoved, Kid: n.Kids[j], From: i, To: j})
+		n.addChange(Change{Type: ChangeChildMoved, Kid: n.Kids[i], From: j, To: i})
+		undoRecorder(n.This()).record(&undoChildMove{par: n.This(), frm: i, to: j, swap: true})
 	}
-	n.UpdateEnd(updt)
+	n.updateEndLocked(updt)
 	return err
 }
 
@@ -1056,14 +1363,14 @@ func (n *Node) SwapChildren(i, j int) error {
 // this function consistently to manage children all of the same type.
 func (n *Node) SetNChildren(trgn int, typ reflect.Type, nameStub string) (mods, updt bool) {
 	mods, updt = false, false
-	sz := len(n.Kids)
+	sz := n.NumChildren()
 	if trgn == sz {
 		return
 	}
 	for sz > trgn {
 		if !mods {
 			mods = true
-			updt = n.UpdateStart()
+			updt = n.updateStartLocked()
 		}
 		sz--
 		n.DeleteChildAtIndex(sz, true)
@@ -1071,7 +1378,7 @@ func (n *Node) SetNChildren(trgn int, typ reflect.Type, nameStub string) (mods,
 	for sz < trgn {
 		if !mods {
 			mods = true
-			updt = n.UpdateStart()
+			updt = n.updateStartLocked()
 		}
 		nm := fmt.Sprintf("%v%v", nameStub, sz)
 		n.InsertNewChildFast(typ, sz, nm)
@@ -1104,31 +1411,70 @@ func (n *Node) ConfigChildren(config kit.TypeAndNameList, uniqNm bool) (mods, up
 // SetParent(nil), so to transfer to another list, set new parent first --
 // destroy will add removed child to deleted list, to be destroyed later
 // -- otherwise child remains intact but parent is nil -- could be
-// inserted elsewhere.
+// inserted elsewhere.  If child's parent = this node, it is first sent a
+// NodeSignalDeleteQuery, and any receiver can veto the deletion, in which
+// case an error is returned.
 func (n *Node) DeleteChildAtIndex(idx int, destroy bool) error {
 	child, err := n.ChildTry(idx)
 	if err != nil {
 		return err
 	}
-	updt := n.UpdateStart()
+	return n.deleteChild(child, idx, destroy)
+}
+
+// deleteChild deletes given child, found at index idx, after sending it
+// the NodeSignalDeleteQuery if we are its parent -- the query is sent
+// without holding the tree lock, so the child is looked up again after.
+func (n *Node) deleteChild(child Ki, idx int, destroy bool) error {
+	if child.Parent() == n.This() && deleteVetoed(child) {
+		return fmt.Errorf("ki %v: deletion of child: %v vetoed", n.Nm, child.Name())
+	}
+	defer n.lockTree().Unlock()
+	idx, ok := n.Kids.IndexOf(child, idx)
+	if !ok {
+		return fmt.Errorf("ki %v: child: %v not found", n.Nm, child.Name())
+	}
+	n.deleteChildAtIndexLocked(idx, destroy)
+	return nil
+}
+
+// deleteChildLocked deletes given child under the tree lock, without the
+// NodeSignalDeleteQuery -- used when moving it to a new parent.
+func (n *Node) deleteChildLocked(child Ki, destroy bool) error {
+	idx, ok := n.Kids.IndexOf(child, 0)
+	if !ok {
+		return fmt.Errorf("ki %v: child: %v not found", n.Nm, child.AsNode().pathUniqueLocked())
+	}
+	n.deleteChildAtIndexLocked(idx, destroy)
+	return nil
+}
+
+// deleteChildAtIndexLocked deletes the child at given valid index under
+// the tree lock, sending the NodeSignalDeleting signal after the lock is
+// released.
+func (n *Node) deleteChildAtIndexLocked(idx int, destroy bool) {
+	child := n.Kids[idx]
+	updt := n.updateStartLocked()
 	n.SetFlag(int(ChildDeleted))
-	if child.Parent() == n.This() {
+	wasPar := child.AsNode().Par == n.This()
+	if wasPar {
 		// only deleting if we are still parent -- change parent first to
 		// signal move delete is always sent live to affected node without
 		// update blocking note: children of child etc will not send a signal
 		// at this point -- only later at destroy -- up to this parent to
 		// manage all that
 		child.SetFlag(int(NodeDeleted))
-		child.NodeSignal().Emit(child, int64(NodeSignalDeleting), nil)
-		child.SetParent(nil)
+		n.treeLock().afterUnlock(func() { child.NodeSignal().Emit(child, int64(NodeSignalDeleting), nil) })
+		child.AsNode().setParentLocked(nil)
 	}
 	n.Kids.DeleteAtIndex(idx)
-	if destroy {
+	n.addChange(Change{Type: ChangeChildDeleted, Kid: child, Index: idx})
+	recorded := undoRecorder(n.This()).record(&undoChildDel{par: n.This(), kid: child, idx: idx, wasPar: wasPar, destroy: destroy})
+	if destroy && !recorded { // recorded deletes are destroyed when dropped from undo history
 		DelMgr.Add(child)
 	}
-	child.UpdateReset() // it won't get the UpdateEnd from us anymore -- init fresh in any case
-	n.UpdateEnd(updt)
-	return nil
+	child.AsNode().updateResetLocked() // it won't get the UpdateEnd from us anymore -- init fresh in any case
+	n.updateEndLocked(updt)
 }
 
 // DeleteChild deletes child node, returning error if not found in
@@ -1139,11 +1485,13 @@ func (n *Node) DeleteChild(child Ki, destroy bool) error {
 	if child == nil {
 		return errors.New("ki DeleteChild: child is nil")
 	}
+	tl := n.rlockTree()
 	idx, ok := n.Kids.IndexOf(child, 0)
+	tl.RUnlock()
 	if !ok {
 		return fmt.Errorf("ki %v: child: %v not found", n.Nm, child.PathUnique())
 	}
-	return n.DeleteChildAtIndex(idx, destroy)
+	return n.deleteChild(child, idx, destroy)
 }
 
 // DeleteChildByName deletes child node by name -- returns child, error
@@ -1151,12 +1499,23 @@ func (n *Node) DeleteChild(child Ki, destroy bool) error {
 // SetParent(nil), so to transfer to another list, set new parent first.
 // See DeleteChildAtIndex for destroy info.
 func (n *Node) DeleteChildByName(name string, destroy bool) (Ki, error) {
+	tl := n.rlockTree()
 	idx, ok := n.Kids.IndexByName(name, 0)
+	var child Ki
+	if ok {
+		child = n.Kids[idx]
+	}
+	tl.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("ki %v: child named: %v not found", n.Nm, name)
 	}
-	child := n.Kids[idx]
-	return child, n.DeleteChildAtIndex(idx, destroy)
+	return child, n.deleteChild(child, idx, destroy)
+}
+
+// deleteVetoed sends the NodeSignalDeleteQuery for given node, returning
+// true if any receiver vetoed its deletion.
+func deleteVetoed(k Ki) bool {
+	return !k.NodeSignal().EmitCollect(k, int64(NodeSignalDeleteQuery), nil, &AndCombiner{}).(bool)
 }
 
 // DeleteChildren deletes all children nodes -- destroy will add removed
@@ -1164,35 +1523,55 @@ func (n *Node) DeleteChildByName(name string, destroy bool) (Ki, error) {
 // remain intact but parent is nil -- could be inserted elsewhere, but you
 // better have kept a slice of them before calling this.
 func (n *Node) DeleteChildren(destroy bool) {
-	updt := n.UpdateStart()
+	tl := n.lockTree()
+	defer tl.Unlock()
+	updt := n.updateStartLocked()
 	n.SetFlag(int(ChildrenDeleted))
+	n.addChange(Change{Type: ChangeChildrenDeleted})
 	for _, child := range n.Kids {
 		if child == nil {
 			continue
 		}
 		child.SetFlag(int(NodeDeleted))
-		child.NodeSignal().Emit(child, int64(NodeSignalDeleting), nil)
-		child.SetParent(nil)
-		child.UpdateReset()
+		tl.afterUnlock(func() { child.NodeSignal().Emit(child, int64(NodeSignalDeleting), nil) })
+		child.AsNode().setParentLocked(nil)
+		child.AsNode().updateResetLocked()
 	}
-	if destroy {
+	recorded := false
+	if us := undoRecorder(n.This()); us != nil && len(n.Kids) > 0 {
+		recorded = us.record(&undoChildrenDel{par: n.This(), kids: append(Slice(nil), n.Kids...), destroy: destroy})
+	}
+	if destroy && !recorded {
 		DelMgr.Add(n.Kids...)
 	}
 	n.Kids = n.Kids[:0] // preserves capacity of list
-	n.UpdateEnd(updt)
+	n.updateEndLocked(updt)
 }
 
 // Delete deletes this node from its parent children list -- destroy will
 // add removed child to deleted list, to be destroyed later -- otherwise
 // child remains intact but parent is nil -- could be inserted elsewhere.
+// Any receiver of the NodeSignalDeleteQuery sent first can veto the
+// deletion -- use DeleteTry to find out if it was.
 func (n *Node) Delete(destroy bool) {
-	if n.Par == nil {
-		if destroy {
-			n.This().Destroy()
+	n.DeleteTry(destroy)
+}
+
+// DeleteTry is Delete, returning an error if the deletion was vetoed by a
+// receiver of the NodeSignalDeleteQuery, or the node was not found in the
+// children of its parent.
+func (n *Node) DeleteTry(destroy bool) error {
+	par := n.Parent()
+	if par != nil {
+		return par.DeleteChild(n.This(), destroy)
+	}
+	if destroy {
+		if deleteVetoed(n.This()) {
+			return fmt.Errorf("ki %v: deletion vetoed", n.Nm)
 		}
-	} else {
-		n.Par.DeleteChild(n.This(), destroy)
+		n.This().Destroy()
 	}
+	return nil
 }
 
 // Destroy calls DisconnectAll to cut all pointers and signal connections,
@@ -1210,9 +1589,17 @@ func (n *Node) Destroy() {
 		return true
 	})
 	DelMgr.DestroyDeleted() // then destroy all those kids
+	if atomic.LoadInt32(&idIndexActive) != 0 && n.Parent() == nil {
+		idIndexDestroyed(n.This())
+	}
+	if atomic.LoadInt32(&mailboxActive) != 0 {
+		mailboxDestroyed(n.This())
+	}
+	tl := n.lockTree()
 	n.SetFlag(int(NodeDestroyed))
 	n.Ths = nil // last gasp: lose our own sense of self..
 	// note: above is thread-safe because This() accessor checks Destroyed
+	tl.Unlock()
 }
 
 //////////////////////////////////////////////////////////////////////////
@@ -1329,6 +1716,7 @@ func (n *Node) IsDestroyed() bool {
 // on Trees about special features of each node -- functions below support
 // inheritance up Tree -- see kit convert.go for robust convenience
 // methods for converting interface{} values to standard types.
+// In concurrent mode, only use while holding the TreeLock.
 func (n *Node) Properties() *Props {
 	return &n.Props
 }
@@ -1336,9 +1724,14 @@ func (n *Node) Properties() *Props {
 // SetProp sets given property key to value val.
 // initializes property map if nil.
 func (n *Node) SetProp(key string, val interface{}) {
+	defer n.lockTree().Unlock()
+	if us := undoRecorder(n.This()); us != nil {
+		us.recordProp(n.This(), key)
+	}
 	if n.Props == nil {
 		n.Props = make(Props)
 	}
+	n.addChange(Change{Type: ChangeProp, Key: key, Old: n.Props[key], New: val})
 	n.Props[key] = val
 }
 
@@ -1369,12 +1762,19 @@ func (n *Node) SetSubProps(key string, val Props) {
 // SetProps sets a whole set of properties, and optionally sets the
 // updated flag and triggers an UpdateSig.
 func (n *Node) SetProps(props Props, update bool) {
+	tl := n.lockTree()
 	if n.Props == nil {
 		n.Props = make(Props)
 	}
+	us := undoRecorder(n.This())
 	for key, val := range props {
+		if us != nil {
+			us.recordProp(n.This(), key)
+		}
+		n.addChange(Change{Type: ChangeProp, Key: key, Old: n.Props[key], New: val})
 		n.Props[key] = val
 	}
+	tl.Unlock()
 	if update {
 		n.SetFlag(int(PropUpdated))
 		n.UpdateSig()
@@ -1393,7 +1793,10 @@ func (n *Node) SetPropUpdate(key string, val interface{}) {
 
 // SetPropChildren sets given property key to value val for all Children.
 func (n *Node) SetPropChildren(key string, val interface{}) {
-	for _, k := range n.Kids {
+	tl := n.rlockTree()
+	kids := slices.Clone(n.Kids)
+	tl.RUnlock()
+	for _, k := range kids {
 		k.SetProp(key, val)
 	}
 }
@@ -1403,12 +1806,14 @@ func (n *Node) SetPropChildren(key string, val interface{}) {
 // direct conversion of return.  See PropTry for version with
 // error message if uncertain if property exists.
 func (n *Node) Prop(key string) interface{} {
+	defer n.rlockTree().RUnlock()
 	return n.Props[key]
 }
 
 // PropTry returns property value for key.  Returns error message
 // if property with that key does not exist.
 func (n *Node) PropTry(key string) (interface{}, error) {
+	defer n.rlockTree().RUnlock()
 	v, ok := n.Props[key]
 	if !ok {
 		return v, fmt.Errorf("ki.PropTry, could not find property with key %v on node %v", key, n.Nm)
@@ -1421,6 +1826,7 @@ func (n *Node) PropTry(key string) (interface{}, error) {
 // checks all parents.  If typ then checks property on type as well
 // (registered via KiT type registry).  Returns false if not set anywhere.
 func (n *Node) PropInherit(key string, inherit, typ bool) (interface{}, bool) {
+	defer n.rlockTree().RUnlock()
 	// pr := prof.Start("PropInherit")
 	// defer pr.End()
 	v, ok := n.Props[key]
@@ -1441,9 +1847,17 @@ func (n *Node) PropInherit(key string, inherit, typ bool) (interface{}, bool) {
 
 // DeleteProp deletes property key on this node.
 func (n *Node) DeleteProp(key string) {
+	defer n.lockTree().Unlock()
 	if n.Props == nil {
 		return
 	}
+	if _, has := n.Props[key]; !has {
+		return
+	}
+	if us := undoRecorder(n.This()); us != nil {
+		us.recordProp(n.This(), key)
+	}
+	n.addChange(Change{Type: ChangeDelProp, Key: key, Old: n.Props[key]})
 	delete(n.Props, key)
 }
 
@@ -1452,6 +1866,7 @@ func (n *Node) DeleteProp(key string) {
 // nil instead of making a new one -- most efficient if potentially no
 // properties will be set).
 func (n *Node) DeleteAllProps(cap int) {
+	defer n.lockTree().Unlock()
 	if n.Props != nil {
 		if cap == 0 {
 			n.Props = nil
@@ -1471,6 +1886,7 @@ func init() {
 // DeleteAllProps to do that -- deep copy uses gob encode / decode --
 // usually not needed).
 func (n *Node) CopyPropsFrom(frm Ki, deep bool) error {
+	defer n.lockTree().Unlock()
 	if *(frm.Properties()) == nil {
 		return nil
 	}
@@ -1516,12 +1932,12 @@ func (n *Node) PropTag() string {
 // This is only valid in a given context, not a stable
 // property of the node (e.g., used in FuncDownBreadthFirst).
 func (n *Node) Depth() int {
-	return n.depth
+	return int(atomic.LoadInt64(&n.depth))
 }
 
 // SetDepth sets the current depth of the node to given value.
 func (n *Node) SetDepth(depth int) {
-	n.depth = depth
+	atomic.StoreInt64(&n.depth, int64(depth))
 }
 
 // FlatFieldsValueFunc is the Node version of this function from kit/embeds.go
@@ -1563,10 +1979,9 @@ func (n *Node) FuncFields(level int, data interface{}, fun Func) {
 	if n.This() == nil {
 		return
 	}
-	op := uintptr(unsafe.Pointer(n))
 	foffs := n.KiFieldOffs()
 	for _, fo := range foffs {
-		fn := (*Node)(unsafe.Pointer(op + fo))
+		fn := (*Node)(unsafe.Pointer(uintptr(unsafe.Pointer(n)) + fo))
 		fun(fn.This(), level, data)
 	}
 }
@@ -1577,6 +1992,7 @@ func (n *Node) FuncFields(level int, data interface{}, fun Func) {
 // is incremented after each step (starts at 0, goes up), and passed to
 // function -- returns false if fun aborts with false, else true.
 func (n *Node) FuncUp(level int, data interface{}, fun Func) bool {
+	defer n.rlockTree().RUnlock()
 	cur := n.This()
 	for {
 		if !fun(cur, level, data) { // false return means stop
@@ -1598,6 +2014,7 @@ func (n *Node) FuncUp(level int, data interface{}, fun Func) bool {
 // is incremented after each step (starts at 0, goes up), and passed to
 // function -- returns false if fun aborts with false, else true.
 func (n *Node) FuncUpParent(level int, data interface{}, fun Func) bool {
+	defer n.rlockTree().RUnlock()
 	if n.IsRoot() {
 		return true
 	}
@@ -1662,6 +2079,7 @@ func (tm TravMap) Get(k Ki) (curField, curChild int) {
 // aborted, but other branches continue -- i.e., if fun on current node
 // returns false, children are not processed further.
 func (n *Node) FuncDownMeFirst(level int, data interface{}, fun Func) {
+	defer n.rlockTree().RUnlock()
 	if n.This() == nil {
 		return
 	}
@@ -1748,6 +2166,7 @@ outer:
 // Function calls are sequential all in current go routine.
 // The level var tracks overall depth in the tree.
 func (n *Node) FuncDownMeLast(level int, data interface{}, doChildTestFunc Func, fun Func) {
+	defer n.rlockTree().RUnlock()
 	if n.This() == nil {
 		return
 	}
@@ -1829,36 +2248,42 @@ outer:
 // https://stackoverflow.com/questions/2549541/performing-breadth-first-search-recursively/2549825#2549825
 
 // FuncDownBreadthFirst calls function on all children in breadth-first order
-// using the standard queue strategy.  This depends on and updates the
-// Depth parameter of the node.  If fun returns false then any further
+// using the standard queue strategy.  This updates the Depth parameter of
+// the node, but keeps its own record of the depth, so it is safe for
+// concurrent calling.  If fun returns false then any further
 // traversal of that branch of the tree is aborted, but other branches continue.
 func (n *Node) FuncDownBreadthFirst(level int, data interface{}, fun Func) {
+	defer n.rlockTree().RUnlock()
+	type bfsNode struct {
+		k     Ki
+		depth int
+	}
 	start := n.This()
 
 	start.SetDepth(level)
-	queue := make([]Ki, 1)
-	queue[0] = start
+	queue := make([]bfsNode, 1)
+	queue[0] = bfsNode{start, level}
 
 	for {
 		if len(queue) == 0 {
 			break
 		}
-		cur := queue[0]
-		depth := cur.Depth()
+		cur := queue[0].k
+		depth := queue[0].depth
 		queue = queue[1:]
 
 		if n.This() != nil && fun(cur, depth, data) { // false return means don't proceed
 			if cur.HasKiFields() {
 				cur.FuncFields(depth+1, data, func(k Ki, level int, d interface{}) bool {
 					k.SetDepth(level)
-					queue = append(queue, k)
+					queue = append(queue, bfsNode{k, level})
 					return true
 				})
 			}
 			for _, k := range *cur.Children() {
 				if k != nil && k.This() != nil {
 					k.SetDepth(depth + 1)
-					queue = append(queue, k)
+					queue = append(queue, bfsNode{k, depth + 1})
 				}
 			}
 		}
@@ -1893,15 +2318,31 @@ func (n *Node) NodeSignal() *Signal {
 //   updt := n.UpdateStart()
 //   defer n.UpdateEnd(updt)
 //   ... code
+//
+// In concurrent mode (see NewTreeLock), the write lock of the tree is held
+// from an UpdateStart that returns true until the matching UpdateEnd,
+// which must be called on the same goroutine -- see TreeLock.
 func (n *Node) UpdateStart() bool {
+	tl := n.lockTree()
+	defer tl.Unlock()
+	updt := n.updateStartLocked()
+	if updt && tl != nil && tl.holdBatch() {
+		n.updtLock = tl
+	}
+	return updt
+}
+
+// updateStartLocked is UpdateStart under the tree lock
+func (n *Node) updateStartLocked() bool {
 	if n.IsUpdating() || n.IsDestroyed() {
 		return false
 	}
+	n.changes = nil
 	if n.OnlySelfUpdate() {
 		n.SetFlag(int(Updating))
 	} else {
 		// pr := prof.Start("ki.Node.UpdateStart")
-		n.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		walkDownLocked(n.This(), func(k Ki) bool {
 			if !k.IsUpdating() {
 				k.ClearFlagMask(int64(UpdateFlagsMask))
 				k.SetFlag(int(Updating))
@@ -1911,6 +2352,7 @@ func (n *Node) UpdateStart() bool {
 		})
 		// pr.End()
 	}
+	undoBegin(n.This())
 	return true
 }
 
@@ -1922,24 +2364,30 @@ func (n *Node) UpdateEnd(updt bool) {
 	if !updt {
 		return
 	}
-	if n.IsDestroyed() || n.IsDeleted() {
-		return
+	tl := n.lockTree()
+	btl := n.updtLock
+	n.updtLock = nil
+	n.updateEndLocked(updt)
+	tl.Unlock()
+	if btl != nil {
+		btl.releaseBatch()
 	}
-	if n.HasAnyFlag(int(ChildDeleted), int(ChildrenDeleted)) {
-		DelMgr.DestroyDeleted()
+}
+
+// updateEndLocked is UpdateEnd under the tree lock -- DestroyDeleted is
+// called and the signal sent after the lock is released.
+func (n *Node) updateEndLocked(updt bool) {
+	if !updt {
+		return
 	}
-	if n.OnlySelfUpdate() {
-		n.ClearFlag(int(Updating))
-		n.NodeSignal().Emit(n.This(), int64(NodeSignalUpdated), n.Flags())
-	} else {
-		// pr := prof.Start("ki.Node.UpdateEnd")
-		n.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
-			k.ClearFlag(int(Updating)) // note: could check first and break here but good to ensure all clear
-			return true
-		})
-		// pr.End()
-		n.NodeSignal().Emit(n.This(), int64(NodeSignalUpdated), n.Flags())
+	n.updateEndNoSigLocked()
+	if n.IsDestroyed() || n.IsDeleted() {
+		return
 	}
+	this, data := n.This(), n.updatedData()
+	n.treeLock().afterUnlock(func() {
+		n.NodeSignal().Emit(this, int64(NodeSignalUpdated), data)
+	})
 }
 
 // UpdateEndNoSig is just like UpdateEnd except it does not emit a
@@ -1949,22 +2397,29 @@ func (n *Node) UpdateEndNoSig(updt bool) {
 	if !updt {
 		return
 	}
+	tl := n.lockTree()
+	btl := n.updtLock
+	n.updtLock = nil
+	n.updateEndNoSigLocked()
+	tl.Unlock()
+	if btl != nil {
+		btl.releaseBatch()
+	}
+}
+
+// updateEndNoSigLocked ends the update under the tree lock, without the
+// signal
+func (n *Node) updateEndNoSigLocked() {
+	undoEnd(n.This())
 	if n.IsDestroyed() || n.IsDeleted() {
 		return
 	}
 	if n.HasAnyFlag(int(ChildDeleted), int(ChildrenDeleted)) {
-		DelMgr.DestroyDeleted()
-	}
-	if n.OnlySelfUpdate() {
-		n.ClearFlag(int(Updating))
-		// n.NodeSignal().Emit(n.This(), int64(NodeSignalUpdated), n.Flags())
-	} else {
-		n.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
-			k.ClearFlag(int(Updating)) // note: could check first and break here but good to ensure all clear
-			return true
-		})
-		// n.NodeSignal().Emit(n.This(), int64(NodeSignalUpdated), n.Flags())
+		n.treeLock().afterUnlock(DelMgr.DestroyDeleted)
 	}
+	// pr := prof.Start("ki.Node.UpdateEnd")
+	n.updateResetLocked() // note: could check first and break here but good to ensure all clear
+	// pr.End()
 }
 
 // UpdateSig just emits a NodeSignalUpdated if the Updating flag is not
@@ -1976,7 +2431,10 @@ func (n *Node) UpdateSig() bool {
 	if n.IsUpdating() || n.IsDestroyed() {
 		return false
 	}
-	n.NodeSignal().Emit(n.This(), int64(NodeSignalUpdated), n.Flags())
+	tl := n.lockTree()
+	n.changes = nil
+	tl.Unlock()
+	n.NodeSignal().Emit(n.This(), int64(NodeSignalUpdated), UpdatedData{Flags: n.Flags()})
 	return true
 }
 
@@ -1984,10 +2442,16 @@ func (n *Node) UpdateSig() bool {
 // case they are out-of-sync due to more complex tree maninpulations --
 // only call at a known point of non-updating.
 func (n *Node) UpdateReset() {
+	defer n.rlockTree().RUnlock()
+	n.updateResetLocked()
+}
+
+// updateResetLocked is UpdateReset under the tree lock
+func (n *Node) updateResetLocked() {
 	if n.OnlySelfUpdate() {
 		n.ClearFlag(int(Updating))
 	} else {
-		n.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		walkDownLocked(n.This(), func(k Ki) bool {
 			k.ClearFlag(int(Updating))
 			return true
 		})
@@ -2018,23 +2482,29 @@ func (n *Node) DisconnectAll() {
 // vice-versa, automatically.  Returns error if not successfully set.
 // wrapped in UpdateStart / End and sets the FieldUpdated flag.
 func (n *Node) SetField(field string, val interface{}) error {
+	defer n.lockTree().Unlock()
 	fv := kit.FlatFieldValueByName(n.This(), field)
 	if !fv.IsValid() {
 		return fmt.Errorf("ki.SetField, could not find field %v on node %v", field, n.Nm)
 	}
-	updt := n.UpdateStart()
+	updt := n.updateStartLocked()
 	var err error
 	if field == "Nm" {
-		n.SetName(kit.ToString(val))
+		n.setNameLocked(kit.ToString(val))
 		n.SetFlag(int(FieldUpdated))
+		n.addChange(Change{Type: ChangeField, Name: field})
 	} else {
+		if us := undoRecorder(n.This()); us != nil {
+			us.recordField(n.This(), field, fv)
+		}
 		if kit.SetRobust(kit.PtrValue(fv).Interface(), val) {
 			n.SetFlag(int(FieldUpdated))
+			n.addChange(Change{Type: ChangeField, Name: field})
 		} else {
 			err = fmt.Errorf("ki.SetField, SetRobust failed to set field %v on node %v to value: %v", field, n.Nm, val)
 		}
 	}
-	n.UpdateEnd(updt)
+	n.updateEndLocked(updt)
 	return err
 }
 
@@ -2042,10 +2512,14 @@ func (n *Node) SetField(field string, val interface{}) error {
 // tree from me -- wrapped in UpdateStart / End.
 func (n *Node) SetFieldDown(field string, val interface{}) {
 	updt := n.UpdateStart()
+	var ks []Ki // the tree cannot be modified within FuncDown in concurrent mode
 	n.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
-		k.SetField(field, val)
+		ks = append(ks, k)
 		return true
 	})
+	for _, k := range ks {
+		k.SetField(field, val)
+	}
 	n.UpdateEnd(updt)
 }
 
@@ -2053,10 +2527,14 @@ func (n *Node) SetFieldDown(field string, val interface{}) {
 // tree from me -- wrapped in UpdateStart / End.
 func (n *Node) SetFieldUp(field string, val interface{}) {
 	updt := n.UpdateStart()
+	var ks []Ki
 	n.FuncUp(0, nil, func(k Ki, level int, d interface{}) bool {
-		k.SetField(field, val)
+		ks = append(ks, k)
 		return true
 	})
+	for _, k := range ks {
+		k.SetField(field, val)
+	}
 	n.UpdateEnd(updt)
 }
 
@@ -2094,9 +2572,11 @@ func (n *Node) FieldTag(field, tag string) string {
 // nodes in the destination if they have the same name and type -- so a
 // copy from a source to a target that only differ minimally will be
 // minimally destructive.  Only copies to same types are supported.
-// Signal connections are NOT copied.  No other Ki pointers are copied,
-// and the field tag copy:"-" can be added for any other fields that
-// should not be copied (unexported, lower-case fields are not copyable).
+// Signal connections are NOT copied.  Ptr fields pointing within the
+// source tree are remapped to the corresponding nodes in the copy.  No
+// other Ki pointers are copied, and the field tag copy:"-" can be added
+// for any other fields that should not be copied (unexported, lower-case
+// fields are not copyable).
 func (n *Node) CopyFrom(frm Ki) error {
 	if frm == nil {
 		err := fmt.Errorf("ki.Node CopyFrom into %v -- null 'from' source", n.PathUnique())
@@ -2112,6 +2592,7 @@ func (n *Node) CopyFrom(frm Ki) error {
 	defer n.UpdateEnd(updt)
 	n.SetFlag(int(NodeCopied))
 	err := n.CopyFromRaw(frm)
+	n.copyPtrsFrom(frm)
 	return err
 }
 
@@ -2203,9 +2684,6 @@ func GenCopyFieldsFrom(to interface{}, frm interface{}) {
 
 // see https://github.com/goki/ki/wiki/Naming for IO naming conventions
 
-// TODO: switch to using Decode / Encode instead of
-// [Un]MarshalJSON which uses byte[] instead of io.Reader / Writer..
-
 // JSONTypePrefix is the first thing output in a ki tree JSON output file,
 // specifying the type of the root node of the ki tree -- this info appears
 // all on one { } bracketed line at the start of the file, and can also be
@@ -2215,30 +2693,23 @@ var JSONTypePrefix = []byte("{\"ki.RootType\": ")
 // JSONTypeSuffix is just the } and \n at the end of the prefix line
 var JSONTypeSuffix = []byte("}\n")
 
-// WriteJSON writes the tree to an io.Writer, using MarshalJSON -- also
-// saves a critical starting record that allows file to be loaded de-novo
-// and recreate the proper root type for the tree.
+// WriteJSON writes the tree to an io.Writer, streaming it node by node in
+// the same format as MarshalJSON -- also saves a critical starting record
+// that allows file to be loaded de-novo and recreate the proper root type
+// for the tree.
 func (n *Node) WriteJSON(writer io.Writer, indent bool) error {
 	err := n.ThisCheck()
 	if err != nil {
 		return err
 	}
-	var b []byte
-	if indent {
-		b, err = json.MarshalIndent(n.This(), "", "  ")
-	} else {
-		b, err = json.Marshal(n.This())
-	}
-	if err != nil {
-		log.Println(err)
-		return err
+	defer n.rlockTree().RUnlock()
+	bw := bufio.NewWriter(writer)
+	bw.Write(jsonHeader(n.Type()))
+	je := jsonEncoder{w: bw, indent: indent}
+	err = je.encodeNode(n.This(), "")
+	if err == nil {
+		err = bw.Flush()
 	}
-	knm := kit.Types.TypeName(n.Type())
-	tstr := string(JSONTypePrefix) + fmt.Sprintf("\"%v\"}\n", knm)
-	nwb := make([]byte, len(b)+len(tstr))
-	copy(nwb, []byte(tstr))
-	copy(nwb[len(tstr):], b) // is there a way to avoid this?
-	_, err = writer.Write(nwb)
 	if err != nil {
 		log.Println(err)
 		return err
@@ -2265,32 +2736,43 @@ func (n *Node) SaveJSON(filename string) error {
 // JSON-encoded byte stream via io.Reader.  First element in the stream
 // must be of same type as this node -- see ReadNewJSON function to
 // construct a new tree.  Uses ConfigureChildren to minimize changes from
-// current tree relative to loading one -- wraps UnmarshalJSON and calls
-// UnmarshalPost to recover pointers from paths.
+// current tree relative to loading one -- streams the tree node by node
+// in the UnmarshalJSON format, and calls UnmarshalPost to recover pointers
+// from paths.  Nodes saved with an older version of their type are upgraded
+// by the migrations registered with kit.Types.AddMigration, which are logged
+// -- see ReadJSONMigrated to get the list of migrations instead.
 func (n *Node) ReadJSON(reader io.Reader) error {
+	migs, err := n.ReadJSONMigrated(reader)
+	logMigrated("ki.ReadJSON", migs)
+	return err
+}
+
+// ReadJSONMigrated reads the tree as in ReadJSON, returning the migrations
+// applied to nodes saved with an older version of their type, which are
+// not logged.
+func (n *Node) ReadJSONMigrated(reader io.Reader) ([]Migrated, error) {
 	err := n.ThisCheck()
 	if err != nil {
 		log.Println(err)
-		return err
+		return nil, err
 	}
-	b, err := ioutil.ReadAll(reader)
-	if err != nil {
+	br := bufio.NewReader(reader)
+	jd := newJSONDecoder(br)
+	if _, err = jd.readRootType(br); err != nil { // skip type
 		log.Println(err)
-		return err
+		return nil, err
 	}
-	updt := n.UpdateStart()
-	stidx := 0
-	if bytes.HasPrefix(b, JSONTypePrefix) { // skip type
-		stidx = bytes.Index(b, JSONTypeSuffix) + len(JSONTypeSuffix)
+	if us := UndoStackFor(n.This()); us != nil {
+		defer us.Reset()
 	}
-	// todo: use json.NewDecoder, Decode instead -- need to deal with TypePrefix etc above
-	err = json.Unmarshal(b[stidx:], n.This()) // key use of this!
+	updt := n.UpdateStart()
+	err = jd.decodeNode(n.This(), "") // key use of this!
 	if err == nil {
-		n.UnmarshalPost()
+		err = n.unmarshalPost()
 	}
 	n.SetFlag(int(ChildAdded)) // this might not be set..
 	n.UpdateEnd(updt)
-	return err
+	return jd.migrated, err
 }
 
 // OpenJSON opens file over this tree from a JSON-encoded file -- see
@@ -2307,34 +2789,42 @@ func (n *Node) OpenJSON(filename string) error {
 
 // ReadNewJSON reads a new Ki tree from a JSON-encoded byte string, using type
 // information at start of file to create an object of the proper type
+// -- nodes saved with older versions of their type are migrated, as in ReadJSON.
 func ReadNewJSON(reader io.Reader) (Ki, error) {
-	b, err := ioutil.ReadAll(reader)
+	root, migs, err := ReadNewJSONMigrated(reader)
+	logMigrated("ki.ReadNewJSON", migs)
+	return root, err
+}
+
+// ReadNewJSONMigrated reads a new Ki tree as in ReadNewJSON, also returning
+// the migrations applied to nodes saved with an older version of their
+// type, which are not logged.
+func ReadNewJSONMigrated(reader io.Reader) (Ki, []Migrated, error) {
+	br := bufio.NewReader(reader)
+	jd := newJSONDecoder(br)
+	tn, err := jd.readRootType(br)
 	if err != nil {
 		log.Println(err)
-		return nil, err
+		return nil, nil, err
 	}
-	if bytes.HasPrefix(b, JSONTypePrefix) {
-		stidx := len(JSONTypePrefix) + 1
-		eidx := bytes.Index(b, JSONTypeSuffix)
-		bodyidx := eidx + len(JSONTypeSuffix)
-		tn := string(bytes.Trim(bytes.TrimSpace(b[stidx:eidx]), "\""))
-		typ := kit.Types.Type(tn)
-		if typ == nil {
-			return nil, fmt.Errorf("ki.OpenNewJSON: kit.Types type name not found: %v", tn)
-		}
-		root := NewOfType(typ)
-		root.Init(root)
+	if tn == "" {
+		return nil, nil, fmt.Errorf("ki.OpenNewJSON -- type prefix not found at start of file -- must be there to identify type of root node of tree")
+	}
+	typ := kit.Types.Type(tn)
+	if typ == nil {
+		return nil, nil, fmt.Errorf("ki.OpenNewJSON: kit.Types type name not found: %v", tn)
+	}
+	root := NewOfType(typ)
+	root.Init(root)
 
-		updt := root.UpdateStart()
-		err = json.Unmarshal(b[bodyidx:], root)
-		if err == nil {
-			root.UnmarshalPost()
-		}
-		root.SetFlag(int(ChildAdded)) // this might not be set..
-		root.UpdateEnd(updt)
-		return root, nil
+	updt := root.UpdateStart()
+	err = jd.decodeNode(root, "")
+	if err == nil {
+		err = root.AsNode().unmarshalPost()
 	}
-	return nil, fmt.Errorf("ki.OpenNewJSON -- type prefix not found at start of file -- must be there to identify type of root node of tree")
+	root.SetFlag(int(ChildAdded)) // this might not be set..
+	root.UpdateEnd(updt)
+	return root, jd.migrated, err
 }
 
 // OpenNewJSON opens a new Ki tree from a JSON-encoded file, using type
@@ -2350,13 +2840,23 @@ func OpenNewJSON(filename string) (Ki, error) {
 }
 
 // WriteXML writes the tree to an XML-encoded byte string over io.Writer
-// using MarshalXML.
+// using MarshalXML, after a ki processing instruction recording the root
+// type and type versions -- saves all fields, Props (with their types) and
+// children, so ReadXML restores the same tree as ReadJSON.
 func (n *Node) WriteXML(writer io.Writer, indent bool) error {
 	err := n.ThisCheck()
 	if err != nil {
 		log.Println(err)
 		return err
 	}
+	hdr := xmlHeader(n.Type())
+	if indent {
+		hdr = append(hdr, '\n')
+	}
+	if _, err = writer.Write(hdr); err != nil {
+		log.Println(err)
+		return err
+	}
 	var b []byte
 	if indent {
 		b, err = xml.MarshalIndent(n.This(), "", "  ")
@@ -2376,30 +2876,56 @@ func (n *Node) WriteXML(writer io.Writer, indent bool) error {
 }
 
 // ReadXML reads the tree from an XML-encoded byte string over io.Reader, calls
-// UnmarshalPost to recover pointers from paths.
+// UnmarshalPost to recover pointers from paths.  If the XML has the ki
+// header, nodes saved with an older version of their type are upgraded by
+// the migrations registered with kit.Types.AddMigration before the tree is
+// loaded, and are logged -- see ReadXMLMigrated to get the list of
+// migrations instead.
 func (n *Node) ReadXML(reader io.Reader) error {
+	migs, err := n.ReadXMLMigrated(reader)
+	logMigrated("ki.ReadXML", migs)
+	return err
+}
+
+// ReadXMLMigrated reads the tree as in ReadXML, returning the migrations
+// applied to nodes saved with an older version of their type, which are
+// not logged.  The tree is not changed if a migration fails.
+func (n *Node) ReadXMLMigrated(reader io.Reader) ([]Migrated, error) {
 	var err error
 	if err = n.ThisCheck(); err != nil {
 		log.Println(err)
-		return err
+		return nil, err
 	}
 	b, err := ioutil.ReadAll(reader)
 	if err != nil {
 		log.Println(err)
-		return err
+		return nil, err
+	}
+	var migs []Migrated
+	if rt, vers := readXMLHeader(b); rt != "" {
+		b, migs, err = migrateXML(b, n.Type(), vers)
+		if err != nil {
+			err = fmt.Errorf("ki.ReadXML: %v", err)
+			log.Println(err)
+			return nil, err
+		}
+	}
+	if us := UndoStackFor(n.This()); us != nil {
+		defer us.Reset()
 	}
 	updt := n.UpdateStart()
 	err = xml.Unmarshal(b, n.This()) // key use of this!
 	if err == nil {
-		n.UnmarshalPost()
+		err = n.unmarshalPost()
 	}
 	n.SetFlag(int(ChildAdded)) // this might not be set..
 	n.UpdateEnd(updt)
-	return nil
+	return migs, err
 }
 
 // ParentAllChildren walks the tree down from current node and call
-// SetParent on all children -- needed after an Unmarshal.
+// SetParent on all children, including those of Ki fields -- needed after
+// an Unmarshal.
 func (n *Node) ParentAllChildren() {
 	for _, child := range *n.Children() {
 		if child != nil {
@@ -2407,12 +2933,37 @@ func (n *Node) ParentAllChildren() {
 			child.ParentAllChildren()
 		}
 	}
+	n.FuncFields(0, nil, func(k Ki, level int, d interface{}) bool {
+		k.ParentAllChildren()
+		return Continue
+	})
 }
 
 // UnmarshalPost must be called after an Unmarshal -- calls
-// ParentAllChildren.
+// ParentAllChildren and SetPtrsFromPaths, adds any new nodes to the
+// TreeLock in concurrent mode, rebuilds the IDIndex if any, and
+// re-establishes the signal connections in Links (ConnectLinks), logging
+// any that could not be connected -- the Read* methods and functions
+// return that error instead, with the rest of the tree loaded.
 func (n *Node) UnmarshalPost() {
+	if err := n.unmarshalPost(); err != nil {
+		log.Println(err)
+	}
+}
+
+// unmarshalPost is UnmarshalPost, returning the error from ConnectLinks
+// instead of logging it -- used by the Read* methods, which return it
+// after loading the rest of the tree.
+func (n *Node) unmarshalPost() error {
 	n.ParentAllChildren()
+	if tl := n.treeLock(); tl != nil {
+		setTreeLockDown(n.This(), tl)
+	}
+	if ix := IDIndexFor(n.This()); ix != nil {
+		ix.Rebuild()
+	}
+	n.SetPtrsFromPaths()
+	return n.ConnectLinks()
 }
 
 // Deleted manages all the deleted Ki elements, that are destined to then be
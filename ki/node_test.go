@@ -208,6 +208,83 @@ func TestNodeFindType(t *testing.T) {
 	}
 }
 
+func TestNodeFindPaths(t *testing.T) {
+	root := NodeField{}
+	root.InitName(&root, "root")
+	c1 := root.AddNewChild(KiT_NodeEmbed, "child1")
+	c2 := root.AddNewChild(KiT_NodeField, "child2")
+	root.AddNewChild(KiT_NodeEmbed, "other")
+	s1 := c1.AddNewChild(KiT_NodeEmbed, "sub1")
+	s2 := c2.AddNewChild(KiT_NodeEmbed, "sub2")
+	ss := s2.AddNewChild(KiT_NodeEmbed, "sub3")
+
+	names := func(ks []Ki) string {
+		nms := make([]string, len(ks))
+		for i, k := range ks {
+			nms[i] = k.Name()
+		}
+		return strings.Join(nms, ",")
+	}
+	tests := []struct {
+		start Ki
+		path  string
+		want  string
+	}{
+		{root.This(), "/root/child1/sub1", "sub1"},
+		{root.This(), "child2/./sub2", "sub2"},
+		{s1, "../../child2/sub2", "sub2"},
+		{s1, "..", "child1"},
+		{root.This(), "*", "child1,child2,other"},
+		{root.This(), "child*", "child1,child2"},
+		{root.This(), "child?/sub*", "sub1,sub2"},
+		{root.This(), "[1]/[0]", "sub2"},
+		{root.This(), "[co]*", "child1,child2,other"},
+		{root.This(), "**/sub*", "sub1,sub2,sub3"},
+		{root.This(), "child2/**", "child2,sub2,sub3"},
+		{root.This(), "**/sub3/..", "sub2"},
+		{root.This(), "*.Field1", "Field1"},
+		{root.This(), ".Field*", "Field1"},
+		{root.This(), "child2.Field1/..", "child2"},
+		{root.This(), "**/nosuch", ""},
+		{ss, "/root/child2/sub2/sub3", "sub3"},
+	}
+	for _, ts := range tests {
+		got := names(ts.start.FindPaths(ts.path))
+		if got != ts.want {
+			t.Errorf("FindPaths %v from %v: expected: %v got: %v", ts.path, ts.start.Name(), ts.want, got)
+		}
+	}
+	if k := root.FindPathUnique("**/sub*"); k != s1 {
+		t.Errorf("FindPathUnique should return first match, got: %v", k)
+	}
+	if k := root.FindPathUnique("**/nosuch"); k != nil {
+		t.Errorf("FindPathUnique should return nil, got: %v", k)
+	}
+}
+
+func TestNodeFindPathsLiteral(t *testing.T) {
+	root := Node{}
+	root.InitName(&root, "root")
+	ab := root.AddNewChild(nil, "a[b]")
+	root.AddNewChild(nil, "ab")
+	xs := root.AddNewChild(nil, "x*")
+	root.AddNewChild(nil, "xy")
+	sub := ab.AddNewChild(nil, "sub")
+
+	if k := root.FindPathUnique("/root/a[b]"); k != ab {
+		t.Errorf("FindPathUnique should find literal name a[b], got: %v", k)
+	}
+	if k := root.FindPathUnique("/root/a[b]/sub"); k != sub {
+		t.Errorf("FindPathUnique should find sub below literal name a[b], got: %v", k)
+	}
+	if ks := root.FindPaths("/root/x*"); len(ks) != 1 || ks[0] != xs {
+		t.Errorf("FindPaths should match only literal name x*, got: %v", ks)
+	}
+	if ks := root.FindPaths("/root/x?"); len(ks) != 2 {
+		t.Errorf("FindPaths should match x? as a glob, got: %v", ks)
+	}
+}
+
 func TestNodeMove(t *testing.T) {
 	parent := NodeEmbed{}
 	parent.InitName(&parent, "par1")
@@ -0,0 +1,235 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// FuncDownParallel calls function on this node and all the nodes below it,
+// including Ki fields, spreading the subtrees over a pool of the given
+// number of worker goroutines (0 = runtime.GOMAXPROCS).  The function is
+// always called on a node before any of its children, but otherwise in no
+// particular order.  If fun returns SkipChildren, the children of that
+// node are not processed.  The first other non-nil error returned by fun
+// stops the traversal and is returned, as is the error of ctx if it is
+// cancelled.  Returns once all function calls are done.  The function
+// must be safe for concurrent use, and must not modify the tree structure.
+func (n *Node) FuncDownParallel(ctx context.Context, workers, level int, data interface{}, fun FuncErr) error {
+	pt := newParTrav(ctx, workers, data, fun, false)
+	return pt.run(n.This(), level)
+}
+
+// FuncDownMeLastParallel calls function on all the nodes below this node,
+// including Ki fields, and then on this node, spreading the subtrees over
+// a pool of the given number of worker goroutines (0 =
+// runtime.GOMAXPROCS).  The function is only called on a node after it
+// has returned for all of its children (and fields), but otherwise in no
+// particular order.  The first non-nil error returned by fun stops the
+// traversal and is returned (SkipChildren is ignored), as is the error of
+// ctx if it is cancelled.  Returns once all function calls are done.  The
+// function must be safe for concurrent use, and must not modify the tree
+// structure.
+func (n *Node) FuncDownMeLastParallel(ctx context.Context, workers, level int, data interface{}, fun FuncErr) error {
+	pt := newParTrav(ctx, workers, data, fun, true)
+	return pt.run(n.This(), level)
+}
+
+// parNode is a node being processed by a parallel traversal
+type parNode struct {
+	k       Ki
+	level   int
+	par     *parNode // MeLast: parent to finish after all its items
+	pending int32    // MeLast: number of items not yet finished
+}
+
+// parTrav manages a parallel traversal: nodes are processed depth-first
+// within each worker, which hands off pending nodes to the shared queue
+// whenever there are idle workers.
+type parTrav struct {
+	ctx     context.Context
+	workers int
+	data    interface{}
+	fun     FuncErr
+	meLast  bool
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*parNode
+	idle   int32 // number of workers waiting for the queue
+	closed bool
+
+	wg      sync.WaitGroup // counts nodes not yet processed
+	stopped int32
+	errOnce sync.Once
+	err     error
+}
+
+func newParTrav(ctx context.Context, workers int, data interface{}, fun FuncErr, meLast bool) *parTrav {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	pt := &parTrav{ctx: ctx, workers: workers, data: data, fun: fun, meLast: meLast}
+	pt.cond = sync.NewCond(&pt.mu)
+	return pt
+}
+
+// run runs the traversal from given root
+func (pt *parTrav) run(root Ki, level int) error {
+	if root == nil {
+		return nil
+	}
+	if err := pt.ctx.Err(); err != nil {
+		return err
+	}
+	pt.wg.Add(1)
+	pt.queue = append(pt.queue, &parNode{k: root, level: level})
+	var workers sync.WaitGroup
+	for i := 0; i < pt.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			pt.worker()
+		}()
+	}
+	pt.wg.Wait()
+	pt.mu.Lock()
+	pt.closed = true
+	pt.cond.Broadcast()
+	pt.mu.Unlock()
+	workers.Wait()
+	return pt.err
+}
+
+// stop stops the traversal with given error, if it is the first one
+func (pt *parTrav) stop(err error) {
+	pt.errOnce.Do(func() {
+		pt.err = err
+		atomic.StoreInt32(&pt.stopped, 1)
+	})
+}
+
+// worker processes nodes from the shared queue until closed
+func (pt *parTrav) worker() {
+	var stack []*parNode
+	for {
+		pt.mu.Lock()
+		for len(pt.queue) == 0 && !pt.closed {
+			atomic.AddInt32(&pt.idle, 1)
+			pt.cond.Wait()
+			atomic.AddInt32(&pt.idle, -1)
+		}
+		if pt.closed {
+			pt.mu.Unlock()
+			return
+		}
+		stack = append(stack, pt.queue[len(pt.queue)-1])
+		pt.queue = pt.queue[:len(pt.queue)-1]
+		pt.mu.Unlock()
+
+		for len(stack) > 0 {
+			if len(stack) > 1 && atomic.LoadInt32(&pt.idle) > 0 {
+				stack = pt.share(stack)
+			}
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			stack = pt.process(p, stack)
+			pt.wg.Done()
+		}
+	}
+}
+
+// share moves the bottom half of stack, which are the nodes nearest the
+// root, with the largest subtrees, to the shared queue
+func (pt *parTrav) share(stack []*parNode) []*parNode {
+	nsh := len(stack) / 2
+	pt.mu.Lock()
+	pt.queue = append(pt.queue, stack[:nsh]...)
+	pt.cond.Broadcast()
+	pt.mu.Unlock()
+	return append(stack[:0], stack[nsh:]...)
+}
+
+// process processes given node, pushing any of its items to be processed
+// onto stack
+func (pt *parTrav) process(p *parNode, stack []*parNode) []*parNode {
+	if atomic.LoadInt32(&pt.stopped) != 0 {
+		return stack
+	}
+	if err := pt.ctx.Err(); err != nil {
+		pt.stop(err)
+		return stack
+	}
+	if !pt.meLast {
+		err := pt.fun(p.k, p.level, pt.data)
+		if err == SkipChildren {
+			return stack
+		}
+		if err != nil {
+			pt.stop(err)
+			return stack
+		}
+	}
+	items := parItems(p.k)
+	if len(items) == 0 {
+		if pt.meLast {
+			pt.finish(p)
+		}
+		return stack
+	}
+	if pt.meLast {
+		atomic.StoreInt32(&p.pending, int32(len(items)))
+	}
+	pt.wg.Add(len(items))
+	for i := len(items) - 1; i >= 0; i-- { // first item on top of stack
+		stack = append(stack, &parNode{k: items[i], level: p.level + 1, par: p})
+	}
+	return stack
+}
+
+// finish calls the function on given node, for MeLast, and then on any
+// parents that have no other pending items
+func (pt *parTrav) finish(p *parNode) {
+	for ; p != nil; p = p.par {
+		if atomic.LoadInt32(&pt.stopped) != 0 {
+			return
+		}
+		if err := pt.ctx.Err(); err != nil {
+			pt.stop(err)
+			return
+		}
+		if err := pt.fun(p.k, p.level, pt.data); err != nil && err != SkipChildren {
+			pt.stop(err)
+			return
+		}
+		if p.par == nil || atomic.AddInt32(&p.par.pending, -1) != 0 {
+			return
+		}
+	}
+}
+
+// parItems returns the Ki fields and then the children of given node
+func parItems(k Ki) []Ki {
+	nf := k.NumKiFields()
+	nc := k.NumChildren()
+	if nf+nc == 0 {
+		return nil
+	}
+	items := make([]Ki, 0, nf+nc)
+	for i := 0; i < nf; i++ {
+		if fk := k.KiField(i); fk != nil {
+			items = append(items, fk)
+		}
+	}
+	for i := 0; i < nc; i++ {
+		if kid, err := k.ChildTry(i); err == nil && kid != nil && kid.This() != nil {
+			items = append(items, kid.This())
+		}
+	}
+	return items
+}
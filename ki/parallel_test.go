@@ -0,0 +1,126 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func parTestTree() Ki {
+	root := NodeField{}
+	root.InitName(&root, "root")
+	for i := 0; i < 10; i++ {
+		c := root.AddNewChild(KiT_NodeField, fmt.Sprintf("c%d", i))
+		for j := 0; j < 10; j++ {
+			gc := c.AddNewChild(KiT_NodeEmbed, fmt.Sprintf("gc%d", j))
+			for l := 0; l < j; l++ {
+				gc.AddNewChild(KiT_NodeEmbed, fmt.Sprintf("ggc%d", l))
+			}
+		}
+	}
+	return root.This()
+}
+
+func TestFuncDownParallel(t *testing.T) {
+	root := parTestTree()
+	nnodes := 0
+	root.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		nnodes++
+		return Continue
+	})
+
+	for _, workers := range []int{1, 4, 0} {
+		var order sync.Map
+		var ctr int64
+		err := root.FuncDownParallel(context.Background(), workers, 0, nil, func(k Ki, level int, d interface{}) error {
+			if level != k.ParentLevel(root)+1 && k != root {
+				t.Errorf("wrong level %v for %v", level, k.PathUnique())
+			}
+			order.Store(k, atomic.AddInt64(&ctr, 1))
+			return nil
+		})
+		if err != nil || int(ctr) != nnodes {
+			t.Errorf("workers %v: called on %v of %v nodes: %v", workers, ctr, nnodes, err)
+		}
+		order.Range(func(key, val interface{}) bool {
+			if par := key.(Ki).Parent(); par != nil {
+				if po, _ := order.Load(par); po.(int64) > val.(int64) {
+					t.Errorf("%v called before its parent", key.(Ki).PathUnique())
+				}
+			}
+			return true
+		})
+
+		var done sync.Map
+		ctr = 0
+		err = root.FuncDownMeLastParallel(context.Background(), workers, 0, nil, func(k Ki, level int, d interface{}) error {
+			for _, it := range parItems(k) {
+				if _, ok := done.Load(it); !ok {
+					t.Errorf("%v called before %v", k.PathUnique(), it.PathUnique())
+				}
+			}
+			done.Store(k, true)
+			atomic.AddInt64(&ctr, 1)
+			return nil
+		})
+		if err != nil || int(ctr) != nnodes {
+			t.Errorf("workers %v: MeLast called on %v of %v nodes: %v", workers, ctr, nnodes, err)
+		}
+	}
+
+	var ctr int64
+	root.FuncDownParallel(context.Background(), 4, 0, nil, func(k Ki, level int, d interface{}) error {
+		atomic.AddInt64(&ctr, 1)
+		if level == 1 && !k.IsField() {
+			return SkipChildren
+		}
+		return nil
+	})
+	if ctr != 12 { // root, Field1, and 10 children
+		t.Errorf("SkipChildren: called on %v nodes", ctr)
+	}
+}
+
+func TestFuncDownParallelStop(t *testing.T) {
+	root := parTestTree()
+	stop := errors.New("stop")
+	for _, meLast := range []bool{false, true} {
+		trav := root.FuncDownParallel
+		if meLast {
+			trav = root.FuncDownMeLastParallel
+		}
+		var ctr int64
+		err := trav(context.Background(), 4, 0, nil, func(k Ki, level int, d interface{}) error {
+			atomic.AddInt64(&ctr, 1)
+			if k.Name() == "ggc3" {
+				return stop
+			}
+			return nil
+		})
+		if err != stop {
+			t.Errorf("meLast %v: expected stop error, got: %v", meLast, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ctr = 0
+		err = trav(ctx, 4, 0, nil, func(k Ki, level int, d interface{}) error {
+			if atomic.AddInt64(&ctr, 1) == 10 {
+				cancel()
+			}
+			return nil
+		})
+		if err != context.Canceled || ctr > 10+4 {
+			t.Errorf("meLast %v: expected cancel after 10 calls, got %v after %v", meLast, err, ctr)
+		}
+		if err := trav(ctx, 4, 0, nil, nil); err != context.Canceled {
+			t.Errorf("expected error for cancelled context: %v", err)
+		}
+	}
+}
@@ -7,9 +7,11 @@ package ki
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"log"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/goki/ki/kit"
@@ -74,6 +76,8 @@ var enumTypeKey = "__enum:"
 // BlankProp is an empty property, for when there isn't any need for the value
 type BlankProp struct{}
 
+var KiT_BlankProp = kit.Types.AddType(&BlankProp{}, nil)
+
 // PropStruct is a struct of Name and Value, for use in a PropSlice to hold
 // properties that require order information (maps do not retain any order)
 type PropStruct struct {
@@ -86,6 +90,8 @@ type PropStruct struct {
 // property to a PropSlice to create an ordered list of property values.
 type PropSlice []PropStruct
 
+var KiT_PropSlice = kit.Types.AddType(&PropSlice{}, nil)
+
 // ElemLabel satisfies the gi.SliceLabeler interface to provide labels for slice elements
 func (ps *PropSlice) ElemLabel(idx int) string {
 	return (*ps)[idx].Name
@@ -197,11 +203,20 @@ func (p Props) MarshalJSON() ([]byte, error) {
 		return b, nil
 	}
 	b = append(b, []byte("{")...)
+	keys := make([]string, 0, nk)
+	for key := range p {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // deterministic output
 	cnt := 0
 	var err error
-	for key, val := range p {
+	for _, key := range keys {
+		val := p[key]
 		vt := kit.NonPtrType(reflect.TypeOf(val))
-		vk := vt.Kind()
+		vk := reflect.Invalid // nil value
+		if vt != nil {
+			vk = vt.Kind()
+		}
 		if vk == reflect.Struct {
 			knm := kit.Types.TypeName(vt)
 			tstr := fmt.Sprintf("\"%v%v\": \"%v\",", struTypeKey, key, knm)
@@ -333,3 +348,233 @@ func (p *Props) UnmarshalJSON(b []byte) error {
 
 	return nil
 }
+
+// XML encoding of Props: each property is a Prop element with name and type
+// attributes, so the UnmarshalXML can restore the actual types: basic types
+// use their Go names (e.g., float64), enums and other registered types their
+// kit.Types name, with enums saved as their string names.  Props, PropSlice,
+// map[string]interface{} (type map) and []interface{} (type list) contain
+// nested Prop elements (without names for lists), and nil values have type
+// nil.  Any other values are saved in their JSON encoding, with type json.
+
+// MarshalXML saves each property as a Prop element, with name and type
+// attributes -- empty Props are not saved at all.
+func (p Props) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(p) == 0 {
+		return nil
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := xmlEncodeProps(e, p); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML restores properties from Prop elements, with their types
+func (p *Props) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	ps, err := xmlDecodeProps(d)
+	if err != nil {
+		return err
+	}
+	*p = make(Props, len(ps))
+	for _, pv := range ps {
+		(*p)[pv.Name] = pv.Value
+	}
+	return nil
+}
+
+// xmlEncodeProps encodes given props map as Prop elements, in sorted key order
+func xmlEncodeProps(e *xml.Encoder, p map[string]interface{}) error {
+	keys := make([]string, 0, len(p))
+	for key := range p {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // deterministic output
+	for _, key := range keys {
+		if err := xmlEncodeProp(e, key, true, p[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xmlEncodeProp encodes one property value as a Prop element, with given name
+// if named (else it is a list element)
+func xmlEncodeProp(e *xml.Encoder, name string, named bool, v interface{}) error {
+	st := xml.StartElement{Name: xml.Name{Local: "Prop"}}
+	if named {
+		st.Attr = append(st.Attr, xml.Attr{Name: xml.Name{Local: "name"}, Value: name})
+	}
+	typeAttr := func(tn string) {
+		st.Attr = append(st.Attr, xml.Attr{Name: xml.Name{Local: "type"}, Value: tn})
+	}
+	var err error
+	switch pv := v.(type) {
+	case nil:
+		typeAttr("nil")
+		if err = e.EncodeToken(st); err == nil {
+			err = e.EncodeToken(st.End())
+		}
+		return err
+	case Props, map[string]interface{}, PropSlice, []interface{}:
+		switch pv.(type) {
+		case Props:
+			typeAttr(kit.Types.TypeName(KiT_Props))
+		case map[string]interface{}:
+			typeAttr("map")
+		case PropSlice:
+			typeAttr(kit.Types.TypeName(KiT_PropSlice))
+		default:
+			typeAttr("list")
+		}
+		if err = e.EncodeToken(st); err != nil {
+			return err
+		}
+		switch pv := pv.(type) {
+		case Props:
+			err = xmlEncodeProps(e, pv)
+		case map[string]interface{}:
+			err = xmlEncodeProps(e, pv)
+		case PropSlice:
+			for _, ps := range pv {
+				if err = xmlEncodeProp(e, ps.Name, true, ps.Value); err != nil {
+					break
+				}
+			}
+		case []interface{}:
+			for _, ev := range pv {
+				if err = xmlEncodeProp(e, "", false, ev); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return e.EncodeToken(st.End())
+	}
+	typ := reflect.TypeOf(v)
+	vt := kit.NonPtrType(typ)
+	switch {
+	case binIsEnum(typ):
+		typeAttr(kit.Types.TypeName(typ))
+		return e.EncodeElement(kit.EnumIfaceToString(v), st)
+	case binBasicTypes[typ.Kind()] == typ:
+		typeAttr(typ.Name())
+		return e.EncodeElement(v, st)
+	case vt.Kind() == reflect.Struct && kit.Types.Type(kit.Types.TypeName(vt)) == vt:
+		typeAttr(kit.Types.TypeName(vt))
+		return e.EncodeElement(v, st)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	typeAttr("json")
+	return e.EncodeElement(string(b), st)
+}
+
+// xmlDecodeProps decodes the Prop elements up to the end of the current
+// element, in order
+func xmlDecodeProps(d *xml.Decoder) (PropSlice, error) {
+	ps := PropSlice{}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tv := t.(type) {
+		case xml.StartElement:
+			if tv.Name.Local != "Prop" {
+				return nil, fmt.Errorf("ki.Props UnmarshalXML: expected Prop element, got: %v", tv.Name.Local)
+			}
+			nm, pv, err := xmlDecodeProp(d, tv)
+			if err != nil {
+				return nil, err
+			}
+			ps = append(ps, PropStruct{Name: nm, Value: pv})
+		case xml.EndElement:
+			return ps, nil
+		}
+	}
+}
+
+// xmlDecodeProp decodes the value of given Prop element, returning its name
+func xmlDecodeProp(d *xml.Decoder, st xml.StartElement) (string, interface{}, error) {
+	nm, tn := "", "string"
+	for _, a := range st.Attr {
+		switch a.Name.Local {
+		case "name":
+			nm = a.Value
+		case "type":
+			tn = a.Value
+		}
+	}
+	switch tn {
+	case "nil":
+		return nm, nil, d.Skip()
+	case "map", "list", kit.Types.TypeName(KiT_Props), kit.Types.TypeName(KiT_PropSlice):
+		ps, err := xmlDecodeProps(d)
+		if err != nil {
+			return nm, nil, err
+		}
+		switch tn {
+		case "map", kit.Types.TypeName(KiT_Props):
+			p := make(Props, len(ps))
+			for _, pv := range ps {
+				p[pv.Name] = pv.Value
+			}
+			if tn == "map" {
+				return nm, map[string]interface{}(p), nil
+			}
+			return nm, p, nil
+		case "list":
+			l := make([]interface{}, len(ps))
+			for i, pv := range ps {
+				l[i] = pv.Value
+			}
+			return nm, l, nil
+		}
+		return nm, ps, nil
+	case "json":
+		var str string
+		var pv interface{}
+		err := d.DecodeElement(&str, &st)
+		if err == nil {
+			err = json.Unmarshal([]byte(str), &pv)
+		}
+		if err != nil {
+			return nm, nil, fmt.Errorf("ki.Props UnmarshalXML: prop %v: %v", nm, err)
+		}
+		return nm, pv, nil
+	}
+	var typ reflect.Type
+	for _, bt := range binBasicTypes {
+		if bt.Name() == tn {
+			typ = bt
+			break
+		}
+	}
+	if typ == nil {
+		typ = binTypeByName(tn)
+	}
+	if typ == nil {
+		return nm, nil, fmt.Errorf("ki.Props UnmarshalXML: prop %v: kit.Types type name not found: %v", nm, tn)
+	}
+	pv := reflect.New(typ)
+	var err error
+	if binIsEnum(typ) {
+		var str string
+		if err = d.DecodeElement(&str, &st); err == nil {
+			err = enumFromString(pv, str)
+		}
+	} else {
+		err = d.DecodeElement(pv.Interface(), &st)
+	}
+	if err != nil {
+		return nm, nil, fmt.Errorf("ki.Props UnmarshalXML: prop %v: %v", nm, err)
+	}
+	return nm, pv.Elem().Interface(), nil
+}
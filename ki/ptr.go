@@ -0,0 +1,181 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/goki/ki/kit"
+)
+
+// Ptr is a pointer to another Ki node, typically within the same tree,
+// which is saved and loaded using its PathUnique -- use as a field in a
+// Node type (directly or in an embedded struct).  After loading (in
+// UnmarshalPost) the pointer is restored by finding the path within the
+// tree.  When copying or cloning, pointers to nodes within the copied
+// subtree point to the corresponding nodes in the copy.
+type Ptr struct {
+	Ptr  Ki     `json:"-" xml:"-" desc:"the pointer to the node"`
+	Path string `desc:"unique path to the node, used for saving and restoring the pointer"`
+}
+
+var KiT_Ptr = kit.Types.AddType(&Ptr{}, nil)
+
+// NewPtr returns a new Ptr to given node
+func NewPtr(k Ki) Ptr {
+	p := Ptr{Ptr: k}
+	p.UpdatePath()
+	return p
+}
+
+// String returns the path of the pointer
+func (p Ptr) String() string {
+	return p.path()
+}
+
+// path returns the current path of the node, or the saved Path if nil
+func (p *Ptr) path() string {
+	if p.Ptr == nil || p.Ptr.This() == nil {
+		return p.Path
+	}
+	return p.Ptr.PathUnique()
+}
+
+// Reset sets the pointer to nil and the path to empty
+func (p *Ptr) Reset() {
+	p.Ptr = nil
+	p.Path = ""
+}
+
+// UpdatePath updates the Path from the current Ptr, e.g., after the node
+// has moved within the tree
+func (p *Ptr) UpdatePath() {
+	p.Path = p.path()
+}
+
+// PtrFromPath sets the Ptr by finding the Path within the tree under given
+// root -- returns false and sets Ptr to nil if not found.
+func (p *Ptr) PtrFromPath(root Ki) bool {
+	p.Ptr = nil
+	if p.Path == "" || root == nil {
+		return false
+	}
+	p.Ptr = root.FindPathUnique(p.Path)
+	return p.Ptr != nil
+}
+
+// MarshalJSON saves the path of the pointer as a JSON string
+func (p Ptr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.path())
+}
+
+// UnmarshalJSON loads the path of the pointer -- Ptr is set later by
+// UnmarshalPost
+func (p *Ptr) UnmarshalJSON(b []byte) error {
+	p.Ptr = nil
+	return json.Unmarshal(b, &p.Path)
+}
+
+// MarshalXML saves the path of the pointer as the element text
+func (p Ptr) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(p.path(), start)
+}
+
+// UnmarshalXML loads the path of the pointer -- Ptr is set later by
+// UnmarshalPost
+func (p *Ptr) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	p.Ptr = nil
+	return d.DecodeElement(&p.Path, &start)
+}
+
+// MarshalBinary saves the path of the pointer, for WriteBinary
+func (p Ptr) MarshalBinary() ([]byte, error) {
+	return []byte(p.path()), nil
+}
+
+// UnmarshalBinary loads the path of the pointer, for ReadBinary
+func (p *Ptr) UnmarshalBinary(b []byte) error {
+	p.Ptr = nil
+	p.Path = string(b)
+	return nil
+}
+
+// ptrFieldIdxs caches the field indexes of the Ptr fields of each type
+var ptrFieldIdxs sync.Map
+
+// ptrFields returns the Ptr fields of given node, including those in
+// embedded structs
+func ptrFields(k Ki) []*Ptr {
+	v := reflect.ValueOf(k).Elem()
+	var idxs [][]int
+	if ci, ok := ptrFieldIdxs.Load(v.Type()); ok {
+		idxs = ci.([][]int)
+	} else {
+		idxs = appendPtrFieldIdxs(nil, v.Type(), nil)
+		ptrFieldIdxs.Store(v.Type(), idxs)
+	}
+	if len(idxs) == 0 {
+		return nil
+	}
+	pfs := make([]*Ptr, len(idxs))
+	for i, idx := range idxs {
+		pfs[i] = v.FieldByIndex(idx).Addr().Interface().(*Ptr)
+	}
+	return pfs
+}
+
+// appendPtrFieldIdxs appends the field indexes of the Ptr fields of given
+// struct type, prefixed by index of the struct itself
+func appendPtrFieldIdxs(idxs [][]int, typ reflect.Type, pfx []int) [][]int {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		switch {
+		case f.Type == KiT_Ptr && f.PkgPath == "":
+			idxs = append(idxs, append(append([]int{}, pfx...), i))
+		case f.Anonymous && f.Type.Kind() == reflect.Struct && f.Type != KiT_Node:
+			idxs = appendPtrFieldIdxs(idxs, f.Type, append(append([]int{}, pfx...), i))
+		}
+	}
+	return idxs
+}
+
+// SetPtrsFromPaths sets all the Ptr fields in the tree from this node down
+// from their paths, which are found from the root of the tree -- this is
+// called automatically in UnmarshalPost.
+func (n *Node) SetPtrsFromPaths() {
+	root := n.This().Root()
+	n.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		for _, p := range ptrFields(k) {
+			p.PtrFromPath(root)
+		}
+		return Continue
+	})
+}
+
+// copyPtrsFrom updates the Ptr fields in the tree from this node down
+// after copying from given node: pointers to nodes within the frm tree
+// are set to the corresponding nodes within our tree, and all paths are
+// updated.
+func (n *Node) copyPtrsFrom(frm Ki) {
+	frmPath := frm.PathUnique()
+	myPath := n.PathUnique()
+	n.FuncDownMeFirst(0, nil, func(k Ki, level int, d interface{}) bool {
+		for _, p := range ptrFields(k) {
+			if p.Ptr == nil || p.Ptr.This() == nil {
+				continue
+			}
+			if p.Ptr == frm || p.Ptr.HasParent(frm) {
+				rel := strings.TrimPrefix(p.Ptr.PathUnique(), frmPath)
+				p.Ptr = n.This().FindPathUnique(myPath + rel)
+			}
+			p.UpdatePath()
+		}
+		return Continue
+	})
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/goki/ki/kit"
+)
+
+type NodePtr struct {
+	NodeEmbed
+	Ref Ptr
+}
+
+var KiT_NodePtr = kit.Types.AddType(&NodePtr{}, nil)
+
+func ptrTestTree() (Ki, *NodePtr) {
+	root := NodePtr{}
+	root.InitName(&root, "root")
+	sub := root.AddNewChild(KiT_NodePtr, "sub").(*NodePtr)
+	tgt := sub.AddNewChild(KiT_NodeEmbed, "tgt")
+	out := root.AddNewChild(KiT_NodeEmbed, "out")
+	ref := sub.AddNewChild(KiT_NodePtr, "ref").(*NodePtr)
+	ref.Ref = NewPtr(tgt)
+	sub.Ref = NewPtr(out)
+	root.Ref = NewPtr(ref)
+	return root.This(), sub
+}
+
+func TestPtrSaveLoad(t *testing.T) {
+	root, _ := ptrTestTree()
+	check := func(fmt string, nr Ki) {
+		t.Helper()
+		nref := nr.FindPathUnique("/root/sub/ref").(*NodePtr)
+		if nref.Ref.Ptr != nr.FindPathUnique("/root/sub/tgt") {
+			t.Errorf("%v: Ptr not restored: %v", fmt, nref.Ref)
+		}
+		if nr.(*NodePtr).Ref.Ptr != nref {
+			t.Errorf("%v: root Ptr not restored: %v", fmt, nr.(*NodePtr).Ref)
+		}
+		if nr.Child(0).(*NodePtr).Ref.Ptr != nr.ChildByName("out", 0) {
+			t.Errorf("%v: sub Ptr not restored: %v", fmt, nr.Child(0).(*NodePtr).Ref)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := root.WriteJSON(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"Ref": "/root/sub/tgt"`)) {
+		t.Errorf("JSON does not have path:\n%v", buf.String())
+	}
+	nr, err := ReadNewJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("JSON", nr)
+
+	buf.Reset()
+	if err := root.WriteXML(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`<Ref>/root/sub/tgt</Ref>`)) {
+		t.Errorf("XML does not have path:\n%v", buf.String())
+	}
+	xr := NodePtr{}
+	xr.InitName(&xr, "")
+	if err := xr.ReadXML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	check("XML", xr.This())
+
+	buf.Reset()
+	if err := root.WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	br, err := ReadNewBinary(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("binary", br)
+
+	buf.Reset()
+	if err := root.WriteYAML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	yr := NodePtr{}
+	yr.InitName(&yr, "")
+	if err := yr.ReadYAML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	check("YAML", yr.This())
+}
+
+func TestPtrClone(t *testing.T) {
+	root, sub := ptrTestTree()
+	cl := sub.Clone().(*NodePtr)
+	cl.SetName("sub2")
+	root.AddChild(cl)
+	cref := cl.ChildByName("ref", 0).(*NodePtr)
+	if cref.Ref.Ptr != cl.ChildByName("tgt", 0) {
+		t.Errorf("Ptr within clone not remapped: %v", cref.Ref.Ptr.PathUnique())
+	}
+	if cref.Ref.String() != "/root/sub2/tgt" {
+		t.Errorf("Ptr path not updated: %v", cref.Ref)
+	}
+	if cl.Ref.Ptr != root.ChildByName("out", 0) {
+		t.Errorf("Ptr outside clone should be kept: %v", cl.Ref)
+	}
+	if sub.ChildByName("ref", 0).(*NodePtr).Ref.Ptr != sub.ChildByName("tgt", 0) {
+		t.Errorf("Ptr in original should be unchanged")
+	}
+
+	// CopyFrom onto existing nodes remaps the same way
+	sub3 := root.AddNewChild(KiT_NodePtr, "sub3").(*NodePtr)
+	sub3.CopyFrom(sub)
+	if sub3.ChildByName("ref", 0).(*NodePtr).Ref.Ptr != sub3.ChildByName("tgt", 0) {
+		t.Errorf("Ptr within CopyFrom not remapped")
+	}
+}
@@ -0,0 +1,618 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/goki/ki/kit"
+)
+
+// Selector is a compiled CSS-style selector that matches nodes in a Ki
+// tree -- create with CompileSelector, and reuse it across any number of
+// trees.  A selector is a comma-separated list of alternatives, each of
+// which is a sequence of compound selectors separated by combinators:
+//
+//	A B     descendant: B is anywhere within A
+//	A > B   child: B is a child of A
+//
+// A compound selector is an optional type name (or * for any type) followed
+// by any number of the following, all of which must match:
+//
+//	#name          Name() is name
+//	.class         the "class" property contains class, in a space-separated list
+//	[attr]         attr is a field of the node or one of its properties
+//	[attr=val]     field or property value (as a string) equals val -- other
+//	               operators are: != (not equal), ^= (starts with), $= (ends
+//	               with), *= (contains), ~= (space-separated list contains),
+//	               |= (equals or starts with val-) -- val may be quoted
+//	:first         first child of its parent (also :first-child)
+//	:last          last child of its parent (also :last-child)
+//	:nth-child(an+b)  1-based child index matches an+b, also odd, even
+//	:nth-last-child(an+b)  same, counting from the end
+//
+// Type names are resolved via kit.Types, using the name with or without the
+// package qualifier (e.g., NodeEmbed or ki\.NodeEmbed -- the . must be
+// escaped), and match nodes of that type or any type that embeds it (see
+// TypeEmbeds).  Attribute names are looked up first as exported fields
+// (including Nm, UniqueNm), and then as (non-inherited) properties.
+type Selector struct {
+	Src  string       `desc:"source string that the selector was compiled from"`
+	alts []selComplex // alternatives, separated by , in Src
+}
+
+// selCombs are the combinators between compound selectors
+type selCombs int
+
+const (
+	selDescendant selCombs = iota
+	selChild
+)
+
+// selComplex is a sequence of compound selectors -- the last one is the
+// subject that the nodes matched by the selector must match
+type selComplex []selStep
+
+// selStep is one compound selector, with the combinator relating it to the
+// step before it
+type selStep struct {
+	comb  selCombs
+	types []reflect.Type // nil = any type
+	names []string
+	class []string
+	attrs []selAttr
+	nths  []selNth
+}
+
+// selAttr is an attribute predicate
+type selAttr struct {
+	name string
+	op   string // empty = exists
+	val  string
+}
+
+// selNth is an :nth-child(an+b) predicate
+type selNth struct {
+	a, b    int
+	fromEnd bool
+}
+
+// CompileSelector compiles the given CSS-style selector -- see Selector for
+// the syntax.
+func CompileSelector(sel string) (*Selector, error) {
+	sp := &selParser{src: sel}
+	alts, err := sp.parse()
+	if err != nil {
+		return nil, fmt.Errorf("ki.CompileSelector: %v in selector: %q", err, sel)
+	}
+	return &Selector{Src: sel, alts: alts}, nil
+}
+
+// MustCompileSelector compiles the given selector, and panics if it is
+// not valid -- for use in initializing global variables.
+func MustCompileSelector(sel string) *Selector {
+	s, err := CompileSelector(sel)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the source of the selector
+func (s *Selector) String() string {
+	return s.Src
+}
+
+// Match returns true if the node matches the selector, where all of the
+// node's ancestors can be used to match combinators.
+func (s *Selector) Match(k Ki) bool {
+	return s.match(k, nil)
+}
+
+// QueryOne returns the first node within the subtree below given root (in
+// depth-first order, not including the root itself) that matches the
+// selector, or nil if none.  Combinators are only matched against the
+// root and the nodes within it.  Ki fields are not included.
+func (s *Selector) QueryOne(root Ki) Ki {
+	var res Ki
+	s.query(root, root, func(k Ki) bool {
+		res = k
+		return false
+	})
+	return res
+}
+
+// QueryAll returns all of the nodes within the subtree below given root (in
+// depth-first order, not including the root itself) that match the
+// selector.  Combinators are only matched against the root and the nodes
+// within it.  Ki fields are not included.
+func (s *Selector) QueryAll(root Ki) []Ki {
+	var res []Ki
+	s.query(root, root, func(k Ki) bool {
+		res = append(res, k)
+		return true
+	})
+	return res
+}
+
+// query calls fun on each matching node below k, stopping if fun returns
+// false -- returns false if stopped
+func (s *Selector) query(k, scope Ki, fun func(k Ki) bool) bool {
+	for _, kid := range *k.Children() {
+		if kid == nil || kid.This() == nil {
+			continue
+		}
+		if s.match(kid, scope) && !fun(kid) {
+			return false
+		}
+		if !s.query(kid, scope, fun) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Selector) match(k, scope Ki) bool {
+	for _, sc := range s.alts {
+		if sc.matchAt(k, len(sc)-1, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// selParent returns the parent of k, limited to scope
+func selParent(k, scope Ki) Ki {
+	if k == scope {
+		return nil
+	}
+	return k.Parent()
+}
+
+func (sc selComplex) matchAt(k Ki, i int, scope Ki) bool {
+	st := &sc[i]
+	if !st.match(k) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	if st.comb == selChild {
+		par := selParent(k, scope)
+		return par != nil && sc.matchAt(par, i-1, scope)
+	}
+	for par := selParent(k, scope); par != nil; par = selParent(par, scope) {
+		if sc.matchAt(par, i-1, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns true if the node matches the compound selector
+func (st *selStep) match(k Ki) bool {
+	if st.types != nil {
+		got := false
+		for _, typ := range st.types {
+			if k.TypeEmbeds(typ) {
+				got = true
+				break
+			}
+		}
+		if !got {
+			return false
+		}
+	}
+	for _, nm := range st.names {
+		if k.Name() != nm {
+			return false
+		}
+	}
+	if len(st.class) > 0 {
+		cls := strings.Fields(kit.ToString(k.Prop("class")))
+		for _, c := range st.class {
+			if !selHasWord(cls, c) {
+				return false
+			}
+		}
+	}
+	for _, at := range st.attrs {
+		if !at.match(k) {
+			return false
+		}
+	}
+	if len(st.nths) > 0 {
+		idx, n := 0, 1
+		if par := k.Parent(); par != nil && !k.IsField() {
+			if i, ok := k.IndexInParent(); ok {
+				idx, n = i, par.NumChildren()
+			}
+		}
+		for _, nth := range st.nths {
+			if !nth.match(idx, n) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func selHasWord(words []string, w string) bool {
+	for _, wd := range words {
+		if wd == w {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns true if the node matches the attribute predicate
+func (at *selAttr) match(k Ki) bool {
+	var val string
+	if fv := kit.FlatFieldValueByName(k, at.name); fv.IsValid() && fv.CanInterface() {
+		val = kit.ToString(fv.Interface())
+	} else if pv, ok := (*k.Properties())[at.name]; ok {
+		val = kit.ToString(pv)
+	} else {
+		return false
+	}
+	switch at.op {
+	case "":
+		return true
+	case "=":
+		return val == at.val
+	case "!=":
+		return val != at.val
+	case "^=":
+		return strings.HasPrefix(val, at.val)
+	case "$=":
+		return strings.HasSuffix(val, at.val)
+	case "*=":
+		return strings.Contains(val, at.val)
+	case "~=":
+		return selHasWord(strings.Fields(val), at.val)
+	case "|=":
+		return val == at.val || strings.HasPrefix(val, at.val+"-")
+	}
+	return false
+}
+
+// match returns true if the 0-based index within n children matches
+func (nth *selNth) match(idx, n int) bool {
+	pos := idx + 1
+	if nth.fromEnd {
+		pos = n - idx
+	}
+	if nth.a == 0 {
+		return pos == nth.b
+	}
+	d := pos - nth.b
+	return d%nth.a == 0 && d/nth.a >= 0
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  Parsing
+
+// selParser parses selector source
+type selParser struct {
+	src string
+	pos int
+}
+
+func (sp *selParser) eof() bool {
+	return sp.pos >= len(sp.src)
+}
+
+func (sp *selParser) peek() byte {
+	if sp.eof() {
+		return 0
+	}
+	return sp.src[sp.pos]
+}
+
+func (sp *selParser) skipSpace() bool {
+	st := sp.pos
+	for !sp.eof() && unicode.IsSpace(rune(sp.src[sp.pos])) {
+		sp.pos++
+	}
+	return sp.pos > st
+}
+
+func (sp *selParser) parse() ([]selComplex, error) {
+	var alts []selComplex
+	for {
+		sp.skipSpace()
+		sc, err := sp.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, sc)
+		if sp.eof() {
+			return alts, nil
+		}
+		sp.pos++ // ,
+	}
+}
+
+// parseComplex parses compound selectors and combinators up to , or end
+func (sp *selParser) parseComplex() (selComplex, error) {
+	var sc selComplex
+	comb := selDescendant
+	for {
+		st, err := sp.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		st.comb = comb
+		sc = append(sc, st)
+		sp.skipSpace()
+		switch c := sp.peek(); c {
+		case 0, ',':
+			return sc, nil
+		case '>':
+			sp.pos++
+			sp.skipSpace()
+			comb = selChild
+		default:
+			comb = selDescendant
+		}
+	}
+}
+
+// parseCompound parses one compound selector
+func (sp *selParser) parseCompound() (selStep, error) {
+	var st selStep
+	start := sp.pos
+	switch c := sp.peek(); {
+	case c == '*':
+		sp.pos++
+	case selIdentByte(c) || c == '\\':
+		tnm := sp.parseIdent()
+		st.types = selTypes(tnm)
+		if st.types == nil {
+			return st, fmt.Errorf("type: %v not found in kit.Types", tnm)
+		}
+	}
+	for !sp.eof() {
+		switch c := sp.peek(); c {
+		case '#', '.':
+			sp.pos++
+			id := sp.parseIdent()
+			if id == "" {
+				return st, fmt.Errorf("missing name after %c at: %v", c, sp.pos)
+			}
+			if c == '#' {
+				st.names = append(st.names, id)
+			} else {
+				st.class = append(st.class, id)
+			}
+		case '[':
+			at, err := sp.parseAttr()
+			if err != nil {
+				return st, err
+			}
+			st.attrs = append(st.attrs, at)
+		case ':':
+			nth, err := sp.parsePseudo()
+			if err != nil {
+				return st, err
+			}
+			st.nths = append(st.nths, nth)
+		default:
+			if sp.pos == start {
+				return st, fmt.Errorf("unexpected %q at: %v", c, sp.pos)
+			}
+			return st, nil
+		}
+	}
+	if sp.pos == start {
+		return st, fmt.Errorf("empty selector")
+	}
+	return st, nil
+}
+
+func selIdentByte(c byte) bool {
+	return c == '_' || c == '-' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// parseIdent parses an identifier, with \ escapes
+func (sp *selParser) parseIdent() string {
+	var sb strings.Builder
+	for !sp.eof() {
+		c := sp.peek()
+		if c == '\\' && sp.pos+1 < len(sp.src) {
+			sb.WriteByte(sp.src[sp.pos+1])
+			sp.pos += 2
+			continue
+		}
+		if !selIdentByte(c) {
+			break
+		}
+		sb.WriteByte(c)
+		sp.pos++
+	}
+	return sb.String()
+}
+
+// parseAttr parses an [attr op val] predicate
+func (sp *selParser) parseAttr() (selAttr, error) {
+	var at selAttr
+	sp.pos++ // [
+	sp.skipSpace()
+	at.name = sp.parseIdent()
+	if at.name == "" {
+		return at, fmt.Errorf("missing attribute name at: %v", sp.pos)
+	}
+	sp.skipSpace()
+	if sp.peek() == ']' {
+		sp.pos++
+		return at, nil
+	}
+	for _, op := range []string{"=", "!=", "^=", "$=", "*=", "~=", "|="} {
+		if strings.HasPrefix(sp.src[sp.pos:], op) {
+			at.op = op
+			sp.pos += len(op)
+			break
+		}
+	}
+	if at.op == "" {
+		return at, fmt.Errorf("invalid attribute operator at: %v", sp.pos)
+	}
+	sp.skipSpace()
+	if q := sp.peek(); q == '"' || q == '\'' {
+		end := strings.IndexByte(sp.src[sp.pos+1:], q)
+		if end < 0 {
+			return at, fmt.Errorf("unterminated string at: %v", sp.pos)
+		}
+		at.val = sp.src[sp.pos+1 : sp.pos+1+end]
+		sp.pos += end + 2
+	} else {
+		at.val = sp.parseIdent()
+	}
+	sp.skipSpace()
+	if sp.peek() != ']' {
+		return at, fmt.Errorf("missing ] at: %v", sp.pos)
+	}
+	sp.pos++
+	return at, nil
+}
+
+// parsePseudo parses a :pseudo-class
+func (sp *selParser) parsePseudo() (selNth, error) {
+	var nth selNth
+	sp.pos++ // :
+	nm := sp.parseIdent()
+	switch nm {
+	case "first", "first-child":
+		nth.b = 1
+		return nth, nil
+	case "last", "last-child":
+		nth.b = 1
+		nth.fromEnd = true
+		return nth, nil
+	case "nth-child", "nth-last-child":
+		nth.fromEnd = nm == "nth-last-child"
+	default:
+		return nth, fmt.Errorf("unsupported pseudo-class: %v", nm)
+	}
+	if sp.peek() != '(' {
+		return nth, fmt.Errorf("missing ( after %v", nm)
+	}
+	end := strings.IndexByte(sp.src[sp.pos:], ')')
+	if end < 0 {
+		return nth, fmt.Errorf("missing ) after %v", nm)
+	}
+	arg := sp.src[sp.pos+1 : sp.pos+end]
+	sp.pos += end + 1
+	var err error
+	nth.a, nth.b, err = selParseNth(arg)
+	return nth, err
+}
+
+// selParseNth parses an an+b expression
+func selParseNth(arg string) (a, b int, err error) {
+	arg = strings.ToLower(strings.Replace(arg, " ", "", -1))
+	switch arg {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+	ni := strings.IndexByte(arg, 'n')
+	if ni < 0 {
+		b, err = strconv.Atoi(arg)
+		if err != nil {
+			err = fmt.Errorf("invalid nth-child argument: %v", arg)
+		}
+		return
+	}
+	switch as := arg[:ni]; as {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		a, err = strconv.Atoi(as)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid nth-child argument: %v", arg)
+		}
+	}
+	if bs := arg[ni+1:]; bs != "" {
+		b, err = strconv.Atoi(bs)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid nth-child argument: %v", arg)
+		}
+	}
+	return
+}
+
+// selTypes returns the registered types with given name, with or
+// without the package qualifier, sorted by name
+func selTypes(nm string) []reflect.Type {
+	kit.TypesMu.RLock()
+	defer kit.TypesMu.RUnlock()
+	var tnms []string
+	for tn := range kit.Types.Types {
+		if tn == nm || strings.HasSuffix(tn, "."+nm) {
+			tnms = append(tnms, tn)
+		}
+	}
+	if len(tnms) == 0 {
+		return nil
+	}
+	sort.Strings(tnms)
+	typs := make([]reflect.Type, len(tnms))
+	for i, tn := range tnms {
+		typs[i] = kit.Types.Types[tn]
+	}
+	return typs
+}
+
+// selCacheMax is the maximum number of compiled selectors kept in the
+// selCache -- the least recently used are evicted beyond that, so that
+// selectors built from arbitrary input do not accumulate.
+const selCacheMax = 256
+
+// selCache caches compiled selectors for the Ki QueryOne, QueryAll methods,
+// in order of use, most recent first
+var selCache = struct {
+	sync.Mutex
+	m   map[string]*list.Element
+	lru list.List
+}{m: make(map[string]*list.Element)}
+
+// cachedSelector returns the compiled selector for given source, from
+// the cache if available.
+func cachedSelector(sel string) (*Selector, error) {
+	selCache.Lock()
+	if e, ok := selCache.m[sel]; ok {
+		selCache.lru.MoveToFront(e)
+		selCache.Unlock()
+		return e.Value.(*Selector), nil
+	}
+	selCache.Unlock()
+	s, err := CompileSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	selCache.Lock()
+	if _, ok := selCache.m[sel]; !ok {
+		selCache.m[sel] = selCache.lru.PushFront(s)
+		if selCache.lru.Len() > selCacheMax {
+			old := selCache.lru.Back()
+			selCache.lru.Remove(old)
+			delete(selCache.m, old.Value.(*Selector).Src)
+		}
+	}
+	selCache.Unlock()
+	return s, nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func selTestTree() Ki {
+	root := NodeField{}
+	root.InitName(&root, "root")
+	c1 := root.AddNewChild(KiT_NodeEmbed, "child1").(*NodeEmbed)
+	c1.SetProp("class", "primary big")
+	c2 := root.AddNewChild(KiT_NodeEmbed, "child2").(*NodeEmbed)
+	c2.Mbr1 = "okay"
+	c3 := root.AddNewChild(KiT_NodeField, "child3")
+	for i, nm := range []string{"ok1", "sub2", "ok3", "sub4"} {
+		sk := c3.AddNewChild(KiT_NodeEmbed, nm).(*NodeEmbed)
+		sk.Mbr2 = i
+		if i%2 == 0 {
+			sk.SetProp("class", "primary")
+		}
+	}
+	c3.Child(1).AddNewChild(KiT_NodeField2, "deep")
+	return root.This()
+}
+
+func selNames(ks []Ki) string {
+	nms := make([]string, len(ks))
+	for i, k := range ks {
+		nms[i] = k.Name()
+	}
+	return strings.Join(nms, ",")
+}
+
+func TestSelector(t *testing.T) {
+	root := selTestTree()
+	tests := []struct {
+		sel  string
+		want string
+	}{
+		{"*", "child1,child2,child3,ok1,sub2,deep,ok3,sub4"},
+		{"NodeField", "child3,deep"},
+		{"ki\\.NodeField2", "deep"},
+		{"NodeEmbed", "child1,child2,child3,ok1,sub2,deep,ok3,sub4"},
+		{"#child2", "child2"},
+		{".primary", "child1,ok1,ok3"},
+		{".primary.big", "child1"},
+		{"NodeField > NodeEmbed.primary[Nm^=ok]", "ok1,ok3"},
+		{"NodeField NodeField", "child3,deep"},
+		{"#child3 NodeField", "deep"},
+		{"#root > NodeField", "child3"},
+		{"#child3 NodeField2", "deep"},
+		{"#child3 > NodeField2", ""},
+		{"[Mbr1=okay]", "child2"},
+		{"[Mbr1*='ka']", "child2"},
+		{"[Mbr2!=0]", "sub2,ok3,sub4"},
+		{"[class~=big]", "child1"},
+		{"[class]", "child1,ok1,ok3"},
+		{"#child3 > :first", "ok1"},
+		{"#child3 > :last", "sub4"},
+		{"#child3 > :nth-child(2n)", "sub2,sub4"},
+		{"#child3 > :nth-child(odd)", "ok1,ok3"},
+		{"#child3 > :nth-child(-n+2)", "ok1,sub2"},
+		{"#child3 > :nth-last-child(2)", "ok3"},
+		{"#child1, #child2", "child1,child2"},
+	}
+	for _, ts := range tests {
+		got := selNames(root.QueryAll(ts.sel))
+		if got != ts.want {
+			t.Errorf("selector %q: expected: %v got: %v", ts.sel, ts.want, got)
+		}
+	}
+	if one := root.QueryOne("NodeField"); one == nil || one.Name() != "child3" {
+		t.Errorf("QueryOne failed: %v", one)
+	}
+	if one := root.QueryOne("#nosuch"); one != nil {
+		t.Errorf("QueryOne expected nil, got: %v", one)
+	}
+}
+
+func TestSelectorCompile(t *testing.T) {
+	sel := MustCompileSelector("NodeEmbed.primary")
+	t1 := selTestTree()
+	t2 := selTestTree()
+	if len(sel.QueryAll(t1)) != 3 || len(sel.QueryAll(t2)) != 3 {
+		t.Errorf("compiled selector should apply to any tree")
+	}
+	if !sel.Match(t1.Child(0)) || sel.Match(t1.Child(1)) {
+		t.Errorf("Match failed")
+	}
+	for _, bad := range []string{"", "NoSuchType", "#", "[Nm", "[Nm%=x]", ":bogus", ":nth-child(x)", "a,", ">"} {
+		if _, err := CompileSelector(bad); err == nil {
+			t.Errorf("expected error for selector: %q", bad)
+		}
+	}
+}
+
+func TestSelectorCache(t *testing.T) {
+	root := selTestTree()
+	hot := "NodeEmbed.primary"
+	root.QueryAll(hot)
+	for i := 0; i < 2*selCacheMax; i++ {
+		root.QueryAll(fmt.Sprintf("#child%d", i))
+		if i%16 == 0 {
+			root.QueryAll(hot) // keep recently used
+		}
+	}
+	selCache.Lock()
+	n := len(selCache.m)
+	_, hasHot := selCache.m[hot]
+	_, hasOld := selCache.m["#child0"]
+	selCache.Unlock()
+	if n != selCacheMax || selCache.lru.Len() != selCacheMax {
+		t.Errorf("selector cache not bounded: %v", n)
+	}
+	if !hasHot || hasOld {
+		t.Errorf("selector cache should evict least recently used: %v %v", hasHot, hasOld)
+	}
+}
@@ -6,7 +6,9 @@ package ki
 
 import (
 	"fmt"
+	"log"
 	"sync"
+	"sync/atomic"
 
 	"github.com/goki/ki/kit"
 )
@@ -25,9 +27,11 @@ const (
 
 	// NodeSignalUpdated indicates that the node was updated -- the node Flags
 	// accumulate the specific changes made since the last update signal --
-	// these flags are sent in the signal data -- strongly recommend using
-	// that instead of the flags, which can be subsequently updated by the
-	// time a signal is processed
+	// these flags are sent in the signal data, as an int64 -- strongly
+	// recommend using that instead of the flags, which can be subsequently
+	// updated by the time a signal is processed.  Connections that opt in
+	// with WithChanges get an UpdatedData instead, with the flags and the
+	// detailed list of changes made within the tree in the update batch.
 	NodeSignalUpdated
 
 	// NodeSignalDeleting indicates that the node is being deleted from its
@@ -36,6 +40,15 @@ const (
 	// it will be destroyed unless you hear from it again.
 	NodeSignalDeleting
 
+	// NodeSignalDeleteQuery is sent with EmitCollect before the node is
+	// deleted from its parent by DeleteChild (and related methods) or
+	// Delete, to receivers connected with ConnectRet -- any receiver can
+	// return false to veto the deletion (see DeleteTry).  It is separate
+	// from NodeSignalDeleting, which is still only sent once the node has
+	// been deleted, to all receivers, so existing receivers of that do not
+	// see deletions that then do not happen.
+	NodeSignalDeleteQuery
+
 	NodeSignalsN
 )
 
@@ -57,6 +70,10 @@ var SignalTraceString *string
 // types and referring to them directly
 type RecvFunc func(recv, send Ki, sig int64, data interface{})
 
+// RecvRetFunc is a receiver function type for signals that returns a
+// value, which is collected by EmitCollect -- see ConnectRet.
+type RecvRetFunc func(recv, send Ki, sig int64, data interface{}) interface{}
+
 // Signal implements general signal passing between Ki objects, like Qt's
 // Signal / Slot system.
 //
@@ -77,9 +94,17 @@ type RecvFunc func(recv, send Ki, sig int64, data interface{})
 // used).  Furthermore, arbitrary data as an interface{} can be passed as
 // well.
 //
-// The Signal uses a map indexed by the receiver pointer to hold the
-// connections -- this means that there can only be one such connection per
-// receiver, and the order of signal emission to different receiveres will be random.
+// The Signal keeps the connections in a list, and signals are delivered in
+// order of decreasing priority (see ConnectPri), and in the order the
+// connections were made within the same priority.  Connect makes the only
+// connection for a given receiver, replacing any existing ones, while
+// ConnectPri adds another connection, so a receiver can have any number of
+// them.  Each connection is identified by its SignalConn handle, which can
+// be used to disconnect just that connection.
+//
+// Receivers are normally called directly on the goroutine that emits the
+// signal, but receivers bound to a Mailbox (see BindMailbox) have the calls
+// queued on it, to be run serially by its loop goroutine.
 //
 // Typically an inline anonymous closure receiver function is used to keep all
 // the relevant code in one place.  Due to the typically long-standing nature
@@ -94,41 +119,181 @@ type RecvFunc func(recv, send Ki, sig int64, data interface{})
 // SendType (otherwise if it is known to be of a given type, just directly
 // converting as such is fine)
 type Signal struct {
-	Cons map[Ki]RecvFunc `view:"-" json:"-" xml:"-" desc:"map of receivers and their functions"`
-	Mu   sync.RWMutex    `view:"-" json:"-" xml:"-" desc:"read-write mutex that protects Cons map access -- use RLock for all Cons reads, Lock for all writes"`
+	Cons []*SignalConn `view:"-" json:"-" xml:"-" desc:"list of connections, in order of delivery -- the list is replaced, never modified in place, when connections change, so a copy taken under the read lock can be used without the lock"`
+	Mu   sync.RWMutex  `view:"-" json:"-" xml:"-" desc:"read-write mutex that protects Cons access -- use RLock for all Cons reads, Lock for all writes"`
 }
 
 var KiT_Signal = kit.Types.AddType(&Signal{}, nil)
 
+// SignalConn is one connection of a receiver to a Signal, returned as a
+// handle by Connect and ConnectPri, which can be used to disconnect it.
+type SignalConn struct {
+	Recv   Ki          `desc:"the receiver"`
+	Fun    RecvFunc    `desc:"the receiving function"`
+	RetFun RecvRetFunc `desc:"for connections made with ConnectRet, the receiving function that returns a value -- Fun calls this and ignores the value"`
+	Pri    int         `desc:"priority of the connection -- higher priorities are delivered first"`
+
+	sig     *Signal
+	off     int32 // set atomically when disconnected
+	changes int32 // set atomically by WithChanges
+}
+
+// Disconnect disconnects this connection from its signal -- it is not
+// called by any Emit in progress after this returns, if called from the
+// same goroutine.
+func (sc *SignalConn) Disconnect() {
+	if sc == nil || sc.sig == nil {
+		return
+	}
+	sc.sig.DisconnectConn(sc)
+}
+
+// IsConnected returns true if the connection has not been disconnected.
+func (sc *SignalConn) IsConnected() bool {
+	return sc != nil && atomic.LoadInt32(&sc.off) == 0
+}
+
+// WithChanges makes the connection receive the data of NodeSignalUpdated
+// signals as an UpdatedData, with the changes recorded in the update
+// batch, instead of just the node Flags as an int64.  Returns the
+// connection, e.g.:
+//
+//	k.NodeSignal().Connect(recv, fun).WithChanges()
+func (sc *SignalConn) WithChanges() *SignalConn {
+	atomic.StoreInt32(&sc.changes, 1)
+	return sc
+}
+
+// data returns the signal data to pass to the receiver: the UpdatedData
+// of a NodeSignalUpdated signal is reduced to its Flags unless the
+// connection was made WithChanges.
+func (sc *SignalConn) data(sig int64, data interface{}) interface{} {
+	if ud, ok := data.(UpdatedData); ok && sig == int64(NodeSignalUpdated) && atomic.LoadInt32(&sc.changes) == 0 {
+		return ud.Flags
+	}
+	return data
+}
+
+// send calls the receiving function, or queues the call on the Mailbox of
+// the receiver if it is bound to one (or, if async, starts a goroutine to
+// call it otherwise).  A queued call is skipped if the connection has
+// since been disconnected, or the receiver destroyed.
+func (sc *SignalConn) send(sender Ki, sig int64, data interface{}, async bool) {
+	data = sc.data(sig, data)
+	if mb := MailboxFor(sc.Recv); mb != nil {
+		mb.Post(func() {
+			if sc.IsConnected() && !sc.Recv.IsDestroyed() {
+				sc.Fun(sc.Recv, sender, sig, data)
+			}
+		})
+		return
+	}
+	if async {
+		go sc.Fun(sc.Recv, sender, sig, data)
+		return
+	}
+	sc.Fun(sc.Recv, sender, sig, data)
+}
+
 // ConnectOnly first deletes any existing connections and then attaches a new
 // receiver to the signal
-func (s *Signal) ConnectOnly(recv Ki, fun RecvFunc) {
+func (s *Signal) ConnectOnly(recv Ki, fun RecvFunc) *SignalConn {
 	s.DisconnectAll()
-	s.Connect(recv, fun)
+	return s.Connect(recv, fun)
 }
 
-// Connect attaches a new receiver and function to the signal -- only one such
-// connection per receiver can be made, so any existing connection to that
-// receiver will be overwritten
-func (s *Signal) Connect(recv Ki, fun RecvFunc) {
+// Connect attaches a new receiver and function to the signal, at priority
+// 0 -- this is the only connection for the receiver, so any existing
+// connections to that receiver are disconnected (use ConnectPri to make
+// multiple connections).  Returns the handle of the new connection.
+func (s *Signal) Connect(recv Ki, fun RecvFunc) *SignalConn {
+	return s.connect(recv, 0, fun, true)
+}
+
+// ConnectPri adds a new connection of a receiver and function to the
+// signal, at given priority -- connections with higher priority are
+// delivered first, and those with the same priority in the order they were
+// made (Connect uses priority 0).  Any existing connections to the
+// receiver are kept, so a receiver can have any number of connections.
+// Returns the handle of the new connection.
+func (s *Signal) ConnectPri(recv Ki, pri int, fun RecvFunc) *SignalConn {
+	return s.connect(recv, pri, fun, false)
+}
+
+// ConnectRet adds a new connection of a receiver and a function that
+// returns a value to the signal, at given priority, like ConnectPri -- the
+// values are collected by EmitCollect, which only calls these connections,
+// while the other Emit methods call them like any other connection and
+// ignore the value.  EmitCollect does not call receivers bound to a
+// Mailbox.  Returns the handle of the new connection.
+func (s *Signal) ConnectRet(recv Ki, pri int, fun RecvRetFunc) *SignalConn {
+	sc := s.connect(recv, pri, func(recv, send Ki, sig int64, data interface{}) {
+		fun(recv, send, sig, data)
+	}, false)
+	sc.RetFun = fun
+	return sc
+}
+
+// connect adds the connection, optionally replacing those of the receiver
+func (s *Signal) connect(recv Ki, pri int, fun RecvFunc, only bool) *SignalConn {
+	sc := &SignalConn{Recv: recv, Fun: fun, Pri: pri, sig: s}
 	s.Mu.Lock()
-	if s.Cons == nil {
-		s.Cons = make(map[Ki]RecvFunc)
+	cons := make([]*SignalConn, 0, len(s.Cons)+1)
+	for _, c := range s.Cons {
+		if only && c.Recv == recv {
+			atomic.StoreInt32(&c.off, 1)
+			continue
+		}
+		cons = append(cons, c)
 	}
-	s.Cons[recv] = fun
+	at := len(cons)
+	for at > 0 && cons[at-1].Pri < pri {
+		at--
+	}
+	cons = append(cons, nil)
+	copy(cons[at+1:], cons[at:])
+	cons[at] = sc
+	s.Cons = cons
 	s.Mu.Unlock()
+	return sc
 }
 
-// Disconnect disconnects (deletes) the connection for a given receiver
-func (s *Signal) Disconnect(recv Ki) {
+// disconnect removes all connections for which del returns true
+func (s *Signal) disconnect(del func(c *SignalConn) bool) {
 	s.Mu.Lock()
-	delete(s.Cons, recv)
+	var cons []*SignalConn
+	for i, c := range s.Cons {
+		if !del(c) {
+			if cons != nil {
+				cons = append(cons, c)
+			}
+			continue
+		}
+		atomic.StoreInt32(&c.off, 1)
+		if cons == nil {
+			cons = make([]*SignalConn, i, len(s.Cons))
+			copy(cons, s.Cons[:i])
+		}
+	}
+	if cons != nil {
+		s.Cons = cons
+	}
 	s.Mu.Unlock()
 }
 
-// DisconnectDestroyed disconnects (deletes) the connection for a given receiver,
-// if receiver is destroyed, assumed to be under an RLock (unlocks, relocks read lock).
-// Returns true if was disconnected.
+// Disconnect disconnects (deletes) all the connections for a given receiver
+func (s *Signal) Disconnect(recv Ki) {
+	s.disconnect(func(c *SignalConn) bool { return c.Recv == recv })
+}
+
+// DisconnectConn disconnects (deletes) a given connection
+func (s *Signal) DisconnectConn(sc *SignalConn) {
+	s.disconnect(func(c *SignalConn) bool { return c == sc })
+}
+
+// DisconnectDestroyed disconnects (deletes) the connections for a given
+// receiver, if receiver is destroyed, assumed to be under an RLock
+// (unlocks, relocks read lock).  Returns true if was disconnected.
 func (s *Signal) DisconnectDestroyed(recv Ki) bool {
 	if recv.IsDestroyed() {
 		s.Mu.RUnlock()
@@ -142,10 +307,35 @@ func (s *Signal) DisconnectDestroyed(recv Ki) bool {
 // DisconnectAll removes all connections
 func (s *Signal) DisconnectAll() {
 	s.Mu.Lock()
-	s.Cons = make(map[Ki]RecvFunc)
+	for _, c := range s.Cons {
+		atomic.StoreInt32(&c.off, 1)
+	}
+	s.Cons = nil
 	s.Mu.Unlock()
 }
 
+// NCons returns the number of connections
+func (s *Signal) NCons() int {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return len(s.Cons)
+}
+
+// cons returns the current list of live connections, disconnecting any to
+// destroyed receivers -- the list must not be modified.
+func (s *Signal) cons() []*SignalConn {
+	s.Mu.RLock()
+	cons := s.Cons
+	s.Mu.RUnlock()
+	for _, c := range cons {
+		if c.Recv.IsDestroyed() {
+			s.disconnect(func(c *SignalConn) bool { return c.Recv.IsDestroyed() })
+			break
+		}
+	}
+	return cons
+}
+
 // EmitTrace records a trace of signal being emitted
 func (s *Signal) EmitTrace(sender Ki, sig int64, data interface{}) {
 	if SignalTraceString != nil {
@@ -156,7 +346,10 @@ func (s *Signal) EmitTrace(sender Ki, sig int64, data interface{}) {
 }
 
 // Emit sends the signal across all the connections to the receivers --
-// sequentially but in random order due to the randomization of map iteration
+// sequentially in order of priority and then connection order.
+// Connections that are disconnected while the signal is being sent (e.g.,
+// by an earlier receiver) are skipped, and those made are not included.
+// Signals to receivers bound to a Mailbox are queued on it instead.
 func (s *Signal) Emit(sender Ki, sig int64, data interface{}) {
 	if sender == nil || sender.IsDestroyed() { // dead nodes don't talk..
 		return
@@ -164,20 +357,17 @@ func (s *Signal) Emit(sender Ki, sig int64, data interface{}) {
 	if SignalTrace {
 		s.EmitTrace(sender, sig, data)
 	}
-	s.Mu.RLock()
-	for recv, fun := range s.Cons {
-		if s.DisconnectDestroyed(recv) {
-			continue
+	for _, c := range s.cons() {
+		if c.IsConnected() {
+			c.send(sender, sig, data, false)
 		}
-		s.Mu.RUnlock()
-		fun(recv, sender, sig, data)
-		s.Mu.RLock()
 	}
-	s.Mu.RUnlock()
 }
 
 // EmitGo is the concurrent version of Emit -- sends the signal across all the
-// connections to the receivers as separate goroutines
+// connections to the receivers as separate goroutines, except for
+// receivers bound to a Mailbox, which are queued on it -- use a Mailbox to
+// avoid running receivers concurrently on unsynchronized nodes.
 func (s *Signal) EmitGo(sender Ki, sig int64, data interface{}) {
 	if sender == nil || sender.IsDestroyed() { // dead nodes don't talk..
 		return
@@ -185,16 +375,9 @@ func (s *Signal) EmitGo(sender Ki, sig int64, data interface{}) {
 	if SignalTrace {
 		s.EmitTrace(sender, sig, data)
 	}
-	s.Mu.RLock()
-	for recv, fun := range s.Cons {
-		if s.DisconnectDestroyed(recv) {
-			continue
-		}
-		s.Mu.RUnlock()
-		go fun(recv, sender, sig, data)
-		s.Mu.RLock()
+	for _, c := range s.cons() {
+		c.send(sender, sig, data, true)
 	}
-	s.Mu.RUnlock()
 }
 
 // SignalFilterFunc is the function type for filtering signals before they are
@@ -202,67 +385,144 @@ func (s *Signal) EmitGo(sender Ki, sig int64, data interface{}) {
 type SignalFilterFunc func(recv Ki) bool
 
 // EmitFiltered calls function on each potential receiver, and only sends
-// signal if function returns true
+// signal if function returns true -- in the same order as Emit
 func (s *Signal) EmitFiltered(sender Ki, sig int64, data interface{}, filtFun SignalFilterFunc) {
-	s.Mu.RLock()
-	for recv, fun := range s.Cons {
-		if s.DisconnectDestroyed(recv) {
-			continue
-		}
-		s.Mu.RUnlock()
-		if filtFun(recv) {
-			fun(recv, sender, sig, data)
+	for _, c := range s.cons() {
+		if c.IsConnected() && filtFun(c.Recv) {
+			c.send(sender, sig, data, false)
 		}
-		s.Mu.RLock()
 	}
-	s.Mu.RUnlock()
 }
 
 // EmitGoFiltered is the concurrent version of EmitFiltered -- calls function
 // on each potential receiver, and only sends signal if function returns true
 // (filtering is sequential iteration over receivers)
 func (s *Signal) EmitGoFiltered(sender Ki, sig int64, data interface{}, filtFun SignalFilterFunc) {
-	s.Mu.RLock()
-	for recv, fun := range s.Cons {
-		if s.DisconnectDestroyed(recv) {
-			continue
-		}
-		s.Mu.RUnlock()
-		if filtFun(recv) {
-			go fun(recv, sender, sig, data)
+	for _, c := range s.cons() {
+		if filtFun(c.Recv) {
+			c.send(sender, sig, data, true)
 		}
-		s.Mu.RLock()
 	}
-	s.Mu.RUnlock()
 }
 
-// ConsFunc iterates over the connections with read lock and deletion of
-// destroyed objects, calling given function on each connection -- if
-// it returns false, then iteration is stopped, else continues.
+// ConsFunc iterates over the connections in order of delivery, with
+// deletion of destroyed objects, calling given function on each connection
+// -- if it returns false, then iteration is stopped, else continues.
 // function is called with no lock in place.
 func (s *Signal) ConsFunc(consFun func(recv Ki, fun RecvFunc) bool) {
-	s.Mu.RLock()
-	for recv, fun := range s.Cons {
-		if s.DisconnectDestroyed(recv) {
-			continue
-		}
-		s.Mu.RUnlock()
-		if !consFun(recv, fun) {
-			s.Mu.RLock()
+	for _, c := range s.cons() {
+		if c.IsConnected() && !consFun(c.Recv, c.Fun) {
 			break
 		}
-		s.Mu.RLock()
 	}
-	s.Mu.RUnlock()
 }
 
-// SendSig sends a signal to one given receiver -- receiver must already be
-// connected so that its receiving function is available
+// SendSig sends a signal to one given receiver, across all its connections
+// -- receiver must already be connected so that its receiving functions are
+// available
 func (s *Signal) SendSig(recv, sender Ki, sig int64, data interface{}) {
-	s.Mu.RLock()
-	fun := s.Cons[recv]
-	s.Mu.RUnlock()
-	if fun != nil {
-		fun(recv, sender, sig, data)
+	for _, c := range s.cons() {
+		if c.Recv == recv && c.IsConnected() {
+			c.send(sender, sig, data, false)
+		}
+	}
+}
+
+// SignalCombiner combines the values returned by the receivers in an
+// EmitCollect -- a new one must be used for each EmitCollect.
+type SignalCombiner interface {
+	// Add adds the value returned by the next receiver, returning false to
+	// stop calling any further receivers.
+	Add(val interface{}) bool
+
+	// Result returns the combined result of all the values.
+	Result() interface{}
+}
+
+// FirstCombiner is a SignalCombiner whose Result is the first non-nil
+// value returned -- no further receivers are called after that.
+type FirstCombiner struct {
+	Val interface{} `desc:"the first non-nil value"`
+}
+
+func (fc *FirstCombiner) Add(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	fc.Val = val
+	return false
+}
+
+func (fc *FirstCombiner) Result() interface{} {
+	return fc.Val
+}
+
+// AllCombiner is a SignalCombiner whose Result is the []interface{} list
+// of all the non-nil values returned, in order.
+type AllCombiner struct {
+	Vals []interface{} `desc:"the non-nil values"`
+}
+
+func (ac *AllCombiner) Add(val interface{}) bool {
+	if val != nil {
+		ac.Vals = append(ac.Vals, val)
+	}
+	return true
+}
+
+func (ac *AllCombiner) Result() interface{} {
+	return ac.Vals
+}
+
+// AndCombiner is a SignalCombiner for vetoes, whose Result is the boolean
+// AND of all the bool values returned -- i.e., true unless any receiver
+// returned false, after which no further receivers are called.  Values
+// that are not bool are ignored.
+type AndCombiner struct {
+	Vetoed bool `desc:"true if a receiver returned false"`
+}
+
+func (ac *AndCombiner) Add(val interface{}) bool {
+	if b, ok := val.(bool); ok && !b {
+		ac.Vetoed = true
+		return false
+	}
+	return true
+}
+
+func (ac *AndCombiner) Result() interface{} {
+	return !ac.Vetoed
+}
+
+// EmitCollect sends the signal to all the connections made with
+// ConnectRet, in the same order as Emit, adding the values they return to
+// the combiner, which can stop the emission, and returns the combined
+// Result -- e.g., use an AndCombiner to let receivers veto an action, and
+// an AllCombiner to collect items from them.  Other connections are not
+// called, and neither are those of receivers bound to a Mailbox: their
+// functions must only run on its loop, and EmitCollect cannot wait for the
+// loop to return their value, as it may be running on the loop itself --
+// an error is logged for each such receiver instead.
+func (s *Signal) EmitCollect(sender Ki, sig int64, data interface{}, comb SignalCombiner) interface{} {
+	if sender == nil || sender.IsDestroyed() { // dead nodes don't talk..
+		return comb.Result()
+	}
+	traced := false
+	for _, c := range s.cons() {
+		if c.RetFun == nil || !c.IsConnected() {
+			continue
+		}
+		if MailboxFor(c.Recv) != nil {
+			log.Printf("ki.Signal.EmitCollect: receiver: %v is bound to a Mailbox, so it is not called\n", c.Recv.Name())
+			continue
+		}
+		if SignalTrace && !traced { // only trace if anyone is listening
+			s.EmitTrace(sender, sig, data)
+			traced = true
+		}
+		if !comb.Add(c.RetFun(c.Recv, sender, sig, c.data(sig, data))) {
+			break
+		}
 	}
+	return comb.Result()
 }
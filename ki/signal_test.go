@@ -71,3 +71,178 @@ func TestSignalNameToInt(t *testing.T) {
 		t.Errorf("could not convert from signal type name %v -- got: %v -- maybe need to run go generate?", str, stc.String())
 	}
 }
+
+func TestSignalOrder(t *testing.T) {
+	parent := TestNode{}
+	parent.InitName(&parent, "par1")
+	var kids []Ki
+	for i := 0; i < 5; i++ {
+		kids = append(kids, parent.AddNewChild(nil, fmt.Sprintf("child%d", i)))
+	}
+
+	var res []string
+	recv := func(tag string) RecvFunc {
+		return func(receiver, sender Ki, sig int64, data interface{}) {
+			res = append(res, receiver.Name()+tag)
+		}
+	}
+	for _, k := range kids {
+		parent.sig1.Connect(k, recv(""))
+	}
+	parent.sig1.Connect(kids[2], recv("b")) // replaces, at end
+	hi := parent.sig1.ConnectPri(kids[4], 10, recv("hi"))
+	parent.sig1.ConnectPri(kids[0], -1, recv("lo"))
+	c3 := parent.sig1.ConnectPri(kids[3], 0, recv("x"))
+	for i := 0; i < 3; i++ { // deterministic every time
+		res = res[:0]
+		parent.sig1.Emit(&parent, 0, nil)
+		trg := []string{"child4hi", "child0", "child1", "child3", "child4", "child2b", "child3x", "child0lo"}
+		if !reflect.DeepEqual(res, trg) {
+			t.Errorf("signal order error -- results: %v != target: %v\n", res, trg)
+		}
+	}
+
+	c3.Disconnect()
+	hi.Disconnect()
+	if c3.IsConnected() || parent.sig1.NCons() != 6 {
+		t.Errorf("Disconnect handle failed: %v", parent.sig1.NCons())
+	}
+	parent.sig1.Disconnect(kids[0])
+	res = res[:0]
+	parent.sig1.ConsFunc(func(recv Ki, fun RecvFunc) bool {
+		res = append(res, recv.Name())
+		return true
+	})
+	if !reflect.DeepEqual(res, []string{"child1", "child3", "child4", "child2"}) {
+		t.Errorf("ConsFunc after Disconnect: %v", res)
+	}
+
+	// disconnecting during Emit skips later receivers, and destroyed
+	// receivers are disconnected
+	res = res[:0]
+	parent.sig1.ConnectPri(kids[1], 1, func(receiver, sender Ki, sig int64, data interface{}) {
+		parent.sig1.Disconnect(kids[4])
+	})
+	kids[3].Destroy()
+	parent.sig1.Emit(&parent, 0, nil)
+	if !reflect.DeepEqual(res, []string{"child1", "child2b"}) || parent.sig1.NCons() != 3 {
+		t.Errorf("Emit with disconnect: %v %v", res, parent.sig1.NCons())
+	}
+	res = res[:0]
+	parent.sig1.EmitFiltered(&parent, 0, nil, func(recv Ki) bool { return recv != kids[2] })
+	parent.sig1.SendSig(kids[2], &parent, 0, nil)
+	if !reflect.DeepEqual(res, []string{"child1", "child2b"}) {
+		t.Errorf("EmitFiltered, SendSig: %v", res)
+	}
+}
+
+func TestSignalEmitCollect(t *testing.T) {
+	parent := TestNode{}
+	parent.InitName(&parent, "par1")
+	child1 := parent.AddNewChild(nil, "child1")
+	child2 := parent.AddNewChild(nil, "child2")
+	child3 := parent.AddNewChild(nil, "child3")
+
+	called := 0
+	parent.sig1.Connect(child1, func(receiver, sender Ki, sig int64, data interface{}) {
+		called++
+	})
+	parent.sig1.ConnectRet(child2, 0, func(receiver, sender Ki, sig int64, data interface{}) interface{} {
+		return receiver.Name()
+	})
+	parent.sig1.ConnectRet(child3, 1, func(receiver, sender Ki, sig int64, data interface{}) interface{} {
+		if data == nil {
+			return nil
+		}
+		return data
+	})
+
+	all := parent.sig1.EmitCollect(&parent, 0, "data", &AllCombiner{})
+	if !reflect.DeepEqual(all, []interface{}{"data", "child2"}) || called != 0 {
+		t.Errorf("EmitCollect AllCombiner: %v called: %v", all, called)
+	}
+	if fst := parent.sig1.EmitCollect(&parent, 0, nil, &FirstCombiner{}); fst != "child2" {
+		t.Errorf("EmitCollect FirstCombiner: %v", fst)
+	}
+	parent.sig1.Emit(&parent, 0, nil) // ConnectRet receivers also get Emit
+	if called != 1 {
+		t.Errorf("Emit to plain receiver: %v", called)
+	}
+
+	ok := parent.sig1.EmitCollect(&parent, 0, nil, &AndCombiner{})
+	if ok != true {
+		t.Errorf("EmitCollect AndCombiner without veto: %v", ok)
+	}
+	nveto := 0
+	parent.sig1.ConnectRet(child1, 2, func(receiver, sender Ki, sig int64, data interface{}) interface{} {
+		nveto++
+		return false
+	})
+	parent.sig1.ConnectRet(child1, -1, func(receiver, sender Ki, sig int64, data interface{}) interface{} {
+		nveto++
+		return false
+	})
+	ok = parent.sig1.EmitCollect(&parent, 0, nil, &AndCombiner{})
+	if ok != false || nveto != 1 {
+		t.Errorf("EmitCollect AndCombiner with veto: %v n: %v", ok, nveto)
+	}
+
+	// receivers bound to a Mailbox are not called
+	mb := NewMailbox()
+	BindMailbox(child2, mb)
+	defer BindMailbox(child2, nil)
+	all = parent.sig1.EmitCollect(&parent, 0, "data", &AllCombiner{})
+	if !reflect.DeepEqual(all, []interface{}{false, "data", false}) || mb.Len() != 0 {
+		t.Errorf("EmitCollect to Mailbox receiver: %v queued: %v", all, mb.Len())
+	}
+}
+
+func TestDeleteVeto(t *testing.T) {
+	parent := NodeEmbed{}
+	parent.InitName(&parent, "par1")
+	child1 := parent.AddNewChild(nil, "child1")
+	child2 := parent.AddNewChild(nil, "child2")
+
+	veto := true
+	sc := child1.NodeSignal().ConnectRet(&parent, 0, func(receiver, sender Ki, sig int64, data interface{}) interface{} {
+		if sig != int64(NodeSignalDeleteQuery) {
+			return nil
+		}
+		return !veto
+	})
+	if err := parent.DeleteChild(child1, true); err == nil {
+		t.Errorf("vetoed DeleteChild should return error")
+	}
+	if err := child1.DeleteTry(true); err == nil {
+		t.Errorf("vetoed DeleteTry should return error")
+	}
+	child1.Delete(true)
+	if parent.NumChildren() != 2 || child1.Parent() != parent.This() {
+		t.Errorf("vetoed delete removed child: %v", parent.Kids)
+	}
+
+	// moving to another parent is not a deletion
+	other := NodeEmbed{}
+	other.InitName(&other, "other")
+	other.AddChild(child1)
+	if child1.Parent() != other.This() || parent.NumChildren() != 1 {
+		t.Errorf("vetoed move failed: %v", parent.Kids)
+	}
+
+	// destroying a root node can also be vetoed
+	other.NodeSignal().ConnectRet(&parent, 0, sc.RetFun)
+	if err := other.DeleteTry(true); err == nil {
+		t.Errorf("vetoed DeleteTry of root should return error")
+	}
+	if other.IsDestroyed() {
+		t.Errorf("vetoed Delete of root destroyed it")
+	}
+
+	veto = false
+	if err := other.DeleteChild(child1, true); err != nil || other.NumChildren() != 0 {
+		t.Errorf("DeleteChild not vetoed: %v", err)
+	}
+	if _, err := parent.DeleteChildByName(child2.Name(), true); err != nil {
+		t.Errorf("DeleteChildByName: %v", err)
+	}
+}
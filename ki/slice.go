@@ -6,9 +6,7 @@ package ki
 
 import (
 	"bytes"
-	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"log"
 	"reflect"
@@ -404,9 +402,17 @@ func (sl *Slice) UniqueNameToIndexMap() map[string]int {
 // a tree structure to fit a target configuration, specified in terms of a
 // type-and-name list.  If the node is != nil, then it has UpdateStart / End
 // logic applied to it, only if necessary, as indicated by mods, updt return
-// values.
+// values.  In concurrent mode, the tree lock of the node is held
+// throughout.
 func (sl *Slice) Config(n Ki, config kit.TypeAndNameList, uniqNm bool) (mods, updt bool) {
 	mods, updt = false, false
+	var us *UndoStack
+	var tl *TreeLock
+	if n != nil {
+		tl = n.AsNode().lockTree()
+		defer tl.Unlock()
+		us = undoRecorder(n)
+	}
 	// first make a map for looking up the indexes of the names
 	nm := make(map[string]int)
 	for i, tn := range config {
@@ -424,9 +430,9 @@ func (sl *Slice) Config(n Ki, config kit.TypeAndNameList, uniqNm bool) (mods, up
 		}
 		ti, ok := nm[knm]
 		if !ok {
-			sl.configDeleteKid(kid, i, n, &mods, &updt)
+			sl.configDeleteKid(kid, i, n, tl, us, &mods, &updt)
 		} else if kid.Type() != config[ti].Type {
-			sl.configDeleteKid(kid, i, n, &mods, &updt)
+			sl.configDeleteKid(kid, i, n, tl, us, &mods, &updt)
 		}
 	}
 	// next add and move items as needed -- in order so guaranteed
@@ -444,23 +450,29 @@ func (sl *Slice) Config(n Ki, config kit.TypeAndNameList, uniqNm bool) (mods, up
 			nkid.Init(nkid)
 			sl.Insert(nkid, i)
 			if n != nil {
-				nkid.SetParent(n)
+				nkid.AsNode().setParentLocked(n)
 				n.SetFlag(int(ChildAdded))
+				n.AsNode().addChange(Change{Type: ChangeChildAdded, Kid: nkid, Index: i})
+				us.record(&undoChildIns{par: n, kid: nkid, idx: i})
 			}
 			if uniqNm {
 				nkid.SetNameRaw(tn.Name)
 				nkid.SetUniqueName(tn.Name)
 			} else {
-				nkid.SetName(tn.Name) // triggers uniquify -- slow!
+				nkid.AsNode().setNameLocked(tn.Name) // triggers uniquify -- slow!
 			}
 		} else {
 			if kidx != i {
 				setMods(n, &mods, &updt)
 				sl.Move(kidx, i)
+				if n != nil {
+					n.AsNode().addChange(Change{Type: ChangeChildMoved, Kid: (*sl)[i], From: kidx, To: i})
+					us.record(&undoChildMove{par: n, frm: kidx, to: i})
+				}
 			}
 		}
 	}
-	DelMgr.DestroyDeleted()
+	tl.afterUnlock(DelMgr.DestroyDeleted)
 	return
 }
 
@@ -468,25 +480,30 @@ func setMods(n Ki, mods *bool, updt *bool) {
 	if !*mods {
 		*mods = true
 		if n != nil {
-			*updt = n.UpdateStart()
+			*updt = n.AsNode().updateStartLocked()
 		}
 	}
 }
 
-func (sl *Slice) configDeleteKid(kid Ki, i int, n Ki, mods, updt *bool) {
+func (sl *Slice) configDeleteKid(kid Ki, i int, n Ki, tl *TreeLock, us *UndoStack, mods, updt *bool) {
 	if !*mods {
 		*mods = true
 		if n != nil {
-			*updt = n.UpdateStart()
+			*updt = n.AsNode().updateStartLocked()
 			n.SetFlag(int(ChildDeleted))
 		}
 	}
 	kid.SetFlag(int(NodeDeleted))
-	kid.NodeSignal().Emit(kid, int64(NodeSignalDeleting), nil)
-	kid.SetParent(nil)
-	DelMgr.Add(kid)
+	tl.afterUnlock(func() { kid.NodeSignal().Emit(kid, int64(NodeSignalDeleting), nil) })
+	kid.AsNode().setParentLocked(nil)
 	sl.DeleteAtIndex(i)
-	kid.UpdateReset() // it won't get the UpdateEnd from us anymore -- init fresh in any case
+	if n != nil {
+		n.AsNode().addChange(Change{Type: ChangeChildDeleted, Kid: kid, Index: i})
+	}
+	if !us.record(&undoChildDel{par: n, kid: kid, idx: i, wasPar: true, destroy: true}) {
+		DelMgr.Add(kid)
+	}
+	kid.AsNode().updateResetLocked() // it won't get the UpdateEnd from us anymore -- init fresh in any case
 }
 
 // CopyFrom another Slice.  It is efficient by using the Config method
@@ -529,120 +546,21 @@ func (sl *Slice) ConfigCopy(n Ki, frm Slice) {
 // structs for each element in the slice -- this allows the Unmarshal to first
 // create all the elements and then load them
 func (sl Slice) MarshalJSON() ([]byte, error) {
-	nk := len(sl)
-	b := make([]byte, 0, nk*100+20)
-	if nk == 0 {
-		b = append(b, []byte("null")...)
-		return b, nil
-	}
-	nstr := fmt.Sprintf("[{\"n\":%d,", nk)
-	b = append(b, []byte(nstr)...)
-	for i, kid := range sl {
-		// fmt.Printf("json out of %v\n", kid.PathUnique())
-		knm := kit.Types.TypeName(reflect.TypeOf(kid).Elem())
-		tstr := fmt.Sprintf("\"type\":\"%v\", \"name\": \"%v\"", knm, kid.UniqueName()) // todo: escape names!
-		b = append(b, []byte(tstr)...)
-		if i < nk-1 {
-			b = append(b, []byte(",")...)
-		}
-	}
-	b = append(b, []byte("},")...)
-	for i, kid := range sl {
-		var err error
-		var kb []byte
-		kb, err = json.Marshal(kid)
-		if err == nil {
-			b = append(b, []byte("{")...)
-			b = append(b, kb[1:len(kb)-1]...)
-			b = append(b, []byte("}")...)
-			if i < nk-1 {
-				b = append(b, []byte(",")...)
-			}
-		} else {
-			fmt.Printf("error doing json.Marshall from kid: %v\n", kid.PathUnique())
-			log.Println(err)
-			fmt.Printf("output to point of error: %v\n", string(b))
-		}
+	var b bytes.Buffer
+	je := jsonEncoder{w: &b}
+	err := je.encodeSlice(sl, "")
+	if err != nil {
+		log.Println(err)
+		return nil, err
 	}
-	b = append(b, []byte("]")...)
-	// fmt.Printf("json out: %v\n", string(b))
-	return b, nil
+	return b.Bytes(), nil
 }
 
-///////////////////////////////////////////////////////////////////////////
-// JSON
-
 // UnmarshalJSON parses the length and type information for each object in the
 // slice, creates the new slice with those elements, and then loads based on
 // the remaining bytes which represent each element
 func (sl *Slice) UnmarshalJSON(b []byte) error {
-	// fmt.Printf("json in: %v\n", string(b))
-	if bytes.Equal(b, []byte("null")) {
-		*sl = nil
-		return nil
-	}
-	lb := bytes.IndexRune(b, '{')
-	rb := bytes.IndexRune(b, '}')
-	if lb < 0 || rb < 0 { // probably null
-		return nil
-	}
-	// todo: if name contains "," this won't work..
-	flds := bytes.Split(b[lb+1:rb], []byte(","))
-	if len(flds) == 0 {
-		return errors.New("Slice UnmarshalJSON: no child data found")
-	}
-	// fmt.Printf("flds[0]:\n%v\n", string(flds[0]))
-	ns := bytes.Index(flds[0], []byte("\"n\":"))
-	bn := bytes.TrimSpace(flds[0][ns+4:])
-
-	n64, err := strconv.ParseInt(string(bn), 10, 64)
-	if err != nil {
-		return err
-	}
-	n := int(n64)
-	if n == 0 {
-		return nil
-	}
-	// fmt.Printf("n parsed: %d from %v\n", n, string(bn))
-
-	tnl := make(kit.TypeAndNameList, n)
-
-	for i := 0; i < n; i++ {
-		fld := flds[2*i+1]
-		// fmt.Printf("fld:\n%v\n", string(fld))
-		ti := bytes.Index(fld, []byte("\"type\":"))
-		tn := string(bytes.Trim(bytes.TrimSpace(fld[ti+7:]), "\""))
-		fld = flds[2*i+2]
-		ni := bytes.Index(fld, []byte("\"name\":"))
-		nm := string(bytes.Trim(bytes.TrimSpace(fld[ni+7:]), "\""))
-		// fmt.Printf("making type: %v", tn)
-		typ := kit.Types.Type(tn)
-		if typ == nil {
-			return fmt.Errorf("ki.Slice UnmarshalJSON: kit.Types type name not found: %v", tn)
-		}
-		tnl[i].Type = typ
-		tnl[i].Name = nm
-	}
-
-	sl.Config(nil, tnl, true) // true = uniq names
-
-	nwk := make([]Ki, n) // allocate new slice containing *pointers* to kids
-
-	for i, kid := range *sl {
-		nwk[i] = kid
-	}
-
-	cb := make([]byte, 0, 1+len(b)-rb)
-	cb = append(cb, []byte("[")...)
-	cb = append(cb, b[rb+2:]...)
-
-	// fmt.Printf("loading:\n%v", string(cb))
-
-	err = json.Unmarshal(cb, &nwk)
-	if err != nil {
-		return err
-	}
-	return nil
+	return newJSONDecoder(bytes.NewReader(b)).decodeSlice(sl, "")
 }
 
 // todo: save N as an attr instead of a full element
@@ -650,7 +568,8 @@ func (sl *Slice) UnmarshalJSON(b []byte) error {
 // MarshalXML saves the length and type information for each object in a
 // slice, as a separate struct-like record at the start, followed by the
 // structs for each element in the slice -- this allows the Unmarshal to first
-// create all the elements and then load them
+// create all the elements and then load them.  The unique name of each
+// element is saved as the name attribute of its Type element.
 func (sl Slice) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	tokens := []xml.Token{start}
 	nk := len(sl)
@@ -659,6 +578,7 @@ func (sl Slice) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	for _, kid := range sl {
 		knm := kit.Types.TypeName(reflect.TypeOf(kid).Elem())
 		t := xml.StartElement{Name: xml.Name{Space: "", Local: "Type"}}
+		t.Attr = []xml.Attr{{Name: xml.Name{Local: "name"}, Value: kid.UniqueName()}}
 		tokens = append(tokens, t, xml.CharData(knm), xml.EndElement{Name: t.Name})
 	}
 	for _, t := range tokens {
@@ -788,64 +708,78 @@ func DecodeXMLCharEl(d *xml.Decoder) (name, val string, err error) {
 }
 
 // UnmarshalXML parses the length and type information for each object in the
-// slice, creates the new slice with those elements, and then loads based on
-// the remaining bytes which represent each element
+// slice, configures the slice with those elements (using the unique names
+// from the name attributes of the Type elements, keeping existing elements
+// where possible -- older files without names get all new elements), and
+// then loads each element
 func (sl *Slice) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	// for _, attr := range start.Attr {
-	// 	// todo: need to set the props from name / value -- don't have parent though!
-	// }
 	name, val, err := DecodeXMLCharEl(d)
 	if err != nil {
 		return err
 	}
-	if name == "N" {
-		n64, err := strconv.ParseInt(string(val), 10, 64)
+	if name != "N" {
+		return fmt.Errorf("ki.Slice UnmarshalXML: expected N element, got: %v", name)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || n < 0 {
+		return fmt.Errorf("ki.Slice UnmarshalXML: N is not a count: %v", val)
+	}
+	tnl := make(kit.TypeAndNameList, n)
+	named := true
+	for i := 0; i < n; i++ {
+		st, err := DecodeXMLStartEl(d)
 		if err != nil {
 			return err
 		}
-		n := int(n64)
-		if n == 0 {
-			return DecodeXMLEndEl(d, start)
+		if st.Name.Local != "Type" {
+			return fmt.Errorf("ki.Slice UnmarshalXML: expected Type element %d of %d, got: %v", i, n, st.Name.Local)
 		}
-		// fmt.Printf("n parsed: %d from %v\n", n, string(val))
-		nwk := make([]Ki, 0, n) // allocate new slice
-
-		for i := 0; i < n; i++ {
-			name, val, err = DecodeXMLCharEl(d)
-			if name == "Type" {
-				tn := strings.TrimSpace(val)
-				// fmt.Printf("making type: %v\n", tn)
-				typ := kit.Types.Type(tn)
-				if typ == nil {
-					return fmt.Errorf("ki.Slice UnmarshalXML: kit.Types type name not found: %v", tn)
-				}
-				nkid := reflect.New(typ).Interface()
-				// fmt.Printf("nkid is new obj of type %T val: %+v\n", nkid, nkid)
-				kid, ok := nkid.(Ki)
-				if !ok {
-					return fmt.Errorf("ki.Slice UnmarshalXML: New child of type %v cannot convert to Ki", tn)
-				}
-				kid.Init(kid)
-				nwk = append(nwk, kid)
-			}
+		val, err := DecodeXMLCharData(d)
+		if err != nil {
+			return err
 		}
-
-		for i := 0; i < n; i++ {
-			st, err := DecodeXMLStartEl(d)
-			if err != nil {
-				return err
-			}
-			// todo: could double-check st
-			err = d.DecodeElement(nwk[i], &st)
-			if err != nil {
-				log.Printf("%v", err)
-				return err
+		if err = DecodeXMLEndEl(d, st); err != nil {
+			return err
+		}
+		tn := strings.TrimSpace(val)
+		typ := kit.Types.Type(tn)
+		if typ == nil {
+			return fmt.Errorf("ki.Slice UnmarshalXML: kit.Types type name not found: %v", tn)
+		}
+		if !IsKi(typ) {
+			return fmt.Errorf("ki.Slice UnmarshalXML: New child of type %v cannot convert to Ki", tn)
+		}
+		tnl[i].Type = typ
+		hasName := false
+		for _, attr := range st.Attr {
+			if attr.Name.Local == "name" {
+				tnl[i].Name = attr.Value
+				hasName = true
 			}
 		}
-		*sl = append(*sl, nwk...)
-		// } else {
+		named = named && hasName
+	}
+	if named {
+		sl.Config(nil, tnl, true) // true = uniq names
+	} else {
+		*sl = make(Slice, n)
+		for i, tn := range tnl {
+			kid := NewOfType(tn.Type)
+			kid.Init(kid)
+			(*sl)[i] = kid
+		}
+	}
+	for i := 0; i < n; i++ {
+		st, err := DecodeXMLStartEl(d)
+		if err != nil {
+			return err
+		}
+		if err = d.DecodeElement((*sl)[i], &st); err != nil {
+			return err
+		}
+	}
+	if n == 0 {
+		*sl = nil
 	}
-	// todo: in theory we could just parse a list of type names as tags, but for the "dump" format
-	// this is more robust.
 	return DecodeXMLEndEl(d, start) // final end
 }
@@ -0,0 +1,12 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import "syscall"
+
+// threadID returns the id of the current thread, from gettid
+func threadID() uint64 {
+	return uint64(syscall.Gettid())
+}
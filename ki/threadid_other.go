@@ -0,0 +1,13 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !darwin && !windows
+
+package ki
+
+// threadID returns 0, as thread ids are not available on this platform,
+// so update batches do not hold the TreeLock
+func threadID() uint64 {
+	return 0
+}
@@ -0,0 +1,15 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import "syscall"
+
+var procGetCurrentThreadId = syscall.NewLazyDLL("kernel32.dll").NewProc("GetCurrentThreadId")
+
+// threadID returns the id of the current thread, from GetCurrentThreadId
+func threadID() uint64 {
+	id, _, _ := procGetCurrentThreadId.Call()
+	return uint64(id)
+}
@@ -0,0 +1,775 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// UndoStack records the mutations made to a tree as reversible operations,
+// supporting Undo and Redo of entire transactions.  A transaction is
+// defined by the outermost UpdateStart / UpdateEnd pair within the tree:
+// all of the recorded mutations that happen between them are undone or
+// redone together.  Mutations outside of any update (e.g., SetProp, SetName)
+// are recorded as single-operation transactions.
+//
+// The following mutations are recorded: AddChild, InsertChild (and their
+// New / Fast variants), DeleteChild (and variants, DeleteChildren),
+// MoveChild, SwapChildren, ConfigChildren, SetName, SetField, SetProp,
+// SetProps and DeleteProp.  Bulk operations that bypass these methods
+// (CopyFrom, ReadJSON etc) are not recorded -- ReadJSON and ReadXML
+// automatically Reset the history.
+//
+// Children that are deleted with destroy = true are not destroyed while
+// their deletion is still in the history -- they are destroyed when the
+// transaction falls off the end of the history (see Max), or the stack
+// is Reset or Closed.
+//
+// Create with NewUndoStack, which registers the stack for the given root --
+// all nodes within that tree then record to it.
+type UndoStack struct {
+	Root  Ki           `desc:"root of the tree being recorded"`
+	Max   int          `desc:"maximum number of transactions retained in the undo history -- 0 = no limit"`
+	Undos []*UndoTrans `desc:"transactions that can be undone, most recent last"`
+	Redos []*UndoTrans `desc:"transactions that can be redone, most recent last"`
+	Mu    sync.Mutex   `desc:"mutex protecting the stack"`
+
+	cur       *UndoTrans
+	nextName  string
+	replaying bool
+}
+
+// UndoTrans is one undoable transaction: a list of reversible operations
+// recorded between an outermost UpdateStart / UpdateEnd pair.
+type UndoTrans struct {
+	Name  string `desc:"optional name of the transaction, e.g., for display in an Edit menu"`
+	owner Ki
+	ops   []undoOp
+}
+
+// NOps returns the number of operations recorded in the transaction.
+func (ut *UndoTrans) NOps() int {
+	return len(ut.ops)
+}
+
+// release releases any resources held by the transaction, destroying
+// nodes whose destruction has been deferred.
+func (ut *UndoTrans) release() {
+	for _, op := range ut.ops {
+		op.release()
+	}
+}
+
+// undoStacks is the registry of UndoStack's keyed by tree root
+var undoStacks = struct {
+	sync.RWMutex
+	m map[Ki]*UndoStack
+}{m: make(map[Ki]*UndoStack)}
+
+// undoActive is the number of registered UndoStack's -- avoids any
+// lookup cost when undo is not in use.
+var undoActive int32
+
+// NewUndoStack creates a new UndoStack that records all mutations within
+// the tree under given root node, retaining at most max transactions
+// (0 = no limit).  The root need not be the root of the whole tree, in
+// which case only mutations within its subtree are recorded, and a stack
+// on a node within that subtree takes precedence for its own subtree.
+// Any existing stack for the root is closed first.
+func NewUndoStack(root Ki, max int) *UndoStack {
+	if old := UndoStackFor(root); old != nil && old.Root == root {
+		old.Close()
+	}
+	us := &UndoStack{Root: root, Max: max}
+	undoStacks.Lock()
+	undoStacks.m[root] = us
+	atomic.StoreInt32(&undoActive, int32(len(undoStacks.m)))
+	undoStacks.Unlock()
+	return us
+}
+
+// UndoStackFor returns the UndoStack that records mutations for given
+// node, i.e., the stack of the closest of the node and its parents that
+// has one, or nil if none.
+func UndoStackFor(k Ki) *UndoStack {
+	if atomic.LoadInt32(&undoActive) == 0 || k == nil || k.This() == nil {
+		return nil
+	}
+	defer k.AsNode().rlockTree().RUnlock()
+	return undoStackForLocked(k)
+}
+
+// undoStackForLocked is UndoStackFor under the tree lock
+func undoStackForLocked(k Ki) *UndoStack {
+	if atomic.LoadInt32(&undoActive) == 0 || k == nil || k.This() == nil {
+		return nil
+	}
+	ks := lineageLocked(k)
+	undoStacks.RLock()
+	defer undoStacks.RUnlock()
+	for _, a := range ks {
+		if us, ok := undoStacks.m[a]; ok {
+			return us
+		}
+	}
+	return nil
+}
+
+// undoRecorder returns the UndoStack for given node if it is currently
+// recording (i.e., not replaying an undo / redo), else nil -- assumed to
+// be under the tree lock.
+func undoRecorder(k Ki) *UndoStack {
+	us := undoStackForLocked(k)
+	if us == nil {
+		return nil
+	}
+	us.Mu.Lock()
+	rep := us.replaying
+	us.Mu.Unlock()
+	if rep {
+		return nil
+	}
+	return us
+}
+
+// Close unregisters the stack so its tree is no longer recorded, and
+// releases all recorded history.
+func (us *UndoStack) Close() {
+	undoStacks.Lock()
+	if undoStacks.m[us.Root] == us {
+		delete(undoStacks.m, us.Root)
+	}
+	atomic.StoreInt32(&undoActive, int32(len(undoStacks.m)))
+	undoStacks.Unlock()
+	us.Reset()
+}
+
+// Reset clears all undo and redo history, destroying any deleted nodes
+// held by the history.
+func (us *UndoStack) Reset() {
+	us.Mu.Lock()
+	for _, ut := range us.Undos {
+		ut.release()
+	}
+	us.Undos = nil
+	us.Redos = nil
+	us.cur = nil
+	us.Mu.Unlock()
+	DelMgr.DestroyDeleted()
+}
+
+// Start starts a named transaction, by calling UpdateStart on the Root --
+// pass the result to End.  If a transaction is already in progress, the
+// name is applied to it if it does not already have one.
+func (us *UndoStack) Start(name string) bool {
+	us.Mu.Lock()
+	if us.cur != nil {
+		if us.cur.Name == "" {
+			us.cur.Name = name
+		}
+	} else {
+		us.nextName = name
+	}
+	us.Mu.Unlock()
+	return us.Root.UpdateStart()
+}
+
+// End ends a transaction started with Start, passing the result of
+// that call.
+func (us *UndoStack) End(updt bool) {
+	us.Root.UpdateEnd(updt)
+}
+
+// Do runs given function within a named transaction.
+func (us *UndoStack) Do(name string, fun func()) {
+	updt := us.Start(name)
+	fun()
+	us.End(updt)
+}
+
+// CanUndo returns true if there is a transaction that can be undone.
+func (us *UndoStack) CanUndo() bool {
+	us.Mu.Lock()
+	defer us.Mu.Unlock()
+	return len(us.Undos) > 0
+}
+
+// CanRedo returns true if there is a transaction that can be redone.
+func (us *UndoStack) CanRedo() bool {
+	us.Mu.Lock()
+	defer us.Mu.Unlock()
+	return len(us.Redos) > 0
+}
+
+// UndoName returns the name of the transaction that would be undone next.
+func (us *UndoStack) UndoName() string {
+	us.Mu.Lock()
+	defer us.Mu.Unlock()
+	if len(us.Undos) == 0 {
+		return ""
+	}
+	return us.Undos[len(us.Undos)-1].Name
+}
+
+// RedoName returns the name of the transaction that would be redone next.
+func (us *UndoStack) RedoName() string {
+	us.Mu.Lock()
+	defer us.Mu.Unlock()
+	if len(us.Redos) == 0 {
+		return ""
+	}
+	return us.Redos[len(us.Redos)-1].Name
+}
+
+// Undo reverses the most recent transaction, in reverse order of the
+// recorded operations -- each affected node is wrapped in UpdateStart /
+// UpdateEnd, emitting the usual NodeSignalUpdated signals.
+// Returns false if there was nothing to undo.
+func (us *UndoStack) Undo() bool {
+	us.Mu.Lock()
+	if len(us.Undos) == 0 || us.replaying {
+		us.Mu.Unlock()
+		return false
+	}
+	ut := us.Undos[len(us.Undos)-1]
+	us.Undos = us.Undos[:len(us.Undos)-1]
+	us.replaying = true
+	us.Mu.Unlock()
+
+	for i := len(ut.ops) - 1; i >= 0; i-- {
+		op := ut.ops[i]
+		replayOp(op, op.undo)
+	}
+
+	us.Mu.Lock()
+	us.replaying = false
+	us.Redos = append(us.Redos, ut)
+	us.Mu.Unlock()
+	return true
+}
+
+// Redo re-applies the most recently undone transaction -- each affected
+// node is wrapped in UpdateStart / UpdateEnd, emitting the usual
+// NodeSignalUpdated signals.  Returns false if there was nothing to redo.
+func (us *UndoStack) Redo() bool {
+	us.Mu.Lock()
+	if len(us.Redos) == 0 || us.replaying {
+		us.Mu.Unlock()
+		return false
+	}
+	ut := us.Redos[len(us.Redos)-1]
+	us.Redos = us.Redos[:len(us.Redos)-1]
+	us.replaying = true
+	us.Mu.Unlock()
+
+	for _, op := range ut.ops {
+		replayOp(op, op.redo)
+	}
+
+	us.Mu.Lock()
+	us.replaying = false
+	us.Undos = append(us.Undos, ut)
+	us.Mu.Unlock()
+	return true
+}
+
+// replayOp calls given undo or redo function of op under the tree lock,
+// wrapped in UpdateStart / UpdateEnd of its node.
+func replayOp(op undoOp, fun func()) {
+	tgt := op.node().AsNode()
+	tl := tgt.lockTree()
+	updt := tgt.updateStartLocked()
+	fun()
+	tgt.updateEndLocked(updt)
+	tl.Unlock()
+}
+
+// begin is called by UpdateStart when it returns true, opening a new
+// transaction if none is in progress.
+func (us *UndoStack) begin(k Ki) {
+	us.Mu.Lock()
+	defer us.Mu.Unlock()
+	if us.replaying {
+		return
+	}
+	if us.cur != nil {
+		if us.cur.owner.IsUpdating() {
+			return
+		}
+		us.commit() // stale -- owner was removed without UpdateEnd
+	}
+	us.cur = &UndoTrans{Name: us.nextName, owner: k}
+	us.nextName = ""
+}
+
+// end is called by UpdateEnd, closing the current transaction if given
+// node opened it.
+func (us *UndoStack) end(k Ki) {
+	us.Mu.Lock()
+	defer us.Mu.Unlock()
+	if us.cur == nil || us.cur.owner != k {
+		return
+	}
+	us.commit()
+}
+
+// commit pushes the current transaction onto the undo history, clearing
+// any redo history -- must be called under lock.
+func (us *UndoStack) commit() {
+	ut := us.cur
+	us.cur = nil
+	if len(ut.ops) == 0 {
+		return
+	}
+	us.Undos = append(us.Undos, ut)
+	us.Redos = nil
+	if us.Max > 0 && len(us.Undos) > us.Max {
+		drop := len(us.Undos) - us.Max
+		for _, dt := range us.Undos[:drop] {
+			dt.release()
+		}
+		us.Undos = append(us.Undos[:0], us.Undos[drop:]...)
+	}
+}
+
+// record adds given operation to the current transaction, or as its own
+// transaction if none is in progress.  Returns false if the operation
+// was not recorded.
+func (us *UndoStack) record(op undoOp) bool {
+	if us == nil {
+		return false
+	}
+	us.Mu.Lock()
+	defer us.Mu.Unlock()
+	if us.replaying {
+		return false
+	}
+	if us.cur != nil {
+		us.cur.ops = append(us.cur.ops, op)
+		return true
+	}
+	us.cur = &UndoTrans{Name: us.nextName, ops: []undoOp{op}}
+	us.nextName = ""
+	us.commit()
+	return true
+}
+
+// mark opens a transaction owned by k if none is in progress, and returns
+// the number of operations already recorded in the current transaction,
+// for use in rollback.
+func (us *UndoStack) mark(k Ki) int {
+	us.Mu.Lock()
+	defer us.Mu.Unlock()
+	if us.cur == nil {
+		us.cur = &UndoTrans{Name: us.nextName, owner: k}
+		us.nextName = ""
+	}
+	return len(us.cur.ops)
+}
+
+// rollback undoes and discards the operations recorded in the current
+// transaction since given mark, in reverse order.
+func (us *UndoStack) rollback(mark int) {
+	us.Mu.Lock()
+	if us.cur == nil || len(us.cur.ops) <= mark {
+		us.Mu.Unlock()
+		return
+	}
+	ops := append([]undoOp(nil), us.cur.ops[mark:]...)
+	us.cur.ops = us.cur.ops[:mark]
+	us.replaying = true
+	us.Mu.Unlock()
+
+	for i := len(ops) - 1; i >= 0; i-- {
+		tl := ops[i].node().AsNode().lockTree()
+		ops[i].undo()
+		tl.Unlock()
+	}
+
+	us.Mu.Lock()
+	us.replaying = false
+	us.Mu.Unlock()
+}
+
+// undoBegin and undoEnd are the hooks called from UpdateStart / End,
+// under the tree lock.
+func undoBegin(k Ki) {
+	if us := undoStackForLocked(k); us != nil {
+		us.begin(k)
+	}
+}
+
+func undoEnd(k Ki) {
+	if us := undoStackForLocked(k); us != nil {
+		us.end(k)
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  Operations
+
+// undoOp is one reversible mutation -- undo and redo are called under
+// the tree lock.
+type undoOp interface {
+	// node returns the node whose state is changed by the op
+	node() Ki
+
+	// undo reverses the op
+	undo()
+
+	// redo re-applies the op
+	redo()
+
+	// release is called when the op is dropped from the history
+	release()
+}
+
+// uniqNames is a snapshot of unique names of a set of nodes, which can be
+// swapped with their current unique names -- used to revert the effects
+// of UniquifyNames.
+type uniqNames map[Ki]string
+
+// uniqSnap returns a snapshot of the unique names of the children of par.
+func uniqSnap(par Ki) uniqNames {
+	if par == nil {
+		return nil
+	}
+	kids := par.AsNode().Kids
+	un := make(uniqNames, len(kids))
+	for _, k := range kids {
+		if k != nil {
+			un[k] = k.UniqueName()
+		}
+	}
+	return un
+}
+
+// with adds the current unique name of k to the snapshot, if any.
+func (un uniqNames) with(k Ki) uniqNames {
+	if un != nil {
+		un[k] = k.UniqueName()
+	}
+	return un
+}
+
+// swap sets the snapshot names and records the current ones in their place.
+func (un uniqNames) swap() {
+	for k, nm := range un {
+		un[k] = k.UniqueName()
+		k.SetUniqueName(nm)
+	}
+}
+
+// undoRemoveKid removes kid from par children, starting the search at idx,
+// with the same signaling as DeleteChildAtIndex.  If newPar is non-nil, the
+// kid is being moved back to it, and is re-parented instead of deleted.
+func undoRemoveKid(par, kid Ki, idx int, newPar Ki) {
+	kids := par.Children()
+	if kids.IsValidIndex(idx) != nil || (*kids)[idx] != kid {
+		var ok bool
+		idx, ok = kids.IndexOf(kid, idx)
+		if !ok {
+			return
+		}
+	}
+	par.SetFlag(int(ChildDeleted))
+	if kid.AsNode().Par == par {
+		if newPar != nil {
+			defer lockOther(newPar, par.AsNode().treeLock()).Unlock()
+			kid.AsNode().setParentLocked(newPar)
+		} else {
+			kid.SetFlag(int(NodeDeleted))
+			par.AsNode().treeLock().afterUnlock(func() { kid.NodeSignal().Emit(kid, int64(NodeSignalDeleting), nil) })
+			kid.AsNode().setParentLocked(nil)
+		}
+	}
+	kids.DeleteAtIndex(idx)
+	par.AsNode().addChange(Change{Type: ChangeChildDeleted, Kid: kid, Index: idx})
+	kid.AsNode().updateResetLocked()
+}
+
+// undoInsertKid inserts kid into par children at idx, optionally parenting.
+func undoInsertKid(par, kid Ki, idx int, parent bool) {
+	par.Children().Insert(kid, idx)
+	kid.ClearFlag(int(NodeDeleted))
+	if parent {
+		defer lockOther(kid, par.AsNode().treeLock()).Unlock()
+		kid.AsNode().setParentLocked(par)
+	}
+	par.SetFlag(int(ChildAdded))
+	par.AsNode().addChange(Change{Type: ChangeChildAdded, Kid: kid, Index: idx})
+}
+
+// undoChildIns records the insertion of a child -- oldPar is the previous
+// parent if the child was moved from elsewhere.
+type undoChildIns struct {
+	par    Ki
+	kid    Ki
+	idx    int
+	oldPar Ki
+	uniqs  uniqNames
+}
+
+func (op *undoChildIns) node() Ki { return op.par }
+
+func (op *undoChildIns) undo() {
+	undoRemoveKid(op.par, op.kid, op.idx, op.oldPar)
+	op.uniqs.swap()
+}
+
+func (op *undoChildIns) redo() {
+	undoInsertKid(op.par, op.kid, op.idx, true)
+	op.uniqs.swap()
+}
+
+func (op *undoChildIns) release() {}
+
+// undoChildDel records the deletion of a child.
+type undoChildDel struct {
+	par     Ki
+	kid     Ki
+	idx     int
+	wasPar  bool
+	destroy bool
+}
+
+func (op *undoChildDel) node() Ki { return op.par }
+
+func (op *undoChildDel) undo() {
+	undoInsertKid(op.par, op.kid, op.idx, op.wasPar)
+}
+
+func (op *undoChildDel) redo() {
+	undoRemoveKid(op.par, op.kid, op.idx, nil)
+}
+
+func (op *undoChildDel) release() {
+	if op.destroy && op.kid.AsNode().Par == nil && !op.kid.IsDestroyed() {
+		DelMgr.Add(op.kid)
+	}
+}
+
+// undoChildrenDel records the deletion of all children.
+type undoChildrenDel struct {
+	par     Ki
+	kids    Slice
+	destroy bool
+}
+
+func (op *undoChildrenDel) node() Ki { return op.par }
+
+func (op *undoChildrenDel) undo() {
+	kids := op.par.Children()
+	*kids = append((*kids)[:0], op.kids...)
+	for i, k := range op.kids {
+		if k != nil {
+			k.ClearFlag(int(NodeDeleted))
+			k.AsNode().setParentLocked(op.par)
+			op.par.AsNode().addChange(Change{Type: ChangeChildAdded, Kid: k, Index: i})
+		}
+	}
+	op.par.SetFlag(int(ChildAdded))
+}
+
+func (op *undoChildrenDel) redo() {
+	kids := op.par.Children()
+	op.par.SetFlag(int(ChildrenDeleted))
+	op.par.AsNode().addChange(Change{Type: ChangeChildrenDeleted})
+	for _, k := range *kids {
+		if k == nil {
+			continue
+		}
+		k.SetFlag(int(NodeDeleted))
+		op.par.AsNode().treeLock().afterUnlock(func() { k.NodeSignal().Emit(k, int64(NodeSignalDeleting), nil) })
+		k.AsNode().setParentLocked(nil)
+		k.AsNode().updateResetLocked()
+	}
+	*kids = (*kids)[:0]
+}
+
+func (op *undoChildrenDel) release() {
+	if !op.destroy {
+		return
+	}
+	for _, k := range op.kids {
+		if k != nil && k.AsNode().Par == nil && !k.IsDestroyed() {
+			DelMgr.Add(k)
+		}
+	}
+}
+
+// undoChildMove records a move (or swap) of children.
+type undoChildMove struct {
+	par  Ki
+	frm  int
+	to   int
+	swap bool
+}
+
+func (op *undoChildMove) node() Ki { return op.par }
+
+func (op *undoChildMove) undo() {
+	if op.swap {
+		op.redo()
+		return
+	}
+	op.par.Children().Move(op.to, op.frm)
+	op.par.SetFlag(int(ChildMoved))
+	op.changes(op.to, op.frm)
+}
+
+func (op *undoChildMove) redo() {
+	kids := op.par.Children()
+	if op.swap {
+		kids.Swap(op.frm, op.to)
+	} else {
+		kids.Move(op.frm, op.to)
+	}
+	op.par.SetFlag(int(ChildMoved))
+	op.changes(op.frm, op.to)
+}
+
+// changes records the changes for a move from frm to to
+func (op *undoChildMove) changes(frm, to int) {
+	kids := *op.par.Children()
+	nb := op.par.AsNode()
+	nb.addChange(Change{Type: ChangeChildMoved, Kid: kids[to], From: frm, To: to})
+	if op.swap {
+		nb.addChange(Change{Type: ChangeChildMoved, Kid: kids[frm], From: to, To: frm})
+	}
+}
+
+func (op *undoChildMove) release() {}
+
+// undoRename records a SetName, including any resulting changes to the
+// unique names of siblings.
+type undoRename struct {
+	k     Ki
+	nm    string
+	uniqs uniqNames
+}
+
+func (op *undoRename) node() Ki { return op.k }
+
+func (op *undoRename) undo() {
+	cur := op.k.Name()
+	op.k.SetNameRaw(op.nm)
+	op.nm = cur
+	op.uniqs.swap()
+	op.k.SetFlag(int(FieldUpdated))
+	op.k.AsNode().addChange(Change{Type: ChangeField, Name: "Nm"})
+}
+
+func (op *undoRename) redo() { op.undo() }
+
+func (op *undoRename) release() {}
+
+// undoField records a SetField -- val holds the value to swap in.
+type undoField struct {
+	k    Ki
+	name string
+	fv   reflect.Value
+	val  reflect.Value
+}
+
+func (op *undoField) node() Ki { return op.k }
+
+func (op *undoField) undo() {
+	cur := reflect.New(op.fv.Type()).Elem()
+	cur.Set(op.fv)
+	op.fv.Set(op.val)
+	op.val = cur
+	op.k.SetFlag(int(FieldUpdated))
+	op.k.AsNode().addChange(Change{Type: ChangeField, Name: op.name})
+}
+
+func (op *undoField) redo() { op.undo() }
+
+func (op *undoField) release() {}
+
+// undoProp records a SetProp or DeleteProp -- val, has hold the value
+// to swap in.
+type undoProp struct {
+	k   Ki
+	key string
+	val interface{}
+	has bool
+}
+
+func (op *undoProp) node() Ki { return op.k }
+
+func (op *undoProp) undo() {
+	pp := op.k.Properties()
+	cur, has := (*pp)[op.key]
+	if op.has {
+		if *pp == nil {
+			*pp = make(Props)
+		}
+		(*pp)[op.key] = op.val
+		op.k.AsNode().addChange(Change{Type: ChangeProp, Key: op.key, Old: cur, New: op.val})
+	} else {
+		delete(*pp, op.key)
+		op.k.AsNode().addChange(Change{Type: ChangeDelProp, Key: op.key, Old: cur})
+	}
+	op.val, op.has = cur, has
+	op.k.SetFlag(int(PropUpdated))
+}
+
+func (op *undoProp) redo() { op.undo() }
+
+func (op *undoProp) release() {}
+
+// undoMapKey records setting or deleting a key in a map that is not
+// otherwise recorded as part of a field -- val, has hold the value to
+// swap in.
+type undoMapKey struct {
+	k   Ki
+	m   reflect.Value
+	key reflect.Value
+	val reflect.Value
+	has bool
+}
+
+func (op *undoMapKey) node() Ki { return op.k }
+
+func (op *undoMapKey) undo() {
+	cur := op.m.MapIndex(op.key)
+	if op.has {
+		op.m.SetMapIndex(op.key, op.val)
+	} else {
+		op.m.SetMapIndex(op.key, reflect.Value{})
+	}
+	op.val, op.has = cur, cur.IsValid()
+}
+
+func (op *undoMapKey) redo() { op.undo() }
+
+func (op *undoMapKey) release() {}
+
+// recordProp records the current state of given property on k, prior to
+// it being changed.
+func (us *UndoStack) recordProp(k Ki, key string) {
+	val, has := (*k.Properties())[key]
+	us.record(&undoProp{k: k, key: key, val: val, has: has})
+}
+
+// recordField records the current value of given field value on k, prior
+// to it being changed.
+func (us *UndoStack) recordField(k Ki, name string, fv reflect.Value) {
+	val := reflect.New(fv.Type()).Elem()
+	val.Set(fv)
+	us.record(&undoField{k: k, name: name, fv: fv, val: val})
+}
+
+// snapPar returns par if recording (us != nil), for use with uniqSnap
+// to avoid the cost of the snapshot when not recording.
+func (us *UndoStack) snapPar(par Ki) Ki {
+	if us == nil {
+		return nil
+	}
+	return par
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"testing"
+)
+
+func undoTestTree() *NodeEmbed {
+	parent := NodeEmbed{}
+	parent.InitName(&parent, "par1")
+	typ := KiT_NodeEmbed
+	parent.AddNewChild(typ, "child1")
+	parent.AddNewChild(typ, "child2")
+	parent.AddNewChild(typ, "child3")
+	return &parent
+}
+
+func undoKidNames(k Ki) string {
+	nms := ""
+	for _, kid := range *k.Children() {
+		if nms != "" {
+			nms += ","
+		}
+		nms += kid.UniqueName()
+	}
+	return nms
+}
+
+func TestUndoChildren(t *testing.T) {
+	parent := undoTestTree()
+	us := NewUndoStack(parent, 0)
+	defer us.Close()
+
+	if us.CanUndo() {
+		t.Errorf("new stack should not have anything to undo")
+	}
+	start := undoKidNames(parent)
+
+	parent.AddNewChild(KiT_NodeEmbed, "child4")
+	parent.DeleteChildAtIndex(0, true)
+	parent.MoveChild(0, 2)
+	parent.Child(0).SetName("child2") // forces uniquify of child2
+
+	parent.AddNewChild(KiT_NodeEmbed, "child5")
+	parent.DeleteChildAtIndex(1, true)
+
+	end := undoKidNames(parent)
+	nundo := 0
+	for us.Undo() {
+		nundo++
+	}
+	if nundo != 6 {
+		t.Errorf("expected 6 undos, got: %v", nundo)
+	}
+	if got := undoKidNames(parent); got != start {
+		t.Errorf("undo all: expected: %v got: %v", start, got)
+	}
+	for _, kid := range parent.Kids {
+		if kid.Parent() != parent.This() || kid.IsDeleted() {
+			t.Errorf("undo all: child %v not properly restored", kid.Name())
+		}
+	}
+	for us.Redo() {
+	}
+	if got := undoKidNames(parent); got != end {
+		t.Errorf("redo all: expected: %v got: %v", end, got)
+	}
+}
+
+func TestUndoMove(t *testing.T) {
+	parent := undoTestTree()
+	other := parent.AddNewChild(KiT_NodeEmbed, "other")
+	us := NewUndoStack(parent, 0)
+	defer us.Close()
+
+	kid := parent.Child(0)
+	other.AddChild(kid)
+	if kid.Parent() != other || parent.NumChildren() != 3 {
+		t.Errorf("move: child not moved to other")
+	}
+	if !us.Undo() {
+		t.Errorf("move: nothing to undo")
+	}
+	if kid.Parent() != parent.This() || parent.Child(0) != kid || other.NumChildren() != 0 {
+		t.Errorf("undo move: child not restored to parent")
+	}
+	if us.CanUndo() {
+		t.Errorf("undo move: move should be a single transaction")
+	}
+	us.Redo()
+	if kid.Parent() != other || other.Child(0) != kid {
+		t.Errorf("redo move: child not moved to other")
+	}
+}
+
+func TestUndoTrans(t *testing.T) {
+	parent := undoTestTree()
+	us := NewUndoStack(parent, 0)
+	defer us.Close()
+
+	start := undoKidNames(parent)
+	us.Do("Add Two", func() {
+		parent.AddNewChild(KiT_NodeEmbed, "child4")
+		parent.AddNewChild(KiT_NodeEmbed, "child5")
+	})
+	if us.UndoName() != "Add Two" {
+		t.Errorf("expected UndoName Add Two, got: %v", us.UndoName())
+	}
+	updt := parent.UpdateStart()
+	parent.SwapChildren(0, 1)
+	parent.DeleteChildren(true)
+	parent.UpdateEnd(updt)
+	if len(us.Undos) != 2 {
+		t.Errorf("expected 2 transactions, got: %v", len(us.Undos))
+	}
+	if us.Undos[1].NOps() != 2 {
+		t.Errorf("expected 2 ops in update transaction, got: %v", us.Undos[1].NOps())
+	}
+	us.Undo()
+	us.Undo()
+	if got := undoKidNames(parent); got != start {
+		t.Errorf("undo trans: expected: %v got: %v", start, got)
+	}
+	if us.RedoName() != "Add Two" {
+		t.Errorf("expected RedoName Add Two, got: %v", us.RedoName())
+	}
+
+	// new mutation clears redo
+	parent.SetProp("floatprop", 2.5)
+	if us.CanRedo() {
+		t.Errorf("redo history should be cleared by new mutation")
+	}
+}
+
+func TestUndoPropsFields(t *testing.T) {
+	parent := undoTestTree()
+	us := NewUndoStack(parent, 0)
+	defer us.Close()
+
+	kid := parent.Child(1).Embed(KiT_NodeEmbed).(*NodeEmbed)
+	parent.SetProp("intprop", 42)
+	parent.SetProp("intprop", 43)
+	parent.DeleteProp("intprop")
+	kid.SetField("Mbr1", "new")
+	kid.SetField("Mbr2", 17)
+
+	us.Undo()
+	if kid.Mbr2 != 0 {
+		t.Errorf("undo field: expected 0 got: %v", kid.Mbr2)
+	}
+	us.Undo()
+	if kid.Mbr1 != "" {
+		t.Errorf("undo field: expected empty got: %v", kid.Mbr1)
+	}
+	us.Undo()
+	if v := parent.Prop("intprop"); v != 43 {
+		t.Errorf("undo delete prop: expected 43 got: %v", v)
+	}
+	us.Undo()
+	us.Undo()
+	if parent.Prop("intprop") != nil {
+		t.Errorf("undo set prop: prop should not be set")
+	}
+	us.Redo()
+	us.Redo()
+	us.Redo()
+	us.Redo()
+	if kid.Mbr1 != "new" || kid.Mbr2 != 0 {
+		t.Errorf("redo fields: got: %v %v", kid.Mbr1, kid.Mbr2)
+	}
+	if parent.Prop("intprop") != nil {
+		t.Errorf("redo delete prop: prop should not be set")
+	}
+}
+
+func TestUndoMax(t *testing.T) {
+	parent := undoTestTree()
+	us := NewUndoStack(parent, 3)
+	defer us.Close()
+
+	for i := 0; i < 5; i++ {
+		parent.SetProp("intprop", i)
+	}
+	if len(us.Undos) != 3 {
+		t.Errorf("expected 3 transactions, got: %v", len(us.Undos))
+	}
+	for us.Undo() {
+	}
+	if v := parent.Prop("intprop"); v != 1 {
+		t.Errorf("undo max: expected 1 got: %v", v)
+	}
+}
+
+func TestUndoSignal(t *testing.T) {
+	parent := undoTestTree()
+	us := NewUndoStack(parent, 0)
+	defer us.Close()
+
+	nupdt := 0
+	parent.NodeSignal().Connect(parent.This(), func(r, s Ki, sig int64, d interface{}) {
+		if sig == int64(NodeSignalUpdated) {
+			nupdt++
+		}
+	})
+	parent.AddNewChild(KiT_NodeEmbed, "child4")
+	nupdt = 0
+	us.Undo()
+	if nupdt != 1 {
+		t.Errorf("undo: expected 1 updated signal, got: %v", nupdt)
+	}
+	if parent.NumChildren() != 3 {
+		t.Errorf("undo: expected 3 children, got: %v", parent.NumChildren())
+	}
+}
+
+func TestUndoSubtree(t *testing.T) {
+	parent := undoTestTree()
+	child2 := parent.ChildByName("child2", 0)
+	us := NewUndoStack(child2, 0)
+	defer us.Close()
+	if UndoStackFor(parent) != nil || UndoStackFor(child2) != us {
+		t.Errorf("UndoStackFor wrong stack for subtree")
+	}
+
+	gk := child2.AddNewChild(KiT_NodeEmbed, "gkid")
+	if UndoStackFor(gk) != us {
+		t.Errorf("UndoStackFor should find stack of parent")
+	}
+	gk.SetProp("intprop", 42)
+	parent.AddNewChild(KiT_NodeEmbed, "child4") // outside subtree
+	if len(us.Undos) != 2 {
+		t.Fatalf("expected 2 undo transactions, got: %v", len(us.Undos))
+	}
+	for us.Undo() {
+	}
+	if child2.NumChildren() != 0 || parent.NumChildren() != 4 {
+		t.Errorf("undo subtree: %v %v", undoKidNames(child2), undoKidNames(parent))
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLRoundTrip(t *testing.T) {
+	root := binTestTree()
+	root.Child(2).SetName("child1") // non-unique name
+	c2 := root.ChildByName("child2", 0)
+	c2.SetProp("map", map[string]interface{}{"a": true})
+	c2.SetProp("strs", []string{"x", "y"})
+	c2.SetProp("text", "  spaced\nlines <&> ")
+	c2.SetProp("nil", nil)
+	root.Embed(KiT_NodeField).(*NodeField).Field1.SetProp("fieldprop", "fp")
+
+	var buf bytes.Buffer
+	if err := root.WriteXML(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	nwnd := NodeField{}
+	nwnd.InitName(&nwnd, "")
+	if err := nwnd.ReadXML(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if es, err := Diff(root, nwnd.This()); err != nil || len(es) != 0 {
+		t.Errorf("round trip diffs: %v\n%v", err, es)
+	}
+	nc2 := nwnd.ChildByName("child2", 0)
+	// all but strs keep their exact types
+	want := Props{}
+	for key, val := range *c2.Properties() {
+		want[key] = val
+	}
+	want["strs"] = []interface{}{"x", "y"}
+	if !reflect.DeepEqual(*nc2.Properties(), want) {
+		t.Errorf("props not equal:\n%v\nvs.\n%v", want, nc2.Properties())
+	}
+	if nwnd.Field1.Prop("fieldprop") != "fp" || nwnd.Field1.NumChildren() != 1 {
+		t.Errorf("Ki field not restored")
+	}
+	if nwnd.Child(2).Name() != "child1" || nwnd.Child(2).UniqueName() != "child1_002" {
+		t.Errorf("names not restored: %v %v", nwnd.Child(2).Name(), nwnd.Child(2).UniqueName())
+	}
+	if nc2.Child(0).Parent() != nc2 {
+		t.Errorf("parents not set by UnmarshalPost")
+	}
+
+	// ReadXML onto an existing tree keeps the existing nodes, and does not
+	// add to them
+	tree := diffTestTree()
+	oc2 := tree.ChildByName("child2", 0)
+	if err := tree.ReadXML(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if tree.ChildByName("child2", 0) != oc2 || tree.NumChildren() != root.NumChildren() {
+		t.Errorf("ReadXML should configure existing children")
+	}
+	if es, err := Diff(root, tree); err != nil || len(es) != 0 {
+		t.Errorf("ReadXML diffs: %v\n%v", err, es)
+	}
+}
+
+func TestXMLJSONRoundTrip(t *testing.T) {
+	var jb1 bytes.Buffer
+	if err := binTestTree().WriteJSON(&jb1, true); err != nil {
+		t.Fatal(err)
+	}
+	jroot, err := ReadNewJSON(bytes.NewReader(jb1.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var xb bytes.Buffer
+	if err := jroot.WriteXML(&xb, false); err != nil {
+		t.Fatal(err)
+	}
+	xroot := NewOfType(jroot.Type())
+	xroot.Init(xroot)
+	if err := xroot.ReadXML(bytes.NewReader(xb.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	var jb2 bytes.Buffer
+	xroot.WriteJSON(&jb2, true)
+	if jb1.String() != jb2.String() {
+		t.Errorf("JSON not same after XML round trip:\n%v\nvs.\n%v", jb1.String(), jb2.String())
+	}
+}
+
+func TestXMLErrors(t *testing.T) {
+	var buf bytes.Buffer
+	binTestTree().WriteXML(&buf, false)
+	xs := buf.String()
+	for _, bad := range []string{
+		xs[:len(xs)/2],
+		strings.Replace(xs, `type="ki.EditOps">EditMove`, `type="ki.EditOps">NoSuchOp`, 1),
+		strings.Replace(xs, `type="float64">2.5`, `type="float64">abc`, 1),
+		strings.Replace(xs, `type="float64"`, `type="ki.NoSuchType"`, 1),
+		strings.Replace(xs, `<Type name="child2">ki.NodeEmbed`, `<Type name="child2">ki.NoSuchType`, 1),
+		strings.Replace(xs, `<N>4</N>`, `<N>x</N>`, 1),
+	} {
+		if bad == xs {
+			t.Fatalf("test replacement not found in:\n%v", xs)
+		}
+		tree := NodeField{}
+		tree.InitName(&tree, "")
+		if err := tree.ReadXML(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for:\n%v", bad)
+		}
+	}
+	root := binTestTree()
+	root.SetProp("complex", complex(1, 2))
+	if err := root.WriteXML(&buf, false); err == nil {
+		t.Errorf("expected error for prop that cannot be encoded")
+	}
+}
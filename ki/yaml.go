@@ -0,0 +1,549 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/goki/ki/kit"
+	"gopkg.in/yaml.v3"
+)
+
+// The YAML format is a natural nested representation that is easy to write
+// by hand, e.g., for configuration and test fixtures.  Each node is a
+// mapping with these keys (all optional except type for kids):
+//
+//	type: ki.NodeEmbed    # kit.Types name of the node type
+//	name: child1
+//	uniqueName: child1_1  # only if different from name
+//	id: 1234              # stable ID, only if set -- see NodeIDs
+//	fields:               # own fields of the node type, excluding Node
+//	  Mbr1: a string
+//	links:                # signal connections made with Link
+//	  - Signal: NodeSig
+//	    Recv: /root/child2
+//	    Func: mypkg.MyRecvFunc
+//	    Pri: 0
+//	props:
+//	  intprop: 42
+//	  enumprop: !ki.EditOps EditMove
+//	kids:
+//	  - type: ki.NodeEmbed
+//	    name: subchild1
+//
+// Field values use the same encoding as JSON, except that enums use their
+// kit.Enums names, and Ki fields are nested node mappings (without type).
+// Prop values use plain YAML values, with Props for mappings, and the
+// kit.Types name as a YAML tag for other types (enums, structs, PropSlice).
+
+// WriteYAML writes the tree to an io.Writer in the YAML format, including
+// the type of the root node, so the file can be loaded de-novo using
+// ReadNewYAML.
+func (n *Node) WriteYAML(writer io.Writer) error {
+	err := n.ThisCheck()
+	if err != nil {
+		return err
+	}
+	yn, err := yamlNode(n.This(), true)
+	if err == nil {
+		enc := yaml.NewEncoder(writer)
+		enc.SetIndent(2)
+		err = enc.Encode(yn)
+		if err == nil {
+			err = enc.Close()
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// ReadYAML reads the tree starting at this node from the YAML format.
+// Uses ConfigureChildren to minimize changes from current tree relative to
+// loading one, and calls UnmarshalPost to recover pointers from paths.
+func (n *Node) ReadYAML(reader io.Reader) error {
+	err := n.ThisCheck()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	var doc yaml.Node
+	if err = yaml.NewDecoder(reader).Decode(&doc); err != nil {
+		log.Println(err)
+		return err
+	}
+	if us := UndoStackFor(n.This()); us != nil {
+		defer us.Reset()
+	}
+	updt := n.UpdateStart()
+	err = yamlDecodeNode(n.This(), yamlContent(&doc))
+	if err == nil {
+		err = n.unmarshalPost()
+	}
+	n.SetFlag(int(ChildAdded)) // this might not be set..
+	n.UpdateEnd(updt)
+	return err
+}
+
+// ReadNewYAML reads a new Ki tree from the YAML format, using the type of
+// the root node to create an object of the proper type.
+func ReadNewYAML(reader io.Reader) (Ki, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(reader).Decode(&doc); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	yn := yamlContent(&doc)
+	typ, err := yamlNodeType(yn)
+	if err != nil {
+		return nil, err
+	}
+	root := NewOfType(typ)
+	root.Init(root)
+
+	updt := root.UpdateStart()
+	err = yamlDecodeNode(root, yn)
+	if err == nil {
+		err = root.AsNode().unmarshalPost()
+	}
+	root.SetFlag(int(ChildAdded)) // this might not be set..
+	root.UpdateEnd(updt)
+	return root, err
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  Encoding
+
+// yamlStr returns a string scalar node
+func yamlStr(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// yamlAdd adds a key, value pair to a mapping node
+func yamlAdd(m *yaml.Node, key string, val *yaml.Node) {
+	m.Content = append(m.Content, yamlStr(key), val)
+}
+
+// yamlNode returns the mapping for given Ki node, including the type if
+// withType.
+func yamlNode(k Ki, withType bool) (*yaml.Node, error) {
+	m := &yaml.Node{Kind: yaml.MappingNode}
+	nb := k.AsNode()
+	if withType {
+		yamlAdd(m, "type", yamlStr(kit.Types.TypeName(k.Type())))
+	}
+	yamlAdd(m, "name", yamlStr(nb.Nm))
+	if nb.UniqueNm != nb.Nm {
+		yamlAdd(m, "uniqueName", yamlStr(nb.UniqueNm))
+	}
+	if id := nb.ID(); id != 0 {
+		yamlAdd(m, "id", &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.FormatUint(id, 10)})
+	}
+	flds := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range kit.FlatFields(k.Type()) {
+		if f.PkgPath != "" || nodeFieldNames[f.Name] || f.Tag.Get("json") == "-" {
+			continue
+		}
+		fv := kit.FlatFieldValueByName(k, f.Name)
+		var fn *yaml.Node
+		var err error
+		if fk, ok := fv.Addr().Interface().(Ki); ok {
+			fn, err = yamlNode(fk, false)
+		} else if binIsEnum(f.Type) {
+			fn = yamlStr(kit.EnumIfaceToString(fv.Interface()))
+		} else {
+			fn, err = yamlFromJSON(fv.Interface())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ki.WriteYAML: field %v of %v: %v", f.Name, k.Path(), err)
+		}
+		yamlAdd(flds, f.Name, fn)
+	}
+	if len(flds.Content) > 0 {
+		yamlAdd(m, "fields", flds)
+	}
+	if len(nb.SigLinks) > 0 {
+		ln, err := yamlFromJSON(nb.SigLinks)
+		if err != nil {
+			return nil, fmt.Errorf("ki.WriteYAML: links of %v: %v", k.Path(), err)
+		}
+		yamlAdd(m, "links", ln)
+	}
+	if len(nb.Props) > 0 {
+		pn, err := yamlPropNode(nb.Props)
+		if err != nil {
+			return nil, fmt.Errorf("ki.WriteYAML: props of %v: %v", k.Path(), err)
+		}
+		yamlAdd(m, "props", pn)
+	}
+	if len(nb.Kids) > 0 {
+		kn := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, kid := range nb.Kids {
+			kdn, err := yamlNode(kid, true)
+			if err != nil {
+				return nil, err
+			}
+			kn.Content = append(kn.Content, kdn)
+		}
+		yamlAdd(m, "kids", kn)
+	}
+	return m, nil
+}
+
+// yamlFromJSON returns the YAML node for the JSON encoding of given value
+func yamlFromJSON(v interface{}) (*yaml.Node, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	yn := yamlContent(&doc)
+	yamlClearStyle(yn)
+	return yn, nil
+}
+
+// yamlClearStyle clears the flow and quoting styles from parsed JSON, so
+// values are written in the usual YAML block style
+func yamlClearStyle(yn *yaml.Node) {
+	yn.Style = 0
+	for _, c := range yn.Content {
+		yamlClearStyle(c)
+	}
+}
+
+// yamlPropNode returns the YAML node for given prop value
+func yamlPropNode(v interface{}) (*yaml.Node, error) {
+	switch pv := v.(type) {
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	case Props:
+		return yamlPropMap(pv)
+	case map[string]interface{}:
+		return yamlPropMap(pv)
+	case PropSlice:
+		sn := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!" + kit.Types.TypeName(KiT_PropSlice)}
+		for _, ps := range pv {
+			vn, err := yamlPropNode(ps.Value)
+			if err != nil {
+				return nil, err
+			}
+			m := &yaml.Node{Kind: yaml.MappingNode}
+			yamlAdd(m, ps.Name, vn)
+			sn.Content = append(sn.Content, m)
+		}
+		return sn, nil
+	case []interface{}:
+		sn := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, ev := range pv {
+			vn, err := yamlPropNode(ev)
+			if err != nil {
+				return nil, err
+			}
+			sn.Content = append(sn.Content, vn)
+		}
+		return sn, nil
+	}
+	typ := reflect.TypeOf(v)
+	if binIsEnum(typ) {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!" + kit.Types.TypeName(typ), Value: kit.EnumIfaceToString(v)}, nil
+	}
+	if binBasicTypes[typ.Kind()] == typ {
+		yn := &yaml.Node{}
+		err := yn.Encode(v)
+		return yn, err
+	}
+	yn, err := yamlFromJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	if tn := kit.Types.TypeName(typ); kit.Types.Type(tn) == typ {
+		yn.Tag = "!" + tn
+	}
+	return yn, nil
+}
+
+// yamlPropMap returns the mapping for given props, in sorted key order
+func yamlPropMap(p map[string]interface{}) (*yaml.Node, error) {
+	keys := make([]string, 0, len(p))
+	for key := range p {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	m := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range keys {
+		vn, err := yamlPropNode(p[key])
+		if err != nil {
+			return nil, fmt.Errorf("prop %v: %v", key, err)
+		}
+		yamlAdd(m, key, vn)
+	}
+	return m, nil
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  Decoding
+
+// yamlContent returns the content of a document node, and resolves aliases
+func yamlContent(yn *yaml.Node) *yaml.Node {
+	for {
+		switch {
+		case yn.Kind == yaml.DocumentNode && len(yn.Content) > 0:
+			yn = yn.Content[0]
+		case yn.Kind == yaml.AliasNode && yn.Alias != nil:
+			yn = yn.Alias
+		default:
+			return yn
+		}
+	}
+}
+
+// yamlErrorf returns an error at the line of given YAML node
+func yamlErrorf(yn *yaml.Node, format string, args ...interface{}) error {
+	return fmt.Errorf("ki.ReadYAML: line %d: %v", yn.Line, fmt.Sprintf(format, args...))
+}
+
+// yamlMapValue returns the value for given key in a mapping node, or nil
+func yamlMapValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return yamlContent(m.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// yamlNodeType returns the type of the node mapping from its type key
+func yamlNodeType(yn *yaml.Node) (reflect.Type, error) {
+	if yn.Kind != yaml.MappingNode {
+		return nil, yamlErrorf(yn, "node must be a mapping")
+	}
+	tn := yamlMapValue(yn, "type")
+	if tn == nil {
+		return nil, yamlErrorf(yn, "node has no type")
+	}
+	typ := kit.Types.Type(tn.Value)
+	if typ == nil || !IsKi(typ) {
+		return nil, yamlErrorf(tn, "kit.Types Ki type name not found: %v", tn.Value)
+	}
+	return typ, nil
+}
+
+// yamlDecodeNode sets the Ki node from given node mapping
+func yamlDecodeNode(k Ki, yn *yaml.Node) error {
+	if yn.Kind != yaml.MappingNode {
+		return yamlErrorf(yn, "node must be a mapping")
+	}
+	nb := k.AsNode()
+	unm := ""
+	for i := 0; i+1 < len(yn.Content); i += 2 {
+		kn := yn.Content[i]
+		vn := yamlContent(yn.Content[i+1])
+		var err error
+		switch kn.Value {
+		case "type": // handled by parent
+		case "name":
+			nb.Nm = vn.Value
+		case "uniqueName":
+			unm = vn.Value
+		case "id":
+			var id uint64
+			id, err = strconv.ParseUint(vn.Value, 10, 64)
+			if err != nil {
+				return yamlErrorf(vn, "id must be an unsigned integer: %v", vn.Value)
+			}
+			nb.Uid = id
+		case "fields":
+			err = yamlDecodeFields(k, vn)
+		case "links":
+			err = yamlToJSON(vn, &nb.SigLinks)
+		case "props":
+			var pv interface{}
+			pv, err = yamlPropValue(vn)
+			if err == nil {
+				p, ok := pv.(Props)
+				if !ok && pv != nil {
+					return yamlErrorf(vn, "props must be a mapping")
+				}
+				nb.Props = p
+			}
+		case "kids":
+			err = yamlDecodeKids(k, vn)
+		default:
+			return yamlErrorf(kn, "unexpected node key: %v", kn.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if unm == "" {
+		unm = nb.Nm
+	}
+	nb.UniqueNm = unm
+	return nil
+}
+
+// yamlDecodeFields sets the fields of the Ki node from the fields mapping
+func yamlDecodeFields(k Ki, yn *yaml.Node) error {
+	if yn.Kind != yaml.MappingNode {
+		return yamlErrorf(yn, "fields must be a mapping")
+	}
+	for i := 0; i+1 < len(yn.Content); i += 2 {
+		kn := yn.Content[i]
+		vn := yamlContent(yn.Content[i+1])
+		f, ok := kit.FlatFieldByName(k.Type(), kn.Value)
+		if !ok || f.PkgPath != "" || nodeFieldNames[f.Name] || f.Tag.Get("json") == "-" {
+			return yamlErrorf(kn, "type %v has no field: %v", kit.Types.TypeName(k.Type()), kn.Value)
+		}
+		fv := kit.FlatFieldValueByName(k, f.Name)
+		if fk, ok := fv.Addr().Interface().(Ki); ok {
+			if err := yamlDecodeNode(fk, vn); err != nil {
+				return err
+			}
+			continue
+		}
+		if binIsEnum(f.Type) {
+			if err := enumFromString(fv.Addr(), vn.Value); err != nil {
+				return yamlErrorf(vn, "field %v: %v", f.Name, err)
+			}
+			continue
+		}
+		if err := yamlToJSON(vn, fv.Addr().Interface()); err != nil {
+			return yamlErrorf(vn, "field %v: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// yamlToJSON sets the value pointed to by ptr from given YAML node, using
+// the JSON encoding of the node
+func yamlToJSON(yn *yaml.Node, ptr interface{}) error {
+	var v interface{}
+	if err := yn.Decode(&v); err != nil {
+		return err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, ptr)
+}
+
+// yamlDecodeKids configures the children of the Ki node from the kids
+// sequence, and then sets each child
+func yamlDecodeKids(k Ki, yn *yaml.Node) error {
+	if yn.Kind == yaml.ScalarNode && yn.ShortTag() == "!!null" {
+		k.AsNode().Kids = nil
+		return nil
+	}
+	if yn.Kind != yaml.SequenceNode {
+		return yamlErrorf(yn, "kids must be a sequence")
+	}
+	tnl := make(kit.TypeAndNameList, len(yn.Content))
+	for i, c := range yn.Content {
+		c = yamlContent(c)
+		typ, err := yamlNodeType(c)
+		if err != nil {
+			return err
+		}
+		tnl[i].Type = typ
+		if un := yamlMapValue(c, "uniqueName"); un != nil {
+			tnl[i].Name = un.Value
+		} else if nm := yamlMapValue(c, "name"); nm != nil {
+			tnl[i].Name = nm.Value
+		}
+	}
+	sl := k.Children()
+	sl.Config(nil, tnl, true) // true = uniq names
+	for i, kid := range *sl {
+		if err := yamlDecodeNode(kid, yamlContent(yn.Content[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlPropValue returns the prop value for given YAML node
+func yamlPropValue(yn *yaml.Node) (interface{}, error) {
+	yn = yamlContent(yn)
+	tag := yn.ShortTag()
+	if len(tag) > 1 && tag[0] == '!' && tag[1] != '!' {
+		return yamlTypedPropValue(yn, tag[1:])
+	}
+	switch yn.Kind {
+	case yaml.MappingNode:
+		p := make(Props, len(yn.Content)/2)
+		for i := 0; i+1 < len(yn.Content); i += 2 {
+			pv, err := yamlPropValue(yn.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			p[yn.Content[i].Value] = pv
+		}
+		return p, nil
+	case yaml.SequenceNode:
+		l := make([]interface{}, len(yn.Content))
+		for i, c := range yn.Content {
+			pv, err := yamlPropValue(c)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = pv
+		}
+		return l, nil
+	}
+	var v interface{}
+	if err := yn.Decode(&v); err != nil {
+		return nil, yamlErrorf(yn, "%v", err)
+	}
+	return v, nil
+}
+
+// yamlTypedPropValue returns the prop value of given type name
+func yamlTypedPropValue(yn *yaml.Node, tn string) (interface{}, error) {
+	typ := binTypeByName(tn)
+	if typ == nil {
+		return nil, yamlErrorf(yn, "kit.Types type name not found: %v", tn)
+	}
+	if typ == KiT_PropSlice {
+		if yn.Kind != yaml.SequenceNode {
+			return nil, yamlErrorf(yn, "%v must be a sequence", tn)
+		}
+		ps := make(PropSlice, len(yn.Content))
+		for i, c := range yn.Content {
+			c = yamlContent(c)
+			if c.Kind != yaml.MappingNode || len(c.Content) != 2 {
+				return nil, yamlErrorf(c, "%v element must be a single name: value mapping", tn)
+			}
+			pv, err := yamlPropValue(c.Content[1])
+			if err != nil {
+				return nil, err
+			}
+			ps[i] = PropStruct{Name: c.Content[0].Value, Value: pv}
+		}
+		return ps, nil
+	}
+	pv := reflect.New(typ)
+	if binIsEnum(typ) {
+		if err := enumFromString(pv, yn.Value); err != nil {
+			return nil, yamlErrorf(yn, "%v", err)
+		}
+		return pv.Elem().Interface(), nil
+	}
+	if err := yamlToJSON(yn, pv.Interface()); err != nil {
+		return nil, yamlErrorf(yn, "%v", err)
+	}
+	return pv.Elem().Interface(), nil
+}
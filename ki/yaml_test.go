@@ -0,0 +1,127 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ki
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLRoundTrip(t *testing.T) {
+	root := binTestTree()
+	root.Child(2).SetName("child1") // non-unique name
+	var buf bytes.Buffer
+	err := root.WriteYAML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ys := buf.String()
+	for _, want := range []string{"type: ki.NodeField\n", "enumprop: !ki.EditOps EditMove", "slice: !ki.PropSlice", "uniqueName: child1_002"} {
+		if !strings.Contains(ys, want) {
+			t.Errorf("YAML output missing %q:\n%v", want, ys)
+		}
+	}
+
+	nwnd, err := ReadNewYAML(strings.NewReader(ys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if es, err := Diff(root, nwnd); err != nil || len(es) != 0 {
+		t.Errorf("round trip diffs: %v\n%v", err, es)
+	}
+	nc2 := nwnd.Child(1)
+	if nc2.Prop("enumprop") != EditMove || nc2.Prop("intprop") != 42 || nc2.Prop("blank") != (BlankProp{}) {
+		t.Errorf("typed props not restored: %v", nc2.Properties())
+	}
+	if ps, ok := nc2.Prop("slice").(PropSlice); !ok || !reflect.DeepEqual(ps[1].Value, Props{"x": "y"}) {
+		t.Errorf("PropSlice not restored: %v", nc2.Prop("slice"))
+	}
+	if nwnd.Child(2).Name() != "child1" || nwnd.Child(2).UniqueName() != "child1_002" {
+		t.Errorf("names not restored: %v %v", nwnd.Child(2).Name(), nwnd.Child(2).UniqueName())
+	}
+	if nwnd.Child(1).Child(0).Parent() != nwnd.Child(1) {
+		t.Errorf("parents not set by UnmarshalPost")
+	}
+
+	// same as JSON
+	var jb1, jb2 bytes.Buffer
+	root.WriteJSON(&jb1, true)
+	nwnd.WriteJSON(&jb2, true)
+	if jb1.String() != jb2.String() {
+		t.Errorf("JSON not same after YAML round trip:\n%v\nvs.\n%v", jb1.String(), jb2.String())
+	}
+
+	// ReadYAML onto an existing tree keeps the existing nodes
+	tree := diffTestTree()
+	oc2 := tree.ChildByName("child2", 0)
+	err = tree.ReadYAML(strings.NewReader(ys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.ChildByName("child2", 0) != oc2 {
+		t.Errorf("ReadYAML should configure existing children")
+	}
+	if es, err := Diff(root, tree); err != nil || len(es) != 0 {
+		t.Errorf("ReadYAML diffs: %v\n%v", err, es)
+	}
+}
+
+func TestYAMLHandWritten(t *testing.T) {
+	ys := `
+type: ki.NodeField
+name: root
+fields:
+  Mbr1: top
+  Field1:
+    name: Field1
+    fields: {Mbr2: 3}
+kids:
+  - type: ki.NodeEmbed
+    name: a
+    props:
+      color: red
+      width: 2.5
+      flags: [1, two]
+  - type: ki.NodeField2
+    name: b
+    fields:
+      Field2:
+        fields:
+          Mbr1: deep
+    kids:
+      - {type: ki.NodeEmbed, name: c}
+`
+	root, err := ReadNewYAML(strings.NewReader(ys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nf := root.(*NodeField)
+	if nf.Mbr1 != "top" || nf.Field1.Mbr2 != 3 || root.NumChildren() != 2 {
+		t.Errorf("fields not loaded: %v %v", nf.Mbr1, nf.Field1.Mbr2)
+	}
+	if root.Child(0).Prop("color") != "red" || root.Child(0).Prop("width") != 2.5 {
+		t.Errorf("props not loaded: %v", root.Child(0).Properties())
+	}
+	b := root.Child(1).(*NodeField2)
+	if b.Field2.Mbr1 != "deep" || b.Child(0).Name() != "c" || b.Child(0).Parent() != b {
+		t.Errorf("nested node not loaded")
+	}
+
+	for _, bad := range []string{
+		"type: ki.NodeField\nbogus: 1\n",
+		"type: ki.NodeField\nfields: {NoField: 1}\n",
+		"type: ki.NodeField\nfields: {Mbr2: abc}\n",
+		"type: ki.NodeField\nkids: [{name: a}]\n",
+		"type: ki.NoSuchType\n",
+		"type: ki.NodeField\nprops: {e: !ki.NoSuchType 1}\n",
+		"type: ki.NodeField\nprops: {e: !ki.EditOps NoSuchOp}\n",
+	} {
+		if _, err := ReadNewYAML(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for:\n%v", bad)
+		}
+	}
+}
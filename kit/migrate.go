@@ -0,0 +1,111 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kit
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MigrateFunc upgrades the raw decoded representation of a saved object of a
+// given type from one version of the type to the next, in place.  The raw
+// representation maps field names to values as decoded by encoding/json into
+// an interface{}: string, float64, bool, nil, []interface{} or
+// map[string]interface{} -- see the ki package for how children are included.
+type MigrateFunc func(raw map[string]interface{}) error
+
+// Migration is a registered migration of a type from version From to
+// version From+1
+type Migration struct {
+	From int
+	Func MigrateFunc
+}
+
+// AddMigration registers a migration of the saved representation of given
+// type from fromVersion to fromVersion+1 -- the current version of the type
+// is then one more than the highest fromVersion.  Types without any
+// migrations are at version 0, and migrations must be added for each version
+// starting from 0.  Returns the type, so it can be used in the same kind of
+// global variable expression as AddType, e.g.,
+//
+//	var _ = kit.Types.AddMigration(KiT_MyType, 0, func(raw map[string]interface{}) error {
+//		raw["NewName"] = raw["OldName"]
+//		return nil
+//	})
+func (tr *TypeRegistry) AddMigration(typ reflect.Type, fromVersion int, fun MigrateFunc) reflect.Type {
+	lnm := LongTypeName(typ)
+	TypesMu.Lock()
+	defer TypesMu.Unlock()
+	if tr.Types == nil {
+		tr.init()
+	}
+	ms := tr.Migrations[lnm]
+	idx := sort.Search(len(ms), func(i int) bool { return ms[i].From >= fromVersion })
+	if idx < len(ms) && ms[idx].From == fromVersion {
+		ms[idx].Func = fun
+	} else {
+		ms = append(ms, Migration{})
+		copy(ms[idx+1:], ms[idx:])
+		ms[idx] = Migration{From: fromVersion, Func: fun}
+	}
+	tr.Migrations[lnm] = ms
+	return typ
+}
+
+// Version returns the current version of given type, which is one more
+// than the last registered migration, or 0 if there are none.
+func (tr *TypeRegistry) Version(typ reflect.Type) int {
+	TypesMu.RLock()
+	defer TypesMu.RUnlock()
+	ms := tr.Migrations[LongTypeName(typ)]
+	if len(ms) == 0 {
+		return 0
+	}
+	return ms[len(ms)-1].From + 1
+}
+
+// Versions returns the current versions of all types that have migrations,
+// keyed by the short type name, as used in saved files.
+func (tr *TypeRegistry) Versions() map[string]int {
+	TypesMu.RLock()
+	defer TypesMu.RUnlock()
+	vers := make(map[string]int, len(tr.Migrations))
+	for lnm, ms := range tr.Migrations {
+		if len(ms) == 0 {
+			continue
+		}
+		snm, ok := tr.ShortNames[lnm]
+		if !ok {
+			continue
+		}
+		vers[snm] = ms[len(ms)-1].From + 1
+	}
+	return vers
+}
+
+// Migrate applies the migrations of given type to the raw representation of
+// an object saved at fromVersion, returning the resulting version (the
+// current version of the type), or an error if a migration fails or is
+// missing.
+func (tr *TypeRegistry) Migrate(typ reflect.Type, fromVersion int, raw map[string]interface{}) (int, error) {
+	TypesMu.RLock()
+	ms := tr.Migrations[LongTypeName(typ)]
+	TypesMu.RUnlock()
+	ver := fromVersion
+	for _, m := range ms {
+		if m.From < ver {
+			continue
+		}
+		if m.From > ver {
+			return ver, fmt.Errorf("kit.Migrate: type %v has no migration from version %d", ShortTypeName(typ), ver)
+		}
+		if err := m.Func(raw); err != nil {
+			return ver, fmt.Errorf("kit.Migrate: type %v from version %d: %v", ShortTypeName(typ), ver, err)
+		}
+		ver++
+	}
+	return ver, nil
+}
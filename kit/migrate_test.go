@@ -0,0 +1,66 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	tr := &TypeRegistry{}
+	typ := tr.AddType(&A{}, nil)
+	if tr.Version(typ) != 0 || len(tr.Versions()) != 0 {
+		t.Errorf("type without migrations should be at version 0")
+	}
+	// add out of order
+	tr.AddMigration(typ, 1, func(raw map[string]interface{}) error {
+		raw["Mbr2"] = raw["Mbr2"].(float64) * 2
+		return nil
+	})
+	tr.AddMigration(typ, 0, func(raw map[string]interface{}) error {
+		raw["Mbr1"] = raw["Name"]
+		delete(raw, "Name")
+		return nil
+	})
+	if v := tr.Version(typ); v != 2 {
+		t.Errorf("Version: expected 2, got %d", v)
+	}
+	if vers := tr.Versions(); len(vers) != 1 || vers["kit.A"] != 2 {
+		t.Errorf("Versions: expected map[kit.A:2], got %v", vers)
+	}
+
+	raw := map[string]interface{}{"Name": "a", "Mbr2": 2.0}
+	to, err := tr.Migrate(typ, 0, raw)
+	if err != nil || to != 2 {
+		t.Fatalf("Migrate: %v %v", to, err)
+	}
+	if fmt.Sprint(raw) != "map[Mbr1:a Mbr2:4]" {
+		t.Errorf("Migrate: got %v", raw)
+	}
+
+	raw = map[string]interface{}{"Mbr2": 2.0}
+	if to, err := tr.Migrate(typ, 1, raw); err != nil || to != 2 || raw["Mbr2"] != 4.0 {
+		t.Errorf("Migrate from 1: %v %v %v", to, err, raw)
+	}
+	if to, err := tr.Migrate(typ, 2, raw); err != nil || to != 2 || raw["Mbr2"] != 4.0 {
+		t.Errorf("Migrate from current version should do nothing: %v %v %v", to, err, raw)
+	}
+
+	// replace and fail
+	tr.AddMigration(typ, 1, func(raw map[string]interface{}) error {
+		return fmt.Errorf("bad Mbr2")
+	})
+	if _, err := tr.Migrate(typ, 1, raw); err == nil || !strings.Contains(err.Error(), "bad Mbr2") {
+		t.Errorf("expected error from migration, got: %v", err)
+	}
+
+	// gap
+	tr.AddMigration(typ, 3, func(raw map[string]interface{}) error { return nil })
+	if _, err := tr.Migrate(typ, 2, raw); err == nil {
+		t.Errorf("expected error for missing migration from version 2")
+	}
+}
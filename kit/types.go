@@ -30,6 +30,9 @@
 // useful in more lax user-interface contexts where "common sense" conversions
 // between strings, numbers etc are useful
 //
+// * migrate.go: kit.TypeRegistry.AddMigration registers migrations for
+// upgrading the saved representation of a type from one version to the next.
+//
 // * embeds.go: various functions for managing embedded struct types, e.g.,
 // determining if a given type embeds another type (directly or indirectly),
 // and iterating over fields to flatten the otherwise nested nature of the
@@ -93,6 +96,11 @@ type TypeRegistry struct {
 	// Insts contain an instance of each type (the one passed during AddType)
 	// The key here is the long, full type name.
 	Insts map[string]interface{}
+
+	// Migrations are the migrations for upgrading saved versions of each
+	// type, in order of version -- see AddMigration.  The key here is the
+	// long, full type name.
+	Migrations map[string][]Migration
 }
 
 // Types is master registry of types that embed Ki Nodes
@@ -117,10 +125,10 @@ func ShortTypeName(typ reflect.Type) string {
 	return path.Base(typ.PkgPath()) + "." + typ.Name()
 }
 
-// TypesMu protects updating of the type registry maps -- main Addtype etc all
-// happens at startup and does not need protection, but property access does.
-// use RLock for read-access to properties, and Lock for write access when
-// adding or changing key / value.
+// TypesMu protects updating of the type registry maps -- AddType takes the
+// write lock, as types can also be added after startup (e.g., by plugins).
+// use RLock for read-access to properties and iterating over the maps, and
+// Lock for write access when adding or changing key / value.
 var TypesMu sync.RWMutex
 
 // AddType adds a given type to the registry -- requires an empty object to
@@ -130,10 +138,16 @@ var TypesMu sync.RWMutex
 // view-specific properties etc -- these props MUST be specific to this type
 // as they are used directly, not copied!!
 func (tr *TypeRegistry) AddType(obj interface{}, props map[string]interface{}) reflect.Type {
+	TypesMu.Lock()
+	defer TypesMu.Unlock()
 	if tr.Types == nil {
-		tr.Init()
+		tr.init()
 	}
+	return tr.addType(obj, props)
+}
 
+// addType adds a given type to the registry, under the TypesMu lock
+func (tr *TypeRegistry) addType(obj interface{}, props map[string]interface{}) reflect.Type {
 	typ := reflect.TypeOf(obj).Elem()
 	lnm := LongTypeName(typ)
 	snm := ShortTypeName(typ)
@@ -184,10 +198,13 @@ func (tr *TypeRegistry) InheritTypeProps(typ reflect.Type) bool {
 // It uses a map for fast results.
 func (tr *TypeRegistry) TypeName(typ reflect.Type) string {
 	lnm := LongTypeName(typ)
-	if snm, ok := tr.ShortNames[lnm]; ok {
+	TypesMu.RLock()
+	snm, ok := tr.ShortNames[lnm]
+	TypesMu.RUnlock()
+	if ok {
 		return snm
 	}
-	snm := ShortTypeName(typ)
+	snm = ShortTypeName(typ)
 	TypesMu.Lock()
 	tr.ShortNames[lnm] = snm
 	TypesMu.Unlock()
@@ -198,6 +215,8 @@ func (tr *TypeRegistry) TypeName(typ reflect.Type) string {
 // (package directory + "." + type -- the version that you use in programming).
 // Returns nil if not registered.
 func (tr *TypeRegistry) Type(typeName string) reflect.Type {
+	TypesMu.RLock()
+	defer TypesMu.RUnlock()
 	if typ, ok := tr.Types[typeName]; ok {
 		return typ
 	}
@@ -208,6 +227,8 @@ func (tr *TypeRegistry) Type(typeName string) reflect.Type {
 // to that type) using the long, unambiguous package-qualified name.
 // Returns nil if not found.
 func (tr *TypeRegistry) InstByName(typeName string) interface{} {
+	TypesMu.RLock()
+	defer TypesMu.RUnlock()
 	if inst, ok := tr.Insts[typeName]; ok {
 		return inst
 	}
@@ -302,7 +323,7 @@ func (tr *TypeRegistry) AllImplementersOf(iface reflect.Type, includeBases bool)
 		return nil
 	}
 	tl := make([]reflect.Type, 0)
-	for _, typ := range tr.Types {
+	for _, typ := range tr.allTypes() {
 		if !includeBases {
 			if btp, ok := tr.Prop(typ, "base-type"); ok {
 				if bt, ok := ToBool(btp); ok && bt {
@@ -328,7 +349,7 @@ func (tr *TypeRegistry) AllImplementersOf(iface reflect.Type, includeBases bool)
 // user-facing type selection
 func (tr *TypeRegistry) AllEmbedsOf(embed reflect.Type, inclusive, includeBases bool) []reflect.Type {
 	tl := make([]reflect.Type, 0)
-	for _, typ := range tr.Types {
+	for _, typ := range tr.allTypes() {
 		if !inclusive && typ == embed {
 			continue
 		}
@@ -351,7 +372,7 @@ func (tr *TypeRegistry) AllEmbedsOf(embed reflect.Type, inclusive, includeBases
 // its existence
 func (tr *TypeRegistry) AllTagged(key string) []reflect.Type {
 	tl := make([]reflect.Type, 0)
-	for _, typ := range tr.Types {
+	for _, typ := range tr.allTypes() {
 		_, ok := tr.Prop(typ, key)
 		if !ok {
 			continue
@@ -361,91 +382,111 @@ func (tr *TypeRegistry) AllTagged(key string) []reflect.Type {
 	return tl
 }
 
+// allTypes returns a list of all registered types, read under the TypesMu
+// lock, which is not held while the list is processed
+func (tr *TypeRegistry) allTypes() []reflect.Type {
+	TypesMu.RLock()
+	defer TypesMu.RUnlock()
+	tl := make([]reflect.Type, 0, len(tr.Types))
+	for _, typ := range tr.Types {
+		tl = append(tl, typ)
+	}
+	return tl
+}
+
 // Init initializes the type registry, including adding basic types
 func (tr *TypeRegistry) Init() {
+	TypesMu.Lock()
+	defer TypesMu.Unlock()
+	tr.init()
+}
+
+// init initializes the type registry, under the TypesMu lock
+func (tr *TypeRegistry) init() {
 	tr.Types = make(map[string]reflect.Type, 1000)
 	tr.Insts = make(map[string]interface{}, 1000)
 	tr.Props = make(map[string]map[string]interface{}, 1000)
 	tr.ShortNames = make(map[string]string, 1000)
+	tr.Migrations = make(map[string][]Migration)
 
 	{
 		var BoolProps = map[string]interface{}{
 			"basic-type": true,
 		}
 		ob := false
-		tr.AddType(&ob, BoolProps)
+		tr.addType(&ob, BoolProps)
 	}
 	{
 		var IntProps = map[string]interface{}{
 			"basic-type": true,
 		}
 		ob := int(0)
-		tr.AddType(&ob, IntProps)
+		tr.addType(&ob, IntProps)
 	}
 	{
 		ob := int8(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := int16(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := int32(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := int64(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := uint(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := uint8(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := uint16(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := uint32(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := uint64(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := uintptr(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := float32(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		var Float64Props = map[string]interface{}{
 			"basic-type": true,
 		}
 		ob := float64(0)
-		tr.AddType(&ob, Float64Props)
+		tr.addType(&ob, Float64Props)
 	}
 	{
 		ob := complex64(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		ob := complex128(0)
-		tr.AddType(&ob, nil)
+		tr.addType(&ob, nil)
 	}
 	{
 		var StringProps = map[string]interface{}{
 			"basic-type": true,
 		}
 		ob := ""
-		tr.AddType(&ob, StringProps)
+		tr.addType(&ob, StringProps)
 	}
 }
@@ -0,0 +1,39 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kit
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestTypesConcurrent adds types while reading the registry from other
+// goroutines -- run with -race
+func TestTypesConcurrent(t *testing.T) {
+	tr := &TypeRegistry{}
+	typ := tr.AddType(&A{}, map[string]interface{}{"tag": true})
+	snm := ShortTypeName(typ)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			tr.AddType(&B{}, nil)
+			tr.AddMigration(typ, 0, func(raw map[string]interface{}) error { return nil })
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if tr.Type(snm) != typ {
+				t.Errorf("Type: %v not found", snm)
+			}
+			tr.TypeName(typ)
+			tr.Inst(typ)
+			tr.AllTagged("tag")
+		}
+	}()
+	wg.Wait()
+}
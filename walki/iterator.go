@@ -0,0 +1,272 @@
+// Copyright (c) 2020, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package walki
+
+import (
+	"strings"
+
+	"github.com/goki/ki/ki"
+)
+
+// Order is the order in which an Iterator visits the nodes of a tree
+type Order int32
+
+const (
+	// PreOrder visits each node before its children (depth-first, "me first")
+	PreOrder Order = iota
+
+	// PostOrder visits each node after all of its children (depth-first, "me last")
+	PostOrder
+
+	// BreadthFirst visits all the nodes at a given depth before any deeper nodes
+	BreadthFirst
+
+	OrderN
+)
+
+// Iterator is a pull-style iterator over a tree, which can be advanced one
+// node at a time with Next, e.g., to process a tree piecemeal across
+// multiple event-loop ticks, unlike the callback-based FuncDown* methods.
+// Each step takes O(1) amortized time, using an explicit stack (or queue
+// for BreadthFirst).  Usage:
+//
+//	it := walki.NewIterator(root, walki.PreOrder, false)
+//	for it.Next() {
+//	    k := it.Node()
+//	    ...
+//	}
+//
+// If fields is true, the Ki fields of each node are visited before its
+// children, as in the FuncDown* methods.  The tree can be modified between
+// steps, but only changes to the children of nodes that have not yet been
+// reached are guaranteed to be reflected -- other changes may cause nodes
+// to be skipped or visited twice.
+type Iterator struct {
+	root   ki.Ki
+	order  Order
+	fields bool
+	stack  []iterItem // PreOrder, PostOrder: path from root to current node
+	cur    iterItem   // BreadthFirst: current node
+	queue  []iterItem // BreadthFirst: pending nodes, to visit after cur
+	skip   bool
+	start  bool
+	done   bool
+}
+
+// iterItem is a node on the Iterator stack or queue
+type iterItem struct {
+	k     ki.Ki
+	depth int
+	next  int       // PreOrder, PostOrder: index of next field / child to visit
+	par   *iterPath // BreadthFirst: path to parent, nil for root
+}
+
+// iterPath is the path from the root to a node, as a list from the node up
+// to the root, shared by the children of the node on the BreadthFirst
+// queue -- only the paths of pending nodes are kept
+type iterPath struct {
+	k   ki.Ki
+	par *iterPath
+}
+
+// NewIterator returns a new Iterator over the tree under given root node,
+// in given order, including Ki fields if fields is true.  Call Next to
+// move to the first node (the root, except for PostOrder).
+func NewIterator(root ki.Ki, order Order, fields bool) *Iterator {
+	it := &Iterator{root: root, order: order, fields: fields}
+	it.Reset()
+	return it
+}
+
+// Reset resets the iterator to start again from the root.
+func (it *Iterator) Reset() {
+	it.stack = it.stack[:0]
+	it.cur = iterItem{}
+	it.queue = nil
+	it.skip = false
+	it.start = true
+	it.done = it.root == nil || it.root.This() == nil
+}
+
+// Next moves to the next node, returning false when there are no more
+// nodes, in which case Node returns nil.
+func (it *Iterator) Next() bool {
+	if it.done {
+		return false
+	}
+	switch it.order {
+	case PostOrder:
+		it.nextPost()
+	case BreadthFirst:
+		it.nextBreadth()
+	default:
+		it.nextPre()
+	}
+	it.skip = false
+	it.start = false
+	return !it.done
+}
+
+// Node returns the current node, nil if Next has not yet been called, or
+// there are no more nodes.
+func (it *Iterator) Node() ki.Ki {
+	if it.start || it.done {
+		return nil
+	}
+	if it.order == BreadthFirst {
+		return it.cur.k
+	}
+	return it.stack[len(it.stack)-1].k
+}
+
+// Depth returns the depth of the current node, relative to the root (0).
+func (it *Iterator) Depth() int {
+	if it.start || it.done {
+		return -1
+	}
+	if it.order == BreadthFirst {
+		return it.cur.depth
+	}
+	return it.stack[len(it.stack)-1].depth
+}
+
+// Path returns the path to the current node from the root, using unique
+// names, with nodes separated by / and fields by . -- e.g., /root/child1.
+// Unlike PathUnique, the path starts at the root of the iterator.
+func (it *Iterator) Path() string {
+	if it.start || it.done {
+		return ""
+	}
+	var nodes []ki.Ki
+	if it.order == BreadthFirst {
+		nodes = append(nodes, it.cur.k)
+		for p := it.cur.par; p != nil; p = p.par {
+			nodes = append(nodes, p.k)
+		}
+		for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+			nodes[i], nodes[j] = nodes[j], nodes[i]
+		}
+	} else {
+		nodes = make([]ki.Ki, len(it.stack))
+		for i := range it.stack {
+			nodes[i] = it.stack[i].k
+		}
+	}
+	var sb strings.Builder
+	for i, k := range nodes {
+		if i > 0 && k.IsField() {
+			sb.WriteString(".")
+		} else {
+			sb.WriteString("/")
+		}
+		sb.WriteString(k.UniqueName())
+	}
+	return sb.String()
+}
+
+// SkipChildren skips the fields and children of the current node, which
+// are then not visited.  Only has an effect for PreOrder and BreadthFirst,
+// as PostOrder has already visited them.
+func (it *Iterator) SkipChildren() {
+	it.skip = true
+}
+
+// numItems returns the number of sub-items of k: fields and then children
+func (it *Iterator) numItems(k ki.Ki) int {
+	if it.fields {
+		return k.NumKiFields() + k.NumChildren()
+	}
+	return k.NumChildren()
+}
+
+// item returns sub-item of k at given index, as counted by numItems --
+// nil if not a valid node
+func (it *Iterator) item(k ki.Ki, idx int) ki.Ki {
+	if it.fields {
+		nf := k.NumKiFields()
+		if idx < nf {
+			return k.KiField(idx)
+		}
+		idx -= nf
+	}
+	kid, err := k.ChildTry(idx)
+	if err != nil || kid == nil {
+		return nil
+	}
+	return kid.This()
+}
+
+// push pushes the next valid sub-item of the top of the stack, returning
+// false if there are none left
+func (it *Iterator) push() bool {
+	top := &it.stack[len(it.stack)-1]
+	for top.next < it.numItems(top.k) {
+		k := it.item(top.k, top.next)
+		top.next++
+		if k != nil {
+			it.stack = append(it.stack, iterItem{k: k, depth: top.depth + 1})
+			return true
+		}
+	}
+	return false
+}
+
+func (it *Iterator) nextPre() {
+	if it.start {
+		it.stack = append(it.stack, iterItem{k: it.root})
+		return
+	}
+	if it.skip {
+		it.stack[len(it.stack)-1].next = it.numItems(it.stack[len(it.stack)-1].k)
+	}
+	for len(it.stack) > 0 {
+		if it.push() {
+			return
+		}
+		it.stack = it.stack[:len(it.stack)-1]
+	}
+	it.done = true
+}
+
+func (it *Iterator) nextPost() {
+	if it.start {
+		it.stack = append(it.stack, iterItem{k: it.root})
+	} else {
+		it.stack = it.stack[:len(it.stack)-1] // visited last time
+		if len(it.stack) == 0 {
+			it.done = true
+			return
+		}
+	}
+	for it.push() {
+	}
+}
+
+func (it *Iterator) nextBreadth() {
+	if it.start {
+		it.cur = iterItem{k: it.root}
+		return
+	}
+	if !it.skip {
+		n := it.numItems(it.cur.k)
+		var par *iterPath
+		for i := 0; i < n; i++ {
+			if k := it.item(it.cur.k, i); k != nil {
+				if par == nil {
+					par = &iterPath{k: it.cur.k, par: it.cur.par}
+				}
+				it.queue = append(it.queue, iterItem{k: k, depth: it.cur.depth + 1, par: par})
+			}
+		}
+	}
+	if len(it.queue) == 0 {
+		it.cur = iterItem{}
+		it.done = true
+		return
+	}
+	it.cur = it.queue[0]
+	it.queue[0] = iterItem{} // don't keep visited nodes
+	it.queue = it.queue[1:]
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2020, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package walki
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/goki/ki/ki"
+	"github.com/goki/ki/kit"
+)
+
+type fieldNode struct {
+	ki.Node
+	Field1 ki.Node
+}
+
+var kiT_fieldNode = kit.Types.AddType(&fieldNode{}, nil)
+
+func fieldTree() ki.Ki {
+	root := &fieldNode{}
+	root.InitName(root, "root")
+	c0 := root.AddNewChild(kiT_fieldNode, "child0")
+	c0.AddNewChild(nil, "subchild0")
+	c0.(*fieldNode).Field1.AddNewChild(nil, "fieldchild")
+	root.AddNewChild(nil, "child1")
+	return root
+}
+
+func iterNames(it *Iterator) []string {
+	var nms []string
+	for it.Next() {
+		nms = append(nms, it.Node().Name())
+	}
+	return nms
+}
+
+func funcNames(fun func(f ki.Func)) []string {
+	var nms []string
+	fun(func(k ki.Ki, level int, d interface{}) bool {
+		nms = append(nms, k.Name())
+		return ki.Continue
+	})
+	return nms
+}
+
+func TestIterator(t *testing.T) {
+	for _, root := range []ki.Ki{testTree, fieldTree()} {
+		pre := funcNames(func(f ki.Func) { root.FuncDownMeFirst(0, nil, f) })
+		post := funcNames(func(f ki.Func) {
+			root.FuncDownMeLast(0, nil, func(k ki.Ki, level int, d interface{}) bool { return true }, f)
+		})
+		bfs := funcNames(func(f ki.Func) { root.FuncDownBreadthFirst(0, nil, f) })
+		for i, want := range [][]string{pre, post, bfs} {
+			if got := iterNames(NewIterator(root, Order(i), true)); !reflect.DeepEqual(got, want) {
+				t.Errorf("order %v:\n%v\nvs.\n%v", i, got, want)
+			}
+		}
+	}
+
+	root := fieldTree()
+	want := map[Order][]string{
+		PreOrder:     {"root", "child0", "subchild0", "child1"},
+		PostOrder:    {"subchild0", "child0", "child1", "root"},
+		BreadthFirst: {"root", "child0", "child1", "subchild0"},
+	}
+	for ord, nms := range want {
+		it := NewIterator(root, ord, false)
+		if got := iterNames(it); !reflect.DeepEqual(got, nms) {
+			t.Errorf("order %v without fields: %v", ord, got)
+		}
+		if it.Next() || it.Node() != nil || it.Depth() != -1 {
+			t.Errorf("iterator not done")
+		}
+		it.Reset()
+		if !it.Next() || it.Node().Name() != nms[0] {
+			t.Errorf("Reset did not restart")
+		}
+	}
+}
+
+func TestIteratorSkipPath(t *testing.T) {
+	root := fieldTree()
+	for _, ord := range []Order{PreOrder, BreadthFirst} {
+		var nms []string
+		it := NewIterator(root, ord, true)
+		for it.Next() {
+			nms = append(nms, it.Node().Name())
+			if it.Node().Name() == "child0" {
+				it.SkipChildren()
+			}
+		}
+		if !reflect.DeepEqual(nms, []string{"root", "Field1", "child0", "child1", "Field1"}) {
+			t.Errorf("order %v skip: %v", ord, nms)
+		}
+	}
+
+	for ord := PreOrder; ord < OrderN; ord++ {
+		it := NewIterator(root, ord, true)
+		for it.Next() {
+			k := it.Node()
+			if it.Path() != k.PathUnique() {
+				t.Errorf("order %v Path: %v vs. %v", ord, it.Path(), k.PathUnique())
+			}
+			if d := k.ParentLevel(root); it.Depth() != d+1 && k != root {
+				t.Errorf("order %v Depth of %v: %v vs. %v", ord, k.Name(), it.Depth(), d+1)
+			}
+		}
+	}
+
+	// piecemeal, with the tree changing between steps
+	it := NewIterator(root, PreOrder, false)
+	it.Next()
+	it.Next()
+	it.Node().AddNewChild(nil, "added")
+	root.AddNewChild(nil, "child2")
+	if got := iterNames(it); !reflect.DeepEqual(got, []string{"subchild0", "added", "child1", "child2"}) {
+		t.Errorf("piecemeal: %v", got)
+	}
+}
+
+func TestIteratorBreadthFrontier(t *testing.T) {
+	root := &ki.Node{}
+	root.InitName(root, "root")
+	k := ki.Ki(root)
+	for i := 0; i < 100; i++ {
+		k.AddNewChild(nil, "sib")
+		k = k.AddNewChild(nil, "kid")
+	}
+	it := NewIterator(root, BreadthFirst, false)
+	for it.Next() {
+		if len(it.queue) > 2 {
+			t.Fatalf("queue keeps visited nodes: %v at depth %v", len(it.queue), it.Depth())
+		}
+		if it.Node() == k && len(it.Path()) != len("/root")+100*len("/kid") {
+			t.Errorf("Path of deepest node: %v", it.Path())
+		}
+	}
+}
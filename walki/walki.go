@@ -6,6 +6,8 @@
 Package walki provides basic tree walking functions for iterative traversal
 of the tree in up / down directions.  As compared to the core Func methods
 defined in ki package, these are for more dynamic, piecemeal processing.
+The Iterator supports pre-order, post-order and breadth-first traversal,
+one node at a time, with optional inclusion of Ki fields.
 */
 package walki
 
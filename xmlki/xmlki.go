@@ -0,0 +1,465 @@
+// Copyright (c) 2020, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package xmlki imports arbitrary XML (and HTML) documents into ki trees, using
+generic node types for elements, text, comments etc, and exports them back
+to XML, without the need to write a Go type for each element.
+
+ImportXML returns a Document node, whose children are the ProcInst,
+Directive and Comment nodes of the prolog and the root Element.  Each Element
+has its qualified Tag (e.g., svg:rect), with the attributes as Props keyed by
+their qualified names (including xmlns namespace declarations), and the text
+content in Text if it only contains text -- otherwise the mixed content is
+represented as Text, Comment etc children in order.  Namespace prefixes are
+kept as written, so ExportXML writes the same document back (attributes are
+written in sorted order).
+
+An Importer can map tag names to registered kit.Types that embed Element, so
+that typed nodes are created for them.  Fields of these types with an
+`xml:"name,attr"` tag are set from the attributes of that name, instead of
+Props.
+*/
+package xmlki
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/goki/ki/ki"
+	"github.com/goki/ki/kit"
+)
+
+// Document is the root node of an imported document, whose children are the
+// nodes of the prolog (ProcInst, Directive, Comment) and the root Element.
+type Document struct {
+	ki.Node
+}
+
+var KiT_Document = kit.Types.AddType(&Document{}, nil)
+
+// Element is a generic XML element, with attributes as Props and child
+// elements (and text, comments etc for mixed content) as children.
+type Element struct {
+	ki.Node
+	Tag  string `desc:"qualified tag name, with namespace prefix if any, e.g., svg:rect"`
+	Text string `desc:"text content, if the element only contains text -- otherwise text is in Text children"`
+}
+
+var KiT_Element = kit.Types.AddType(&Element{}, nil)
+
+// NamespaceURI returns the namespace of the element, from the xmlns
+// declarations of the element and its ancestors for the prefix of its Tag.
+func (el *Element) NamespaceURI() string {
+	attr := "xmlns"
+	if ci := strings.Index(el.Tag, ":"); ci >= 0 {
+		attr += ":" + el.Tag[:ci]
+	}
+	for k := ki.Ki(el.This()); k != nil; k = k.Parent() {
+		pel, ok := k.Embed(KiT_Element).(*Element)
+		if !ok {
+			break
+		}
+		if ns, ok := pel.Prop(attr).(string); ok {
+			return ns
+		}
+	}
+	return ""
+}
+
+// Text is a text (character data) node within mixed content
+type Text struct {
+	ki.Node
+	Data string
+}
+
+var KiT_Text = kit.Types.AddType(&Text{}, nil)
+
+// Comment is an XML comment
+type Comment struct {
+	ki.Node
+	Data string
+}
+
+var KiT_Comment = kit.Types.AddType(&Comment{}, nil)
+
+// ProcInst is an XML processing instruction, e.g., the <?xml ...?> declaration
+type ProcInst struct {
+	ki.Node
+	Target string
+	Inst   string
+}
+
+var KiT_ProcInst = kit.Types.AddType(&ProcInst{}, nil)
+
+// Directive is an XML directive, e.g., <!DOCTYPE ...>
+type Directive struct {
+	ki.Node
+	Data string
+}
+
+var KiT_Directive = kit.Types.AddType(&Directive{}, nil)
+
+// Importer imports XML documents into ki trees
+type Importer struct {
+
+	// Types maps qualified tag names to registered types embedding Element,
+	// which are created for those tags instead of Element
+	Types map[string]reflect.Type
+
+	// KeepSpace keeps whitespace-only text between elements, which is
+	// otherwise dropped
+	KeepSpace bool
+
+	// HTML decodes in the lax mode of encoding/xml for HTML, with HTML
+	// entities, and void elements such as br that have no end tag
+	HTML bool
+}
+
+// ImportXML imports an XML document into a new tree of generic nodes,
+// returning the Document node -- see Importer for options.
+func ImportXML(r io.Reader) (ki.Ki, error) {
+	im := Importer{}
+	return im.Import(r)
+}
+
+// ImportHTML imports an HTML document into a new tree of generic nodes,
+// returning the Document node -- see Importer for options.
+func ImportHTML(r io.Reader) (ki.Ki, error) {
+	im := Importer{HTML: true}
+	return im.Import(r)
+}
+
+// Import imports an XML document into a new tree, returning the Document node
+func (im *Importer) Import(r io.Reader) (ki.Ki, error) {
+	for tag, typ := range im.Types {
+		if kit.Types.Type(kit.Types.TypeName(typ)) != typ {
+			return nil, fmt.Errorf("xmlki.Import: type %v for tag %v is not registered in kit.Types", typ, tag)
+		}
+		if !kit.TypeEmbeds(typ, KiT_Element) {
+			return nil, fmt.Errorf("xmlki.Import: type %v for tag %v does not embed xmlki.Element", typ, tag)
+		}
+	}
+	d := xml.NewDecoder(r)
+	if im.HTML {
+		d.Strict = false
+		d.Entity = xml.HTMLEntity
+	}
+	doc := &Document{}
+	doc.InitName(doc, "document")
+	stack := []*importLevel{{k: doc.This()}}
+	var void string // open HTML void element that may have an explicit end
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xmlki.Import: line %d: %v", importLine(d), err)
+		}
+		top := stack[len(stack)-1]
+		if void != "" {
+			vd := void
+			void = ""
+			if et, ok := t.(xml.EndElement); ok && strings.EqualFold(qualName(et.Name), vd) {
+				continue
+			}
+		}
+		switch tv := t.(type) {
+		case xml.StartElement:
+			tag := qualName(tv.Name)
+			k, err := im.newElement(tag, tv.Attr)
+			if err != nil {
+				return nil, fmt.Errorf("xmlki.Import: line %d: %v", importLine(d), err)
+			}
+			top.add(k)
+			if im.HTML && isHTMLVoid(tag) {
+				void = tag
+				continue
+			}
+			stack = append(stack, &importLevel{k: k, tag: tag})
+		case xml.EndElement:
+			tag := qualName(tv.Name)
+			li := len(stack) - 1
+			if im.HTML { // close any unclosed elements up to matching one
+				for li > 0 && !strings.EqualFold(stack[li].tag, tag) {
+					li--
+				}
+				if li == 0 { // stray end tag
+					continue
+				}
+			} else if li == 0 || stack[li].tag != tag {
+				return nil, fmt.Errorf("xmlki.Import: line %d: element <%v> closed by </%v>", importLine(d), top.tag, tag)
+			}
+			for len(stack) > li {
+				stack[len(stack)-1].end(im.KeepSpace)
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) == 1 && !im.KeepSpace && len(strings.TrimSpace(string(tv))) == 0 {
+				continue
+			}
+			top.addText(string(tv))
+		case xml.Comment:
+			cm := &Comment{Data: string(tv)}
+			cm.InitName(cm, "comment")
+			top.add(cm.This())
+		case xml.ProcInst:
+			pi := &ProcInst{Target: tv.Target, Inst: string(tv.Inst)}
+			pi.InitName(pi, tv.Target)
+			top.add(pi.This())
+		case xml.Directive:
+			dr := &Directive{Data: string(tv)}
+			dr.InitName(dr, "directive")
+			top.add(dr.This())
+		}
+	}
+	if len(stack) > 1 {
+		if !im.HTML {
+			return nil, fmt.Errorf("xmlki.Import: line %d: element <%v> not closed", importLine(d), stack[len(stack)-1].tag)
+		}
+		for len(stack) > 1 {
+			stack[len(stack)-1].end(im.KeepSpace)
+			stack = stack[:len(stack)-1]
+		}
+	}
+	stack[0].end(im.KeepSpace)
+	return doc.This(), nil
+}
+
+// importLine returns the current line of the decoder
+func importLine(d *xml.Decoder) int {
+	line, _ := d.InputPos()
+	return line
+}
+
+// newElement returns a new element node for given tag and attributes,
+// of the type for the tag if any
+func (im *Importer) newElement(tag string, attrs []xml.Attr) (ki.Ki, error) {
+	typ := KiT_Element
+	if ttyp, ok := im.Types[tag]; ok {
+		typ = ttyp
+	}
+	k := ki.NewOfType(typ)
+	k.InitName(k, tag)
+	el := k.Embed(KiT_Element).(*Element)
+	el.Tag = tag
+	var flds map[string]reflect.Value
+	if typ != KiT_Element {
+		flds = attrFields(k)
+	}
+	for _, attr := range attrs {
+		nm := qualName(attr.Name)
+		if fv, ok := flds[nm]; ok {
+			if !kit.SetRobust(fv.Addr().Interface(), attr.Value) {
+				return nil, fmt.Errorf("cannot set field for attribute %v of <%v> from: %v", nm, tag, attr.Value)
+			}
+			continue
+		}
+		k.SetProp(nm, attr.Value)
+	}
+	return k, nil
+}
+
+// attrFields returns the fields of given node with xml attr tags, by name
+func attrFields(k ki.Ki) map[string]reflect.Value {
+	flds := make(map[string]reflect.Value)
+	kit.FlatFieldsValueFunc(k, func(stru interface{}, typ reflect.Type, field reflect.StructField, fieldVal reflect.Value) bool {
+		if nm, ok := attrFieldName(field); ok {
+			flds[nm] = fieldVal
+		}
+		return true
+	})
+	return flds
+}
+
+// attrFieldName returns the attribute name of given field, if it has an xml
+// attr tag
+func attrFieldName(field reflect.StructField) (string, bool) {
+	tag := strings.Split(field.Tag.Get("xml"), ",")
+	if len(tag) < 2 || tag[1] != "attr" {
+		return "", false
+	}
+	if tag[0] != "" {
+		return tag[0], true
+	}
+	return field.Name, true
+}
+
+// qualName returns the qualified name as written, prefix:local
+func qualName(nm xml.Name) string {
+	if nm.Space == "" {
+		return nm.Local
+	}
+	return nm.Space + ":" + nm.Local
+}
+
+// isHTMLVoid returns true if tag is an HTML element without an end tag
+func isHTMLVoid(tag string) bool {
+	for _, vt := range xml.HTMLAutoClose {
+		if strings.EqualFold(tag, vt) {
+			return true
+		}
+	}
+	return false
+}
+
+// importLevel accumulates the children of a node being imported, to add
+// them all at the end
+type importLevel struct {
+	k    ki.Ki
+	tag  string
+	kids ki.Slice
+}
+
+// add adds given child node
+func (il *importLevel) add(k ki.Ki) {
+	il.kids = append(il.kids, k)
+}
+
+// addText adds text, appending to a preceding text node
+func (il *importLevel) addText(str string) {
+	if n := len(il.kids); n > 0 {
+		if tx, ok := il.kids[n-1].(*Text); ok {
+			tx.Data += str
+			return
+		}
+	}
+	tx := &Text{Data: str}
+	tx.InitName(tx, "text")
+	il.add(tx.This())
+}
+
+// end sets the children of the node -- content that is only text is set
+// as the Text of an Element, and whitespace-only text in mixed content is
+// dropped unless keepSpace
+func (il *importLevel) end(keepSpace bool) {
+	if el, ok := il.k.Embed(KiT_Element).(*Element); ok {
+		allText := true
+		for _, kid := range il.kids {
+			if _, ok := kid.(*Text); !ok {
+				allText = false
+				break
+			}
+		}
+		if allText {
+			for _, kid := range il.kids {
+				el.Text += kid.(*Text).Data
+			}
+			return
+		}
+	}
+	kids := il.k.Children()
+	for _, kid := range il.kids {
+		if tx, ok := kid.(*Text); ok && !keepSpace && len(strings.TrimSpace(tx.Data)) == 0 {
+			continue
+		}
+		*kids = append(*kids, kid)
+		kid.SetParent(il.k)
+	}
+	il.k.UniquifyNames()
+}
+
+//////////////////////////////////////////////////////////////////////////
+//  Export
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;", "\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;")
+)
+
+// ExportXML writes the tree starting at given node (a Document or Element,
+// or any of the other node types) as XML.
+func ExportXML(k ki.Ki, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := exportNode(bw, k); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// exportNode writes given node and its children
+func exportNode(w *bufio.Writer, k ki.Ki) error {
+	switch nd := k.(type) {
+	case *Document:
+		return exportKids(w, k)
+	case *Text:
+		textEscaper.WriteString(w, nd.Data)
+		return nil
+	case *Comment:
+		if strings.Contains(nd.Data, "--") {
+			return fmt.Errorf("xmlki.ExportXML: comment contains --: %v", nd.Data)
+		}
+		w.WriteString("<!--" + nd.Data + "-->")
+		return nil
+	case *ProcInst:
+		w.WriteString("<?" + nd.Target)
+		if nd.Inst != "" {
+			w.WriteString(" " + nd.Inst)
+		}
+		w.WriteString("?>")
+		return nil
+	case *Directive:
+		w.WriteString("<!" + nd.Data + ">")
+		return nil
+	}
+	el, ok := k.Embed(KiT_Element).(*Element)
+	if !ok {
+		return fmt.Errorf("xmlki.ExportXML: node %v of type %v is not an xmlki node type", k.Path(), k.Type())
+	}
+	if el.Tag == "" {
+		return fmt.Errorf("xmlki.ExportXML: element %v has no Tag", k.Path())
+	}
+	w.WriteString("<" + el.Tag)
+	if k.Type() != KiT_Element {
+		kit.FlatFieldsValueFunc(k, func(stru interface{}, typ reflect.Type, field reflect.StructField, fieldVal reflect.Value) bool {
+			if nm, ok := attrFieldName(field); ok {
+				exportAttr(w, nm, kit.ToString(stru))
+			}
+			return true
+		})
+	}
+	props := *k.Properties()
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		exportAttr(w, key, kit.ToString(props[key]))
+	}
+	if el.Text == "" && !k.HasChildren() {
+		w.WriteString("/>")
+		return nil
+	}
+	w.WriteString(">")
+	textEscaper.WriteString(w, el.Text)
+	if err := exportKids(w, k); err != nil {
+		return err
+	}
+	w.WriteString("</" + el.Tag + ">")
+	return nil
+}
+
+// exportAttr writes an attribute
+func exportAttr(w *bufio.Writer, nm, val string) {
+	w.WriteString(" " + nm + "=\"")
+	attrEscaper.WriteString(w, val)
+	w.WriteString("\"")
+}
+
+// exportKids writes the children of given node
+func exportKids(w *bufio.Writer, k ki.Ki) error {
+	for _, kid := range *k.Children() {
+		if err := exportNode(w, kid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
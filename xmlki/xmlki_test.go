@@ -0,0 +1,159 @@
+// Copyright (c) 2020, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xmlki
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goki/ki/ki"
+	"github.com/goki/ki/kit"
+)
+
+var testDoc = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<!-- drawing -->
+<svg:svg height="10" width="20" xmlns:svg="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">
+  <svg:title>A &amp; B</svg:title>
+  <svg:g id="layer1">
+    <!-- shapes -->
+    <svg:rect id="r1" x="1.5" y="2"/>
+    <svg:rect id="r2" x="3" y="4"/>
+    <svg:use xlink:href="#r1"/>
+  </svg:g>
+  <svg:text x="0">Some <svg:tspan>mixed</svg:tspan> text &lt;here&gt;</svg:text>
+</svg:svg>`
+
+func TestImportExport(t *testing.T) {
+	im := Importer{KeepSpace: true}
+	doc, err := im.Import(strings.NewReader(testDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ExportXML(doc, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != testDoc {
+		t.Errorf("export not same as import:\n%v\nvs.\n%v", buf.String(), testDoc)
+	}
+
+	doc, err = ImportXML(strings.NewReader(testDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.NumChildren() != 4 {
+		t.Fatalf("expected prolog and root, got: %v", *doc.Children())
+	}
+	if pi, ok := doc.Child(0).(*ProcInst); !ok || pi.Target != "xml" {
+		t.Errorf("ProcInst not imported: %v", doc.Child(0))
+	}
+	if _, ok := doc.Child(1).(*Directive); !ok {
+		t.Errorf("Directive not imported: %v", doc.Child(1))
+	}
+	if cm, ok := doc.Child(2).(*Comment); !ok || cm.Data != " drawing " {
+		t.Errorf("Comment not imported: %v", doc.Child(2))
+	}
+	svg := doc.Child(3).(*Element)
+	if svg.Tag != "svg:svg" || svg.Prop("width") != "20" || svg.NumChildren() != 3 {
+		t.Errorf("root element not imported: %v %v", svg.Tag, svg.Properties())
+	}
+	if title := svg.Child(0).(*Element); title.Text != "A & B" || title.HasChildren() {
+		t.Errorf("text content not imported: %q", title.Text)
+	}
+	g := svg.Child(1).(*Element)
+	if g.NumChildren() != 4 || g.Child(1).UniqueName() != "svg:rect" || g.Child(2).UniqueName() != "svg:rect_002" {
+		t.Errorf("element order not kept: %v", *g.Children())
+	}
+	if r1 := g.Child(1).(*Element); r1.Prop("x") != "1.5" || r1.NamespaceURI() != "http://www.w3.org/2000/svg" {
+		t.Errorf("attributes or namespace not imported: %v %v", r1.Properties(), r1.NamespaceURI())
+	}
+	txt := svg.Child(2).(*Element)
+	if txt.NumChildren() != 3 || txt.Child(0).(*Text).Data != "Some " || txt.Child(2).(*Text).Data != " text <here>" {
+		t.Errorf("mixed content not imported: %v", *txt.Children())
+	}
+
+	buf.Reset()
+	ExportXML(doc, &buf)
+	compact := `<?xml version="1.0" encoding="UTF-8"?><!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd"><!-- drawing --><svg:svg height="10" width="20" xmlns:svg="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><svg:title>A &amp; B</svg:title><svg:g id="layer1"><!-- shapes --><svg:rect id="r1" x="1.5" y="2"/><svg:rect id="r2" x="3" y="4"/><svg:use xlink:href="#r1"/></svg:g><svg:text x="0">Some <svg:tspan>mixed</svg:tspan> text &lt;here&gt;</svg:text></svg:svg>`
+	if buf.String() != compact {
+		t.Errorf("compact export:\n%v\nvs.\n%v", buf.String(), compact)
+	}
+}
+
+type Rect struct {
+	Element
+	X float32 `xml:"x,attr"`
+	Y float32 `xml:"y,attr"`
+}
+
+var KiT_Rect = kit.Types.AddType(&Rect{}, nil)
+
+func TestImportTypes(t *testing.T) {
+	im := Importer{Types: map[string]reflect.Type{"svg:rect": KiT_Rect}}
+	doc, err := im.Import(strings.NewReader(testDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1, ok := doc.Child(3).Child(1).Child(1).(*Rect)
+	if !ok {
+		t.Fatalf("typed node not created: %T", doc.Child(3).Child(1).Child(1))
+	}
+	if r1.X != 1.5 || r1.Y != 2 || r1.Prop("id") != "r1" || r1.Prop("x") != nil {
+		t.Errorf("attributes not set: %v %v %v", r1.X, r1.Y, r1.Properties())
+	}
+	r1.X = 5
+	var buf bytes.Buffer
+	ExportXML(r1, &buf)
+	if buf.String() != `<svg:rect x="5" y="2" id="r1"/>` {
+		t.Errorf("typed export: %v", buf.String())
+	}
+
+	for _, typ := range []reflect.Type{ki.KiT_Node, reflect.TypeOf(struct{ Element }{})} {
+		im.Types["svg:rect"] = typ
+		if _, err := im.Import(strings.NewReader(testDoc)); err == nil {
+			t.Errorf("expected error for type %v", typ)
+		}
+	}
+	im.Types["svg:rect"] = KiT_Rect
+	if _, err := im.Import(strings.NewReader(`<svg:rect x="abc"/>`)); err == nil {
+		t.Errorf("expected error for bad attribute value")
+	}
+}
+
+func TestImportHTML(t *testing.T) {
+	doc, err := ImportHTML(strings.NewReader(`<html><body><p>a&nbsp;b<br>c<img src="x.png"></p><p>d<br/>e</p></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := doc.Child(0).Child(0)
+	p := body.Child(0)
+	if body.NumChildren() != 2 || p.NumChildren() != 4 || p.Child(0).(*Text).Data != "a\u00a0b" {
+		t.Errorf("HTML not imported: %v %v", *body.Children(), *p.Children())
+	}
+	if body.Child(1).NumChildren() != 3 {
+		t.Errorf("explicitly closed void element: %v", *body.Child(1).Children())
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	for _, bad := range []string{
+		`<a><b></a>`,
+		`<a><b></b>`,
+		`<a>&nbsp;</a>`,
+		`<a x=1></a>`,
+	} {
+		if _, err := ImportXML(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for: %v", bad)
+		}
+	}
+	cm := &Comment{Data: "a -- b"}
+	cm.InitName(cm, "comment")
+	if err := ExportXML(cm, &bytes.Buffer{}); err == nil {
+		t.Errorf("expected error for comment with --")
+	}
+}